@@ -2,8 +2,10 @@ package keystore
 
 import (
 	"bytes"
+	"crypto/ecdsa"
 	"crypto/rsa"
 	"crypto/x509"
+	"encoding/asn1"
 	"encoding/pem"
 	"errors"
 	"fmt"
@@ -11,6 +13,8 @@ import (
 	"io/fs"
 	"path"
 	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
 )
 
 // key represents key information.
@@ -22,13 +26,15 @@ type key struct {
 // KeyStore represents an in memory store implementation of the
 // KeyLookup interface for use with the auth package.
 type KeyStore struct {
-	store map[string]key
+	store     map[string]key
+	secp256k1 map[string]*ecdsa.PrivateKey
 }
 
 // New constructs an empty KeyStore ready for use.
 func New() *KeyStore {
 	return &KeyStore{
-		store: make(map[string]key),
+		store:     make(map[string]key),
+		secp256k1: make(map[string]*ecdsa.PrivateKey),
 	}
 }
 
@@ -37,7 +43,45 @@ func New() *KeyStore {
 // Example: ks.LoadRSAKeys(os.DirFS("/zarf/keys/"))
 // Example: /zarf/keys/54bb2165-71e1-41a6-af3e-7da4a0e1e2c1.pem
 func (ks *KeyStore) LoadRSAKeys(fsys fs.FS) error {
-	fn := func(fileName string, dirEntry fs.DirEntry, err error) error {
+	return walkPEMFiles(fsys, func(kid string, pem []byte) error {
+		privatePEM := string(pem)
+		publicPEM, err := toPublicPEM(privatePEM)
+		if err != nil {
+			return fmt.Errorf("converting private PEM to public: %w", err)
+		}
+
+		key := key{
+			privatePEM: privatePEM,
+			publicPEM:  publicPEM,
+		}
+
+		ks.store[kid] = key
+
+		return nil
+	})
+}
+
+// LoadSecp256k1Keys loads a set of secp256k1 PEM files rooted inside of a
+// directory, such as generated by openssl ecparam -name secp256k1 -genkey.
+// These keys sign Ethereum transactions and messages. The name of each PEM
+// file will be used as the key id.
+func (ks *KeyStore) LoadSecp256k1Keys(fsys fs.FS) error {
+	return walkPEMFiles(fsys, func(kid string, pem []byte) error {
+		key, err := parseSecp256k1PEM(pem)
+		if err != nil {
+			return fmt.Errorf("parsing secp256k1 key %s: %w", kid, err)
+		}
+
+		ks.secp256k1[kid] = key
+
+		return nil
+	})
+}
+
+// walkPEMFiles calls fn with the id and the content of every PEM file rooted
+// inside of a directory.
+func walkPEMFiles(fsys fs.FS, fn func(kid string, pem []byte) error) error {
+	walk := func(fileName string, dirEntry fs.DirEntry, err error) error {
 		if err != nil {
 			return fmt.Errorf("walkdir failure: %w", err)
 		}
@@ -64,23 +108,10 @@ func (ks *KeyStore) LoadRSAKeys(fsys fs.FS) error {
 			return fmt.Errorf("reading auth private key: %w", err)
 		}
 
-		privatePEM := string(pem)
-		publicPEM, err := toPublicPEM(privatePEM)
-		if err != nil {
-			return fmt.Errorf("converting private PEM to public: %w", err)
-		}
-
-		key := key{
-			privatePEM: privatePEM,
-			publicPEM:  publicPEM,
-		}
-
-		ks.store[strings.TrimSuffix(dirEntry.Name(), ".pem")] = key
-
-		return nil
+		return fn(strings.TrimSuffix(dirEntry.Name(), ".pem"), pem)
 	}
 
-	if err := fs.WalkDir(fsys, ".", fn); err != nil {
+	if err := fs.WalkDir(fsys, ".", walk); err != nil {
 		return fmt.Errorf("walking directory: %w", err)
 	}
 
@@ -107,6 +138,17 @@ func (ks *KeyStore) PublicKey(kid string) (string, error) {
 	return key.publicPEM, nil
 }
 
+// Secp256k1Key searches the key store for a given kid and returns the
+// secp256k1 private key.
+func (ks *KeyStore) Secp256k1Key(kid string) (*ecdsa.PrivateKey, error) {
+	key, found := ks.secp256k1[kid]
+	if !found {
+		return nil, errors.New("kid lookup failed")
+	}
+
+	return key, nil
+}
+
 func toPublicPEM(privatePEM string) (string, error) {
 	block, _ := pem.Decode([]byte(privatePEM))
 	if block == nil {
@@ -144,3 +186,39 @@ func toPublicPEM(privatePEM string) (string, error) {
 
 	return buf.String(), nil
 }
+
+// oidSecp256k1 identifies the secp256k1 curve in SEC 1 keys.
+var oidSecp256k1 = asn1.ObjectIdentifier{1, 3, 132, 0, 10}
+
+// ecPrivateKey is the SEC 1 structure of EC private keys. The x509 package
+// doesn't parse it for the secp256k1 curve.
+type ecPrivateKey struct {
+	Version       int
+	PrivateKey    []byte
+	NamedCurveOID asn1.ObjectIdentifier `asn1:"optional,explicit,tag:0"`
+	PublicKey     asn1.BitString        `asn1:"optional,explicit,tag:1"`
+}
+
+func parseSecp256k1PEM(data []byte) (*ecdsa.PrivateKey, error) {
+	// Skip the EC PARAMETERS block openssl writes before the key.
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, errors.New("invalid key: Key must be a PEM encoded EC PRIVATE KEY")
+		}
+		if block.Type != "EC PRIVATE KEY" {
+			continue
+		}
+
+		var key ecPrivateKey
+		if _, err := asn1.Unmarshal(block.Bytes, &key); err != nil {
+			return nil, fmt.Errorf("parsing EC private key: %w", err)
+		}
+		if key.NamedCurveOID != nil && !key.NamedCurveOID.Equal(oidSecp256k1) {
+			return nil, errors.New("key is not a secp256k1 private key")
+		}
+
+		return crypto.ToECDSA(key.PrivateKey)
+	}
+}
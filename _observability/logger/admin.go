@@ -0,0 +1,247 @@
+package logger
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// LevelSettings is the state of a GlogHandler as exposed by a LevelHandler.
+// Verbosity uses the legacy 0 (crit) to 5 (trace) scale, like Vmodule.
+type LevelSettings struct {
+	Verbosity int        `json:"verbosity"`
+	Level     string     `json:"level"`
+	Vmodule   string     `json:"vmodule"`
+	RestoreAt *time.Time `json:"restoreAt,omitempty"` // When the previous settings come back, if temporary
+}
+
+// levelChange is the body of a request changing the settings. Omitted fields
+// are left untouched.
+type levelChange struct {
+	Verbosity *int    `json:"verbosity"`
+	Vmodule   *string `json:"vmodule"`
+	TTL       string  `json:"ttl"` // Optional duration, e.g. "15m"
+}
+
+// LevelHandlerOptions configures a LevelHandler.
+type LevelHandlerOptions struct {
+	// Logger records every change. Defaults to the root logger.
+	Logger Logger
+
+	// Identify returns who made a request, for the change log. Defaults to
+	// the basic auth user, if any, and the remote address.
+	Identify func(*http.Request) string
+}
+
+// LevelHandler is an http.Handler reading and changing the verbosity and
+// vmodule of a GlogHandler at runtime, to be mounted on an admin server:
+//
+//	GET  returns the current LevelSettings.
+//	PUT  (or POST) applies a JSON body such as
+//	     {"verbosity": 5, "vmodule": "worker/*=5", "ttl": "10m"},
+//	     restoring the previous settings once the optional ttl elapses.
+//
+// The handler does no authentication of its own, the admin server is expected
+// to.
+type LevelHandler struct {
+	glog *GlogHandler
+	opts LevelHandlerOptions
+
+	mu         sync.Mutex
+	restore    *time.Timer // Pending restoration of temporary settings
+	restoreAt  time.Time
+	generation uint64        // Incremented with each timer, to tell stale ones
+	previous   LevelSettings // Settings to restore when the timer fires
+}
+
+// NewLevelHandler returns an http.Handler controlling the given GlogHandler.
+func NewLevelHandler(glog *GlogHandler, opts LevelHandlerOptions) *LevelHandler {
+	if opts.Identify == nil {
+		opts.Identify = identifyRequest
+	}
+	return &LevelHandler{
+		glog: glog,
+		opts: opts,
+	}
+}
+
+// ServeHTTP implements http.Handler.
+func (h *LevelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		h.mu.Lock()
+		settings := h.current()
+		h.mu.Unlock()
+		writeJSON(w, http.StatusOK, settings)
+
+	case http.MethodPut, http.MethodPost:
+		var change levelChange
+		dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&change); err != nil {
+			http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+			return
+		}
+		settings, err := h.apply(change, h.opts.Identify(r))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, http.StatusOK, settings)
+
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// Close cancels a pending restoration, leaving the current settings in place.
+func (h *LevelHandler) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.restore != nil {
+		h.restore.Stop()
+		h.restore = nil
+	}
+}
+
+// apply validates and applies a change on behalf of who.
+func (h *LevelHandler) apply(change levelChange, who string) (LevelSettings, error) {
+	var ttl time.Duration
+	if change.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(change.TTL); err != nil || ttl <= 0 {
+			return LevelSettings{}, fmt.Errorf("invalid ttl %q", change.TTL)
+		}
+	}
+	if change.Verbosity != nil && (*change.Verbosity < legacyLevelCrit || *change.Verbosity > legacyLevelTrace) {
+		return LevelSettings{}, fmt.Errorf("verbosity must be between %d and %d", legacyLevelCrit, legacyLevelTrace)
+	}
+	if change.Verbosity == nil && change.Vmodule == nil {
+		return LevelSettings{}, errors.New("nothing to change")
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	before := h.current()
+	if change.Vmodule != nil {
+		if err := h.glog.Vmodule(*change.Vmodule); err != nil {
+			return LevelSettings{}, err
+		}
+	}
+	if change.Verbosity != nil {
+		h.glog.Verbosity(FromLegacyLevel(*change.Verbosity))
+	}
+
+	// A change replaces any pending restoration. Temporary changes made on
+	// top of each other all revert to the settings before the first one.
+	if h.restore != nil {
+		h.restore.Stop()
+		h.restore = nil
+		if ttl == 0 {
+			h.restoreAt = time.Time{}
+		}
+	} else {
+		h.previous = before
+	}
+	if ttl > 0 {
+		h.generation++
+		generation := h.generation
+		h.restoreAt = time.Now().Add(ttl)
+		h.restore = time.AfterFunc(ttl, func() { h.revert(generation) })
+	}
+	after := h.current()
+
+	h.logger().Warn("Log verbosity changed", "by", who,
+		"verbosity", after.Verbosity, "vmodule", after.Vmodule,
+		"previous.verbosity", before.Verbosity, "previous.vmodule", before.Vmodule,
+		"ttl", ttl)
+	return after, nil
+}
+
+// revert restores the settings saved before a temporary change, unless the
+// timer of the given generation was stopped or replaced meanwhile: it may
+// fire while a change stopping it waits for the lock.
+func (h *LevelHandler) revert(generation uint64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.restore == nil || h.generation != generation {
+		return
+	}
+	h.restore, h.restoreAt = nil, time.Time{}
+
+	// The previous ruleset was accepted before, it can't fail now.
+	h.glog.Vmodule(h.previous.Vmodule)
+	h.glog.Verbosity(FromLegacyLevel(h.previous.Verbosity))
+
+	h.logger().Warn("Log verbosity restored", "verbosity", h.previous.Verbosity, "vmodule", h.previous.Vmodule)
+}
+
+// current returns the settings of the handler. It must be called with the lock
+// held.
+func (h *LevelHandler) current() LevelSettings {
+	level := h.glog.GetVerbosity()
+	settings := LevelSettings{
+		Verbosity: toLegacyLevel(level),
+		Level:     LevelString(level),
+		Vmodule:   h.glog.GetVmodule(),
+	}
+	if h.restore != nil {
+		restoreAt := h.restoreAt
+		settings.RestoreAt = &restoreAt
+	}
+	return settings
+}
+
+func (h *LevelHandler) logger() Logger {
+	if h.opts.Logger != nil {
+		return h.opts.Logger
+	}
+	return Root()
+}
+
+// toLegacyLevel is the inverse of FromLegacyLevel, rounding custom levels to
+// the closest more verbose legacy level.
+func toLegacyLevel(lvl slog.Level) int {
+	switch {
+	case lvl >= LevelCrit:
+		return legacyLevelCrit
+	case lvl >= slog.LevelError:
+		return legacyLevelError
+	case lvl >= slog.LevelWarn:
+		return legacyLevelWarn
+	case lvl >= slog.LevelInfo:
+		return legacyLevelInfo
+	case lvl >= slog.LevelDebug:
+		return legacyLevelDebug
+	default:
+		return legacyLevelTrace
+	}
+}
+
+// identifyRequest names the origin of a request by its basic auth user, if
+// any, and its remote address.
+func identifyRequest(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if user, _, ok := r.BasicAuth(); ok {
+		return user + "@" + host
+	}
+	return host
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,245 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+)
+
+// defaultAsyncBufferSize is the number of records an AsyncHandler buffers
+// when no size is configured.
+const defaultAsyncBufferSize = 1024
+
+// OverflowPolicy defines what an AsyncHandler does with a record when its
+// buffer is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock makes the caller wait until there is room in the buffer.
+	OverflowBlock OverflowPolicy = iota
+
+	// OverflowDropOldest discards the oldest buffered record to make room.
+	OverflowDropOldest
+
+	// OverflowDropBelow discards records below AsyncOptions.DropLevel and
+	// blocks for the others.
+	OverflowDropBelow
+)
+
+// AsyncOptions configures an AsyncHandler.
+type AsyncOptions struct {
+	// BufferSize is the maximum number of records waiting to be written.
+	// Defaults to 1024.
+	BufferSize int
+
+	// Overflow is the policy applied when the buffer is full.
+	Overflow OverflowPolicy
+
+	// DropLevel is the level under which records are dropped on overflow
+	// with OverflowDropBelow.
+	DropLevel slog.Level
+}
+
+// asyncEntry is a buffered record along with the handler it's destined to,
+// which carries the attributes and groups of the logger it came from.
+type asyncEntry struct {
+	h slog.Handler
+	r slog.Record
+}
+
+// asyncQueue is the ring buffer shared by an AsyncHandler and all the
+// handlers derived from it.
+type asyncQueue struct {
+	opts AsyncOptions
+
+	mu       sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	idle     *sync.Cond
+	ring     []asyncEntry
+	head     int  // Index of the oldest record
+	count    int  // Number of buffered records
+	busy     bool // Whether the writer is handling a batch
+	closed   bool
+
+	dropped atomic.Uint64
+	done    chan struct{}
+}
+
+// AsyncHandler is a slog.Handler that buffers records and hands them to the
+// wrapped handler on a background goroutine, so slow outputs don't slow down
+// the caller. Records at LevelCrit and above are flushed before Handle
+// returns, so they make it out before Crit exits the process.
+type AsyncHandler struct {
+	origin slog.Handler
+	queue  *asyncQueue
+}
+
+// NewAsyncHandler returns a handler buffering records for h. Call Close on
+// shutdown to write out the buffered records.
+func NewAsyncHandler(h slog.Handler, opts AsyncOptions) *AsyncHandler {
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = defaultAsyncBufferSize
+	}
+	q := &asyncQueue{
+		opts: opts,
+		ring: make([]asyncEntry, opts.BufferSize),
+		done: make(chan struct{}),
+	}
+	q.notEmpty = sync.NewCond(&q.mu)
+	q.notFull = sync.NewCond(&q.mu)
+	q.idle = sync.NewCond(&q.mu)
+
+	go q.loop()
+
+	return &AsyncHandler{
+		origin: h,
+		queue:  q,
+	}
+}
+
+// Enabled implements slog.Handler, deferring to the wrapped handler.
+func (h *AsyncHandler) Enabled(ctx context.Context, lvl slog.Level) bool {
+	return h.origin.Enabled(ctx, lvl)
+}
+
+// Handle implements slog.Handler, buffering the record according to the
+// overflow policy. Once the handler is closed, records are written
+// synchronously.
+func (h *AsyncHandler) Handle(ctx context.Context, r slog.Record) error {
+	if !h.queue.push(asyncEntry{h.origin, r.Clone()}) {
+		return h.origin.Handle(ctx, r)
+	}
+	if r.Level >= LevelCrit {
+		h.Flush()
+	}
+	return nil
+}
+
+// WithAttrs implements slog.Handler. The returned handler shares the buffer
+// of the receiver.
+func (h *AsyncHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &AsyncHandler{
+		origin: h.origin.WithAttrs(attrs),
+		queue:  h.queue,
+	}
+}
+
+// WithGroup implements slog.Handler. The returned handler shares the buffer
+// of the receiver.
+func (h *AsyncHandler) WithGroup(name string) slog.Handler {
+	return &AsyncHandler{
+		origin: h.origin.WithGroup(name),
+		queue:  h.queue,
+	}
+}
+
+// Dropped returns the number of records discarded because the buffer was
+// full.
+func (h *AsyncHandler) Dropped() uint64 {
+	return h.queue.dropped.Load()
+}
+
+// Flush waits until every record buffered so far has been written.
+func (h *AsyncHandler) Flush() {
+	q := h.queue
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for q.count > 0 || q.busy {
+		q.idle.Wait()
+	}
+}
+
+// Close writes out the buffered records and stops the background goroutine.
+// It affects all the handlers sharing the buffer. Records handled after
+// Close are written synchronously.
+func (h *AsyncHandler) Close() error {
+	q := h.queue
+
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		q.notEmpty.Broadcast()
+		q.notFull.Broadcast()
+	}
+	q.mu.Unlock()
+
+	<-q.done
+	return nil
+}
+
+// push adds an entry to the ring buffer. It reports false if the queue is
+// closed and the entry must be handled by the caller.
+func (q *asyncQueue) push(e asyncEntry) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for !q.closed && q.count == len(q.ring) {
+		switch {
+		case q.opts.Overflow == OverflowDropOldest:
+			q.ring[q.head] = asyncEntry{}
+			q.head = (q.head + 1) % len(q.ring)
+			q.count--
+			q.dropped.Add(1)
+
+		case q.opts.Overflow == OverflowDropBelow && e.r.Level < q.opts.DropLevel:
+			q.dropped.Add(1)
+			return true
+
+		default:
+			q.notFull.Wait()
+		}
+	}
+	if q.closed {
+		return false
+	}
+	q.ring[(q.head+q.count)%len(q.ring)] = e
+	q.count++
+	q.notEmpty.Signal()
+	return true
+}
+
+// loop writes out the buffered records until the queue is closed and empty.
+func (q *asyncQueue) loop() {
+	defer close(q.done)
+
+	batch := make([]asyncEntry, 0, len(q.ring))
+	for {
+		q.mu.Lock()
+		for q.count == 0 && !q.closed {
+			q.notEmpty.Wait()
+		}
+		if q.count == 0 && q.closed {
+			q.idle.Broadcast()
+			q.mu.Unlock()
+			return
+		}
+		// Take everything that is buffered in one go to keep the lock
+		// hold times short.
+		for q.count > 0 {
+			batch = append(batch, q.ring[q.head])
+			q.ring[q.head] = asyncEntry{}
+			q.head = (q.head + 1) % len(q.ring)
+			q.count--
+		}
+		q.busy = true
+		q.notFull.Broadcast()
+		q.mu.Unlock()
+
+		for _, e := range batch {
+			e.h.Handle(context.Background(), e.r)
+		}
+		clear(batch)
+		batch = batch[:0]
+
+		q.mu.Lock()
+		q.busy = false
+		if q.count == 0 {
+			q.idle.Broadcast()
+		}
+		q.mu.Unlock()
+	}
+}
@@ -0,0 +1,455 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// auditCheckpointMsg is the message of the checkpoint records.
+const auditCheckpointMsg = "audit checkpoint"
+
+var (
+	// ErrAuditFormat is returned when a line is not an audit record.
+	ErrAuditFormat = errors.New("malformed audit record")
+
+	// ErrAuditHash is returned when a record was edited after being written.
+	ErrAuditHash = errors.New("audit record hash mismatch")
+
+	// ErrAuditChain is returned when records were deleted, inserted or
+	// reordered.
+	ErrAuditChain = errors.New("audit hash chain broken")
+
+	// ErrAuditSignature is returned when a checkpoint signature is invalid.
+	ErrAuditSignature = errors.New("invalid audit checkpoint signature")
+)
+
+// auditGenesis is the previous hash of the first record of a chain.
+var auditGenesis = AuditHead{Hash: hex.EncodeToString(make([]byte, sha256.Size))}
+
+// auditEnvelope matches the fields the audit handler wraps records with.
+// Checkpoints carry theirs right after "prev", where the fields of a record,
+// starting with its time, level and message, can't appear.
+var (
+	auditPrefix           = regexp.MustCompile(`^\{"seq":([0-9]+),"prev":"([0-9a-f]{64})",`)
+	auditCheckpointPrefix = regexp.MustCompile(`^\{"seq":[0-9]+,"prev":"[0-9a-f]{64}","checkpoint":\{`)
+	auditSuffix           = regexp.MustCompile(`,"hash":"([0-9a-f]{64})"\}$`)
+)
+
+// auditCheckpointField is the "checkpoint" field of checkpoint records.
+type auditCheckpointField struct {
+	Seq  uint64 `json:"seq"`
+	Hash string `json:"hash"`
+	KID  string `json:"kid"`
+	Sig  string `json:"sig"`
+}
+
+// KeyLookup gives access to the keys of a key store by key id, like the
+// keystore package does with PEM encoded RSA keys.
+type KeyLookup interface {
+	PrivateKey(kid string) (string, error)
+	PublicKey(kid string) (string, error)
+}
+
+// AuditHead identifies the last record of a hash chain.
+type AuditHead struct {
+	Seq  uint64 // Sequence number of the record, starting at 1
+	Hash string // Hex encoded hash of the record
+}
+
+// AuditCheckpoint is a signed statement of the head of a chain. Publishing it
+// elsewhere, e.g. anchoring it on chain, also protects the records before it
+// against truncation.
+type AuditCheckpoint struct {
+	Head      AuditHead
+	KeyID     string
+	Signature []byte // RSA PKCS#1 v1.5 signature of the SHA-256 of "seq:hash"
+}
+
+// AuditOptions configures an AuditHandler.
+type AuditOptions struct {
+	// Head continues an existing chain, as reported by VerifyAuditLog on the
+	// file being appended to. The zero value starts a new chain.
+	Head AuditHead
+
+	// Keys and KeyID select the RSA key signing the checkpoints. Checkpoints
+	// are disabled when Keys is nil.
+	Keys  KeyLookup
+	KeyID string
+
+	// CheckpointEvery is the number of records between two checkpoints.
+	// Zero only writes checkpoints when Checkpoint is called.
+	CheckpointEvery int
+
+	// OnCheckpoint, when set, is called with every checkpoint written.
+	OnCheckpoint func(AuditCheckpoint)
+}
+
+// auditChain is the state shared by an AuditHandler and the handlers derived
+// from it.
+type auditChain struct {
+	mu    sync.Mutex
+	wr    io.Writer
+	buf   *bytes.Buffer // Buffer all the formatting handlers write to
+	head  AuditHead
+	since int // Records since the last checkpoint
+
+	opts AuditOptions
+	key  *rsa.PrivateKey
+	root slog.Handler // Formatter without attributes or groups, for checkpoints
+}
+
+// AuditHandler is a slog.Handler writing tamper-evident JSON records. Every
+// line carries its sequence number and the hash of the previous line, and is
+// itself hashed with SHA-256:
+//
+//	{"seq":2,"prev":"<hash of 1>","t":...,"msg":...,"hash":"<hash of this line>"}
+//
+// so editing, deleting or reordering lines breaks the chain. Truncating the
+// end of the log can only be detected with checkpoints kept elsewhere.
+//
+// Checkpoints are records of their own, with the signed head in a
+// "checkpoint" field following "prev". Records logged through the handler
+// can't put a field there, so they can't pass for checkpoints.
+type AuditHandler struct {
+	chain  *auditChain
+	format slog.Handler
+}
+
+// NewAuditHandler returns a handler writing a hash chained audit log to wr.
+func NewAuditHandler(wr io.Writer, opts AuditOptions) (*AuditHandler, error) {
+	if opts.Head.Hash == "" {
+		opts.Head.Hash = auditGenesis.Hash
+	}
+	if _, err := hex.DecodeString(opts.Head.Hash); err != nil || len(opts.Head.Hash) != 2*sha256.Size {
+		return nil, fmt.Errorf("invalid audit head hash %q", opts.Head.Hash)
+	}
+	chain := &auditChain{
+		wr:   wr,
+		buf:  new(bytes.Buffer),
+		head: opts.Head,
+		opts: opts,
+	}
+	if opts.Keys != nil {
+		privatePEM, err := opts.Keys.PrivateKey(opts.KeyID)
+		if err != nil {
+			return nil, fmt.Errorf("looking up checkpoint key: %w", err)
+		}
+		if chain.key, err = parseRSAPrivateKey(privatePEM); err != nil {
+			return nil, fmt.Errorf("parsing checkpoint key: %w", err)
+		}
+	}
+	chain.root = JSONHandler(chain.buf)
+
+	return &AuditHandler{
+		chain:  chain,
+		format: chain.root,
+	}, nil
+}
+
+// Enabled implements slog.Handler. Audit records are never filtered.
+func (h *AuditHandler) Enabled(_ context.Context, _ slog.Level) bool {
+	return true
+}
+
+// Handle implements slog.Handler, appending the record to the chain. A
+// checkpoint follows it when due.
+func (h *AuditHandler) Handle(ctx context.Context, r slog.Record) error {
+	c := h.chain
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.append(ctx, h.format, r, nil); err != nil {
+		return err
+	}
+	c.since++
+	if c.key != nil && c.opts.CheckpointEvery > 0 && c.since >= c.opts.CheckpointEvery {
+		return c.checkpoint(ctx)
+	}
+	return nil
+}
+
+// WithAttrs implements slog.Handler. The returned handler extends the same
+// chain.
+func (h *AuditHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &AuditHandler{
+		chain:  h.chain,
+		format: h.format.WithAttrs(attrs),
+	}
+}
+
+// WithGroup implements slog.Handler. The returned handler extends the same
+// chain.
+func (h *AuditHandler) WithGroup(name string) slog.Handler {
+	return &AuditHandler{
+		chain:  h.chain,
+		format: h.format.WithGroup(name),
+	}
+}
+
+// Head returns the last record written.
+func (h *AuditHandler) Head() AuditHead {
+	h.chain.mu.Lock()
+	defer h.chain.mu.Unlock()
+
+	return h.chain.head
+}
+
+// Checkpoint writes a signed checkpoint of the records written so far.
+func (h *AuditHandler) Checkpoint() error {
+	c := h.chain
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.key == nil {
+		return errors.New("no checkpoint key configured")
+	}
+	return c.checkpoint(context.Background())
+}
+
+// append formats a record, chains it to the head and writes it out. The
+// envelope fields, if any, are written before the ones of the record. It
+// must be called with the lock held.
+func (c *auditChain) append(ctx context.Context, format slog.Handler, r slog.Record, envelope []byte) error {
+	c.buf.Reset()
+	if err := format.Handle(ctx, r); err != nil {
+		return err
+	}
+	record := bytes.TrimSuffix(c.buf.Bytes(), []byte("\n"))
+	if len(record) < 2 || record[0] != '{' {
+		return ErrAuditFormat
+	}
+	seq := c.head.Seq + 1
+
+	line := make([]byte, 0, len(record)+2*sha256.Size+128)
+	line = append(line, `{"seq":`...)
+	line = strconv.AppendUint(line, seq, 10)
+	line = append(line, `,"prev":"`...)
+	line = append(line, c.head.Hash...)
+	line = append(line, `",`...)
+	if len(envelope) > 0 {
+		line = append(line, envelope...)
+		line = append(line, ',')
+	}
+	if len(record) == 2 {
+		line = line[:len(line)-1] // No fields to follow, drop the comma
+	}
+	line = append(line, record[1:]...)
+
+	sum := sha256.Sum256(line)
+	hash := hex.EncodeToString(sum[:])
+
+	line = append(line[:len(line)-1], `,"hash":"`...)
+	line = append(line, hash...)
+	line = append(line, "\"}\n"...)
+
+	if _, err := c.wr.Write(line); err != nil {
+		return err
+	}
+	c.head = AuditHead{Seq: seq, Hash: hash}
+	return nil
+}
+
+// checkpoint signs the head and appends the signature to the chain. It must
+// be called with the lock held.
+func (c *auditChain) checkpoint(ctx context.Context) error {
+	digest := checkpointDigest(c.head)
+	sig, err := rsa.SignPKCS1v15(rand.Reader, c.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return fmt.Errorf("signing audit checkpoint: %w", err)
+	}
+	cp := AuditCheckpoint{
+		Head:      c.head,
+		KeyID:     c.opts.KeyID,
+		Signature: sig,
+	}
+	field, err := json.Marshal(auditCheckpointField{
+		Seq:  cp.Head.Seq,
+		Hash: cp.Head.Hash,
+		KID:  cp.KeyID,
+		Sig:  base64.StdEncoding.EncodeToString(sig),
+	})
+	if err != nil {
+		return err
+	}
+	r := slog.NewRecord(time.Now(), LevelInfo, auditCheckpointMsg, 0)
+	if err := c.append(ctx, c.root, r, append([]byte(`"checkpoint":`), field...)); err != nil {
+		return err
+	}
+	c.since = 0
+
+	if c.opts.OnCheckpoint != nil {
+		c.opts.OnCheckpoint(cp)
+	}
+	return nil
+}
+
+// AuditReport is the outcome of verifying an audit log.
+type AuditReport struct {
+	Head        AuditHead        // Last record of the chain
+	Records     uint64           // Number of records, checkpoints included
+	Checkpoints []AuditHead      // Heads covered by a valid checkpoint
+	Signed      bool             // Whether checkpoint signatures were checked
+	Last        *AuditCheckpoint // Last checkpoint found, if any
+}
+
+// VerifyAuditLog reads an audit log written by an AuditHandler and checks
+// that no record was edited, deleted, inserted or reordered. Checkpoint
+// signatures are checked against keys, unless nil. The first record must
+// continue from the given head, the zero value for a log starting a chain.
+//
+// On failure, the report covers the records up to the offending one and the
+// error names its line.
+func VerifyAuditLog(r io.Reader, from AuditHead, keys KeyLookup) (*AuditReport, error) {
+	if from.Hash == "" {
+		from.Hash = auditGenesis.Hash
+	}
+	report := &AuditReport{Head: from, Signed: keys != nil}
+	publicKeys := make(map[string]*rsa.PublicKey)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 16<<20) // Backtraces make for long lines
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		prefix := auditPrefix.FindSubmatch(line)
+		suffix := auditSuffix.FindSubmatchIndex(line)
+		if prefix == nil || suffix == nil {
+			return report, fmt.Errorf("line %d: %w", n, ErrAuditFormat)
+		}
+		seq, err := strconv.ParseUint(string(prefix[1]), 10, 64)
+		if err != nil {
+			return report, fmt.Errorf("line %d: %w", n, ErrAuditFormat)
+		}
+		if seq != report.Head.Seq+1 || string(prefix[2]) != report.Head.Hash {
+			return report, fmt.Errorf("line %d: %w: record %d follows record %d", n, ErrAuditChain, seq, report.Head.Seq)
+		}
+		body := append(line[:suffix[0]:suffix[0]], '}')
+		sum := sha256.Sum256(body)
+		hash := hex.EncodeToString(sum[:])
+		if hash != string(line[suffix[2]:suffix[3]]) {
+			return report, fmt.Errorf("line %d: %w: record %d", n, ErrAuditHash, seq)
+		}
+
+		// The record is intact, check it if it's a checkpoint.
+		if auditCheckpointPrefix.Match(body) {
+			var rec struct {
+				Checkpoint auditCheckpointField `json:"checkpoint"`
+			}
+			if err := json.Unmarshal(body, &rec); err != nil {
+				return report, fmt.Errorf("line %d: %w: %v", n, ErrAuditFormat, err)
+			}
+			cp := AuditCheckpoint{
+				Head:  AuditHead{Seq: rec.Checkpoint.Seq, Hash: rec.Checkpoint.Hash},
+				KeyID: rec.Checkpoint.KID,
+			}
+			if cp.Head != report.Head {
+				return report, fmt.Errorf("line %d: %w: checkpoint of record %d doesn't match the chain", n, ErrAuditChain, cp.Head.Seq)
+			}
+			if cp.Signature, err = base64.StdEncoding.DecodeString(rec.Checkpoint.Sig); err != nil {
+				return report, fmt.Errorf("line %d: %w", n, ErrAuditSignature)
+			}
+			if keys != nil {
+				if err := verifyCheckpoint(cp, keys, publicKeys); err != nil {
+					return report, fmt.Errorf("line %d: %w", n, err)
+				}
+			}
+			report.Checkpoints = append(report.Checkpoints, cp.Head)
+			report.Last = &cp
+		}
+		report.Head = AuditHead{Seq: seq, Hash: hash}
+		report.Records++
+	}
+	if err := scanner.Err(); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// VerifyCheckpoint checks the signature of a checkpoint, e.g. one anchored
+// elsewhere, against the keys.
+func VerifyCheckpoint(cp AuditCheckpoint, keys KeyLookup) error {
+	return verifyCheckpoint(cp, keys, nil)
+}
+
+func verifyCheckpoint(cp AuditCheckpoint, keys KeyLookup, cache map[string]*rsa.PublicKey) error {
+	key, ok := cache[cp.KeyID]
+	if !ok {
+		publicPEM, err := keys.PublicKey(cp.KeyID)
+		if err != nil {
+			return fmt.Errorf("%w: key %q: %v", ErrAuditSignature, cp.KeyID, err)
+		}
+		if key, err = parseRSAPublicKey(publicPEM); err != nil {
+			return fmt.Errorf("%w: key %q: %v", ErrAuditSignature, cp.KeyID, err)
+		}
+		if cache != nil {
+			cache[cp.KeyID] = key
+		}
+	}
+	digest := checkpointDigest(cp.Head)
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], cp.Signature); err != nil {
+		return fmt.Errorf("%w: checkpoint of record %d", ErrAuditSignature, cp.Head.Seq)
+	}
+	return nil
+}
+
+// checkpointDigest is the digest signed by a checkpoint.
+func checkpointDigest(head AuditHead) [sha256.Size]byte {
+	return sha256.Sum256([]byte(strconv.FormatUint(head.Seq, 10) + ":" + head.Hash))
+}
+
+func parseRSAPrivateKey(privatePEM string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(privatePEM))
+	if block == nil {
+		return nil, errors.New("invalid key: key must be a PEM encoded PKCS1 or PKCS8 key")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("key is not a valid RSA private key")
+	}
+	return key, nil
+}
+
+func parseRSAPublicKey(publicPEM string) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(publicPEM))
+	if block == nil {
+		return nil, errors.New("invalid key: key must be a PEM encoded PKIX public key")
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("key is not a valid RSA public key")
+	}
+	return key, nil
+}
@@ -0,0 +1,73 @@
+// Command auditverify checks that an audit log written by logger.AuditHandler
+// was not tampered with: no record edited, deleted, inserted or reordered, and
+// every checkpoint validly signed.
+//
+// Usage:
+//
+//	auditverify [-keys dir] [-from-seq n -from-hash hex] audit.log
+//
+// The keys directory holds the RSA PEM files of the signing keys, named after
+// their key ids, as loaded by the keystore package.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	keystore "EncrypteDL/EncryrpteID/_observability/keyStore"
+	"EncrypteDL/EncryrpteID/_observability/logger"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "auditverify:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("auditverify", flag.ContinueOnError)
+	keysDir := flags.String("keys", "", "directory of the PEM keys signing the checkpoints")
+	fromSeq := flags.Uint64("from-seq", 0, "sequence number of the record preceding the file, for rotated logs")
+	fromHash := flags.String("from-hash", "", "hash of the record preceding the file, for rotated logs")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("expected one audit log file, got %d", flags.NArg())
+	}
+
+	var keys logger.KeyLookup
+	if *keysDir != "" {
+		ks := keystore.New()
+		if err := ks.LoadRSAKeys(os.DirFS(*keysDir)); err != nil {
+			return fmt.Errorf("loading keys: %w", err)
+		}
+		keys = ks
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	report, err := logger.VerifyAuditLog(f, logger.AuditHead{Seq: *fromSeq, Hash: *fromHash}, keys)
+	if err != nil {
+		return fmt.Errorf("%s: verified %d records before failing: %w", flags.Arg(0), report.Records, err)
+	}
+
+	fmt.Fprintf(out, "%s: %d records intact\n", flags.Arg(0), report.Records)
+	fmt.Fprintf(out, "head: seq=%d hash=%s\n", report.Head.Seq, report.Head.Hash)
+	switch {
+	case report.Last == nil:
+		fmt.Fprintln(out, "checkpoints: none, truncation can't be detected")
+	case !report.Signed:
+		fmt.Fprintf(out, "checkpoints: %d, signatures not checked (no -keys)\n", len(report.Checkpoints))
+	default:
+		fmt.Fprintf(out, "checkpoints: %d, signatures valid, last covers seq=%d\n", len(report.Checkpoints), report.Last.Head.Seq)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"EncrypteDL/EncryrpteID/_observability/logger"
+)
+
+// auditLog writes an audit log of a few records and returns its path.
+func auditLog(t *testing.T) string {
+	t.Helper()
+	var out bytes.Buffer
+	handler, err := logger.NewAuditHandler(&out, logger.AuditOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := logger.NewLogger(handler)
+	for _, did := range []string{"did:example:1", "did:example:2", "did:example:3"} {
+		log.Info("did updated", "did", did, "actor", "admin")
+	}
+	path := filepath.Join(t.TempDir(), "audit.log")
+	if err := os.WriteFile(path, out.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// tamper replaces old with new in the file.
+func tamper(t *testing.T, path, old, new string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte(old)) {
+		t.Fatalf("%q not found in %s", old, data)
+	}
+	if err := os.WriteFile(path, bytes.Replace(data, []byte(old), []byte(new), 1), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	path := auditLog(t)
+	var out bytes.Buffer
+	if err := run([]string{path}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "3 records intact") {
+		t.Errorf("unexpected report:\n%s", out.String())
+	}
+
+	tamper(t, path, "did:example:2", "did:example:9")
+	out.Reset()
+	if err := run([]string{path}, &out); err == nil || !strings.Contains(err.Error(), "verified 1 records") {
+		t.Errorf("expected the edited record to fail verification, got %v", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("expected no report for a tampered log, got:\n%s", out.String())
+	}
+}
+
+// TestExitStatus runs the command in a subprocess, the test binary running
+// main when AUDITVERIFY_LOG is set.
+func TestExitStatus(t *testing.T) {
+	if path := os.Getenv("AUDITVERIFY_LOG"); path != "" {
+		os.Args = []string{"auditverify", path}
+		main()
+		return
+	}
+
+	path := auditLog(t)
+	verify := func() (string, error) {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestExitStatus$")
+		cmd.Env = append(os.Environ(), "AUDITVERIFY_LOG="+path)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		err := cmd.Run()
+		return stderr.String(), err
+	}
+	if stderr, err := verify(); err != nil {
+		t.Fatalf("expected an intact log to exit with 0, got %v: %s", err, stderr)
+	}
+
+	// Deleting a record breaks the chain.
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	tamper(t, path, lines[1], "")
+
+	stderr, err := verify()
+	var exit *exec.ExitError
+	if !errors.As(err, &exit) || exit.ExitCode() != 1 {
+		t.Fatalf("expected a tampered log to exit with 1, got %v", err)
+	}
+	if !strings.HasPrefix(stderr, "auditverify: "+path) {
+		t.Errorf("unexpected error output %q", stderr)
+	}
+}
@@ -0,0 +1,243 @@
+// Command logpretty re-renders JSON and logfmt logs, as written by
+// logger.JSONHandler and logger.LogfmtHandler, in the colored terminal
+// format. Lines that aren't log records are printed as they are.
+//
+// Usage:
+//
+//	logpretty [flags] [file ...]
+//
+// With no file, or "-", it reads stdin. Flags:
+//
+//	-level warn          minimum level (name or 0-5 verbosity)
+//	-msg text            message substring
+//	-attr key=value      attribute value, repeatable, dotted keys for groups
+//	-since 15m           RFC 3339 time or duration before now
+//	-until 2024-...      RFC 3339 time or duration before now
+//	-f                   follow the file as it grows, across rotations
+//	-color auto          always, never or auto (when stdout is a terminal)
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"EncrypteDL/EncryrpteID/_observability/logger"
+)
+
+// pollInterval is how often a followed file is checked for new lines.
+const pollInterval = 250 * time.Millisecond
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := run(ctx, os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "logpretty:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, stdin io.Reader, stdout *os.File) error {
+	var (
+		flags  = flag.NewFlagSet("logpretty", flag.ContinueOnError)
+		level  = flags.String("level", "", "minimum level, by name or 0-5 verbosity")
+		msg    = flags.String("msg", "", "message substring")
+		since  = flags.String("since", "", "RFC 3339 time or duration before now")
+		until  = flags.String("until", "", "RFC 3339 time or duration before now")
+		follow = flags.Bool("f", false, "follow the file as it grows")
+		color  = flags.String("color", "auto", "always, never or auto")
+		query  logger.RingQuery
+	)
+	flags.Func("attr", "key=value attribute filter, repeatable", func(s string) error {
+		key, value, found := strings.Cut(s, "=")
+		if !found || key == "" {
+			return errors.New("expect key=value")
+		}
+		if query.Attrs == nil {
+			query.Attrs = make(map[string]string)
+		}
+		query.Attrs[key] = value
+		return nil
+	})
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var err error
+	if *level != "" {
+		lvl, err := logger.ParseLevel(*level)
+		if err != nil {
+			return err
+		}
+		query.Level = lvl
+	}
+	query.Message = *msg
+	now := time.Now()
+	if query.Since, err = logger.ParseTime(*since, now); err != nil {
+		return fmt.Errorf("invalid -since: %w", err)
+	}
+	if query.Until, err = logger.ParseTime(*until, now); err != nil {
+		return fmt.Errorf("invalid -until: %w", err)
+	}
+
+	var useColor bool
+	switch *color {
+	case "always":
+		useColor = true
+	case "never":
+	case "auto":
+		useColor = isTerminal(stdout) && os.Getenv("NO_COLOR") == ""
+	default:
+		return fmt.Errorf("invalid -color %q", *color)
+	}
+
+	files := flags.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	if *follow && len(files) != 1 {
+		return errors.New("-f follows a single file")
+	}
+	out := bufio.NewWriter(stdout)
+	defer out.Flush()
+
+	p := &printer{
+		query: query,
+		term:  logger.NewTerminalHandler(out, useColor),
+		out:   out,
+	}
+	for _, name := range files {
+		if name == "-" {
+			// Stdin is followed naturally, until the writer closes it.
+			if err := p.copy(ctx, stdin, true); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := p.file(ctx, name, *follow); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printer filters and renders log lines.
+type printer struct {
+	query logger.RingQuery
+	term  *logger.TerminalHandler
+	out   *bufio.Writer
+}
+
+// line renders a single line, or prints it as is if it's not a record.
+func (p *printer) line(ctx context.Context, line []byte) {
+	r, err := logger.ParseRecord(line)
+	if err != nil {
+		// Filters can't apply to foreign lines, only show them unfiltered.
+		if p.query.Level == nil && p.query.Message == "" && len(p.query.Attrs) == 0 &&
+			p.query.Since.IsZero() && p.query.Until.IsZero() {
+			p.out.Write(line)
+		}
+		return
+	}
+	if p.query.Match(r) {
+		p.term.Handle(ctx, r)
+	}
+}
+
+// copy renders all the lines of a reader. Output is flushed after every line
+// when the reader is interactive.
+func (p *printer) copy(ctx context.Context, r io.Reader, flush bool) error {
+	br := bufio.NewReaderSize(r, 64<<10)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			p.line(ctx, line)
+			if flush && br.Buffered() == 0 {
+				p.out.Flush()
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// file renders a file, then keeps rendering the lines appended to it when
+// following. A truncated file is read again from the start and a rotated one
+// is reopened by name.
+func (p *printer) file(ctx context.Context, name string, follow bool) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer func() { f.Close() }()
+
+	if !follow {
+		return p.copy(ctx, f, false)
+	}
+	var (
+		br      = bufio.NewReaderSize(f, 64<<10)
+		partial []byte // Incomplete last line, waiting for its end
+		offset  int64
+	)
+	for {
+		line, err := br.ReadBytes('\n')
+		offset += int64(len(line))
+		if err == nil {
+			p.line(ctx, append(partial, line...))
+			partial = partial[:0]
+			continue
+		}
+		if !errors.Is(err, io.EOF) {
+			return err
+		}
+		partial = append(partial, line...)
+		p.out.Flush()
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(pollInterval):
+		}
+		// Start over if the file was truncated or replaced.
+		current, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		if latest, err := os.Stat(name); err == nil && !os.SameFile(current, latest) {
+			if reopened, err := os.Open(name); err == nil {
+				// Drain what was written to the old file before the rotation.
+				p.copy(ctx, io.MultiReader(bytes.NewReader(partial), br), false)
+				f.Close()
+				f, br, partial, offset = reopened, bufio.NewReaderSize(reopened, 64<<10), partial[:0], 0
+			}
+			continue
+		}
+		if current.Size() < offset {
+			if _, err := f.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			br.Reset(f)
+			partial, offset = partial[:0], 0
+		}
+	}
+}
+
+// isTerminal reports whether the file is a character device, such as a
+// terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
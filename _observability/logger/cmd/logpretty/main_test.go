@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"EncrypteDL/EncryrpteID/_observability/logger"
+)
+
+// record formats a record with a handler, as found in a log file.
+func record(t *testing.T, newHandler func(w io.Writer) slog.Handler, at time.Time, level slog.Level, msg string, attrs ...slog.Attr) string {
+	t.Helper()
+	var out bytes.Buffer
+	r := slog.NewRecord(at, level, msg, 0)
+	r.AddAttrs(attrs...)
+	if err := newHandler(&out).Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+// pretty runs the command on the lines and returns its output.
+func pretty(t *testing.T, lines []string, args ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.log")
+	if err := os.WriteFile(in, []byte(strings.Join(lines, "")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stdout, err := os.Create(filepath.Join(dir, "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdout.Close()
+
+	if err := run(context.Background(), append(args, in), nil, stdout); err != nil {
+		t.Fatal(err)
+	}
+	out, err := os.ReadFile(stdout.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestRun(t *testing.T) {
+	now := time.Now()
+	lines := []string{
+		record(t, logger.JSONHandler, now, slog.LevelInfo, "did resolved", slog.String("did", "did:example:1")),
+		record(t, logger.JSONHandler, now, slog.LevelWarn, "slow resolution", slog.String("did", "did:example:1")),
+		record(t, logger.LogfmtHandler, now, slog.LevelError, "resolution failed", slog.Group("req", slog.String("did", "did:example:1"))),
+		record(t, logger.LogfmtHandler, now, slog.LevelError, "resolution failed", slog.String("did", "did:example:2")),
+		record(t, logger.JSONHandler, now.Add(-2*time.Hour), slog.LevelError, "stale failure", slog.String("did", "did:example:1")),
+		"panic: not a record\n",
+	}
+
+	// Without filters, every line is printed, the records in the terminal
+	// format.
+	out := pretty(t, lines, "-color", "never")
+	for _, want := range []string{"INFO", "did resolved", "did=did:example:1", "stale failure", "panic: not a record\n"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output misses %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, `"msg"`) || strings.Contains(out, "msg=") {
+		t.Errorf("records not re-rendered:\n%s", out)
+	}
+
+	// Filters apply to both formats and drop the foreign lines.
+	out = pretty(t, lines, "-color", "never", "-level", "warn", "-msg", "resol", "-since", "1h", "-attr", "did=did:example:1")
+	if have := strings.Count(out, "\n"); have != 1 || !strings.Contains(out, "slow resolution") {
+		t.Errorf("expected only the warning to match:\n%s", out)
+	}
+	out = pretty(t, lines, "-color", "never", "-attr", "req.did=did:example:1")
+	if have := strings.Count(out, "\n"); have != 1 || !strings.Contains(out, "req.did=did:example:1") {
+		t.Errorf("expected only the grouped attribute to match:\n%s", out)
+	}
+	out = pretty(t, lines, "-color", "always", "-level", "error", "-until", now.Add(-time.Hour).Format(time.RFC3339))
+	if have := strings.Count(out, "\n"); have != 1 || !strings.Contains(out, "stale failure") || !strings.Contains(out, "\x1b[") {
+		t.Errorf("expected only the stale failure, colored:\n%q", out)
+	}
+}
+
+func TestRunFlags(t *testing.T) {
+	for _, args := range [][]string{
+		{"-since", "yesterday"},
+		{"-level", "loud"},
+		{"-attr", "did"},
+		{"-color", "sometimes"},
+		{"-f", "a.log", "b.log"},
+	} {
+		if err := run(context.Background(), args, nil, os.Stdout); err == nil {
+			t.Errorf("expected %v to be rejected", args)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// maxStackDepth is the number of frames captured by WithStack and Errorf.
+const maxStackDepth = 32
+
+// ErrorFielder is implemented by error types carrying typed fields, e.g. the
+// DID or the block number a failure relates to. The fields are logged along
+// with the error message.
+type ErrorFielder interface {
+	ErrorFields() []slog.Attr
+}
+
+// stackError attaches the call stack of its creation to an error.
+type stackError struct {
+	err error
+	pcs []uintptr
+}
+
+func (e *stackError) Error() string { return e.err.Error() }
+func (e *stackError) Unwrap() error { return e.err }
+
+// fieldsError attaches fields to an error.
+type fieldsError struct {
+	err   error
+	attrs []slog.Attr
+}
+
+func (e *fieldsError) Error() string            { return e.err.Error() }
+func (e *fieldsError) Unwrap() error            { return e.err }
+func (e *fieldsError) ErrorFields() []slog.Attr { return e.attrs }
+
+// WithStack returns err annotated with the stack of the caller, logged along
+// with it by the JSON handler. It returns err as is if it's nil or already
+// carries a stack.
+func WithStack(err error) error {
+	if err == nil || hasStack(err) {
+		return err
+	}
+	return &stackError{err: err, pcs: callers()}
+}
+
+// Errorf is fmt.Errorf annotating the error with the stack of the caller,
+// unless an error it wraps already carries one. Use it at wrap sites:
+//
+//	return logger.Errorf("resolving %s: %w", did, err)
+func Errorf(format string, args ...any) error {
+	err := fmt.Errorf(format, args...)
+	if hasStack(err) {
+		return err
+	}
+	return &stackError{err: err, pcs: callers()}
+}
+
+// WithFields returns err annotated with fields, given as key/value pairs or
+// slog.Attr like the Logger methods take. It returns nil if err is nil.
+func WithFields(err error, args ...any) error {
+	if err == nil {
+		return nil
+	}
+	var r slog.Record
+	r.Add(args...)
+
+	attrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(attr slog.Attr) bool {
+		attrs = append(attrs, attr)
+		return true
+	})
+	return &fieldsError{err: err, attrs: attrs}
+}
+
+// callers returns the program counters of the caller of the function calling
+// it.
+func callers() []uintptr {
+	var pcs [maxStackDepth]uintptr
+	n := runtime.Callers(3, pcs[:])
+	return pcs[:n:n]
+}
+
+func hasStack(err error) bool {
+	var se *stackError
+	return errors.As(err, &se)
+}
+
+// errorObject is the structured form of an error in JSON output.
+type errorObject struct {
+	Msg    string         `json:"msg"`
+	Type   string         `json:"type"`
+	Fields map[string]any `json:"fields,omitempty"`
+	Causes []errorCause   `json:"causes,omitempty"`
+	Stack  []string       `json:"stack,omitempty"`
+}
+
+// errorCause is an error unwrapped from a logged error.
+type errorCause struct {
+	Msg  string `json:"msg"`
+	Type string `json:"type"`
+}
+
+// structuredError returns the structured form of an error: its message, its
+// type, the chain of errors it wraps, the fields of all of them and the
+// deepest stack attached to them.
+func structuredError(err error) errorObject {
+	obj := errorObject{Msg: err.Error()}
+
+	var fields []slog.Attr
+	walkErrors(err, func(e error) {
+		if fe, ok := e.(ErrorFielder); ok {
+			fields = append(fields, fe.ErrorFields()...)
+		}
+		switch e := e.(type) {
+		case *stackError:
+			obj.Stack = formatStack(e.pcs) // Keep the deepest, closest to the origin
+			return
+		case *fieldsError:
+			return
+		}
+		if obj.Type == "" {
+			obj.Type = errorType(e)
+			return
+		}
+		obj.Causes = append(obj.Causes, errorCause{Msg: e.Error(), Type: errorType(e)})
+	})
+	if len(fields) > 0 {
+		obj.Fields = attrsToMap(fields)
+	}
+	return obj
+}
+
+// compactError renders an error on a single line, with its fields if it has
+// any: "msg [key=value ...]".
+func compactError(err error) string {
+	var fields []slog.Attr
+	walkErrors(err, func(e error) {
+		if fe, ok := e.(ErrorFielder); ok {
+			fields = append(fields, fe.ErrorFields()...)
+		}
+	})
+	if len(fields) == 0 {
+		return err.Error()
+	}
+	var b strings.Builder
+	b.WriteString(err.Error())
+	b.WriteString(" [")
+	var flat []slog.Attr
+	for _, attr := range fields {
+		flat = appendFlattenedAttr(flat, "", attr)
+	}
+	for i, attr := range flat {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(attr.Key)
+		b.WriteByte('=')
+		b.Write(FormatSlogValue(attr.Value, nil))
+	}
+	b.WriteByte(']')
+	return b.String()
+}
+
+// walkErrors calls fn on err and every error it wraps, depth first.
+func walkErrors(err error, fn func(error)) {
+	var walk func(error, int)
+	walk = func(err error, depth int) {
+		if err == nil || depth > 64 {
+			return
+		}
+		fn(err)
+		switch u := err.(type) {
+		case interface{ Unwrap() error }:
+			walk(u.Unwrap(), depth+1)
+		case interface{ Unwrap() []error }:
+			for _, e := range u.Unwrap() {
+				walk(e, depth+1)
+			}
+		}
+	}
+	walk(err, 0)
+}
+
+// errorType names the type of an error, e.g. "*fs.PathError".
+func errorType(err error) string {
+	return fmt.Sprintf("%T", err)
+}
+
+// formatStack renders program counters as "function file:line" frames.
+func formatStack(pcs []uintptr) []string {
+	frames := runtime.CallersFrames(pcs)
+	var res []string
+	for {
+		frame, more := frames.Next()
+		if frame.Function != "" {
+			res = append(res, frame.Function+" "+frame.File+":"+strconv.Itoa(frame.Line))
+		}
+		if !more {
+			return res
+		}
+	}
+}
+
+// attrsToMap converts attributes to a map marshalled as a JSON object.
+func attrsToMap(attrs []slog.Attr) map[string]any {
+	res := make(map[string]any, len(attrs))
+	for _, attr := range attrs {
+		v := attr.Value.Resolve()
+		switch v.Kind() {
+		case slog.KindGroup:
+			res[attr.Key] = attrsToMap(v.Group())
+		default:
+			if err, ok := v.Any().(error); ok {
+				res[attr.Key] = err.Error()
+			} else {
+				res[attr.Key] = v.Any()
+			}
+		}
+	}
+	return res
+}
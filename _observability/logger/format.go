@@ -6,6 +6,7 @@ import (
 	"log/slog"
 	"math/big"
 	"reflect"
+	"slices"
 	"strconv"
 	"time"
 	"unicode/utf8"
@@ -106,16 +107,87 @@ func (h *TerminalHandler) formatAttributes(buf *bytes.Buffer, r slog.Record, col
 	}
 	var n = 0
 	var nAttrs = len(h.attrs) + r.NumAttrs()
+
+	// Groups need to be flattened into dotted keys first, which also changes
+	// the number of attributes. Skip the extra work in the common case.
+	var grouped []slog.Attr
+	if h.group != "" || hasGroupAttr(r) {
+		grouped = make([]slog.Attr, 0, r.NumAttrs())
+		r.Attrs(func(attr slog.Attr) bool {
+			grouped = appendFlattenedAttr(grouped, h.group, attr)
+			return true
+		})
+		nAttrs = len(h.attrs) + len(grouped)
+	}
+
 	for _, attr := range h.attrs {
 		writeAttr(attr, n == 0, n == nAttrs-1)
 		n++
 	}
+	if grouped != nil {
+		for _, attr := range grouped {
+			writeAttr(attr, n == 0, n == nAttrs-1)
+			n++
+		}
+	} else {
+		r.Attrs(func(attr slog.Attr) bool {
+			writeAttr(attr, n == 0, n == nAttrs-1)
+			n++
+			return true
+		})
+	}
+	buf.WriteByte('\n')
+}
+
+// hasGroupAttr reports whether any of the record's attributes is a group.
+func hasGroupAttr(r slog.Record) bool {
+	found := false
 	r.Attrs(func(attr slog.Attr) bool {
-		writeAttr(attr, n == 0, n == nAttrs-1)
-		n++
+		found = attr.Value.Kind() == slog.KindGroup
+		return !found
+	})
+	return found
+}
+
+// appendFlattenedAttr appends attr to dst with its key qualified by prefix.
+// Group values are expanded recursively into dotted keys, inline groups (the
+// ones with an empty key) are merged into their parent and empty groups are
+// dropped, mirroring how the slog handlers treat them.
+func appendFlattenedAttr(dst []slog.Attr, prefix string, attr slog.Attr) []slog.Attr {
+	if attr.Value.Kind() == slog.KindLogValuer {
+		attr.Value = attr.Value.Resolve()
+	}
+	if attr.Value.Kind() != slog.KindGroup {
+		attr.Key = prefix + attr.Key
+		return append(dst, attr)
+	}
+	if attr.Key != "" {
+		prefix += attr.Key + "."
+	}
+	for _, ga := range attr.Value.Group() {
+		dst = appendFlattenedAttr(dst, prefix, ga)
+	}
+	return dst
+}
+
+// flattenRecord returns the attributes of a handler followed by the ones of a
+// record, flattened to dotted keys.
+func flattenRecord(attrs []slog.Attr, group string, r slog.Record) []slog.Attr {
+	flat := slices.Clip(attrs)
+	r.Attrs(func(attr slog.Attr) bool {
+		flat = appendFlattenedAttr(flat, group, attr)
 		return true
 	})
-	buf.WriteByte('\n')
+	return flat
+}
+
+// appendFlattenedAttrs appends attrs to dst, flattened to dotted keys.
+func appendFlattenedAttrs(dst []slog.Attr, group string, attrs []slog.Attr) []slog.Attr {
+	dst = slices.Clip(dst)
+	for _, attr := range attrs {
+		dst = appendFlattenedAttr(dst, group, attr)
+	}
+	return dst
 }
 
 // FormatSlogValue formats a slog.Value for serialization to terminal.
@@ -161,7 +233,7 @@ func FormatSlogValue(v slog.Value, tmp []byte) (result []byte) {
 	case *uint256.Int: // Need to be before fmt.Stringer-clause
 		return appendU256(tmp, v)
 	case error:
-		return appendEscapeString(tmp, v.Error())
+		return appendEscapeString(tmp, compactError(v))
 	case TerminalStringer:
 		return appendEscapeString(tmp, v.TerminalString())
 	case fmt.Stringer:
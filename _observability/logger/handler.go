@@ -6,11 +6,12 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
-	"maps"
 	"math/big"
+	"path/filepath"
 	"reflect"
 	"regexp"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -23,34 +24,55 @@ import (
 // errVmoduleSyntax is returned when a user vmodule pattern is invalid.
 var errVmoduleSyntax = errors.New("expect comma-separated list of filename=N")
 
+// errTraceSyntax is returned when a user backtrace pattern is invalid.
+var errTraceSyntax = errors.New("expect file.go:234")
+
 // GlogHandler is a log handler that mimics the filtering features of Google's
 // glog logger: setting global log levels; overriding with callsite pattern
 // matches; and requesting backtraces at certain positions.
 type GlogHandler struct {
 	origin slog.Handler // The origin handler this wraps
 
+	// rooted rebuilds the origin with an attribute added at the root, before
+	// the groups opened by WithGroup. Nil until a group is opened.
+	rooted func(attr slog.Attr) slog.Handler
+
+	*glogFilter // Filtering state shared with the derived handlers
+}
+
+// glogFilter is the filtering state of a GlogHandler. It's shared by all the
+// handlers derived from one with WithAttrs and WithGroup, so changing the
+// verbosity also applies to the loggers created from it.
+type glogFilter struct {
 	level    atomic.Int32 // Current log level, atomically accessible
 	override atomic.Bool  // Flag whether overrides are used, atomically accessible
 
 	patterns  []pattern              // Current list of patterns to override with
+	ruleset   string                 // Vmodule ruleset the patterns were built from
 	siteCache map[uintptr]slog.Level // Cache of callsite pattern evaluations
 	location  string                 // file:line location where to do a stackdump at
-	lock      sync.RWMutex           // Lock protecting the override pattern list
+	lock      sync.RWMutex           // Lock protecting the override pattern list and location
+
+	backtrace  atomic.Bool  // Flag whether a backtrace location is set, atomically accessible
+	stacks     atomic.Bool  // Flag whether stacks are captured by level, atomically accessible
+	stackLevel atomic.Int32 // Level from which stacks are captured, atomically accessible
 }
 
 // NewGlogHandler creates a new log handler with filtering functionality similar
 // to Google's glog logger. The returned handler implements Handler.
 func NewGlogHandler(h slog.Handler) *GlogHandler {
 	return &GlogHandler{
-		origin: h,
+		origin:     h,
+		glogFilter: new(glogFilter),
 	}
 }
 
 // pattern contains a filter for the Vmodule option, holding a verbosity level
-// and a file pattern to match.
+// and a file or function pattern to match.
 type pattern struct {
-	pattern *regexp.Regexp
-	level   slog.Level
+	pattern  *regexp.Regexp
+	level    slog.Level
+	function bool // Whether the pattern matches function names, not files
 }
 
 // Verbosity sets the glog verbosity ceiling. The verbosity of individual packages
@@ -59,6 +81,56 @@ func (h *GlogHandler) Verbosity(level slog.Level) {
 	h.level.Store(int32(level))
 }
 
+// GetVerbosity returns the glog verbosity ceiling.
+func (h *GlogHandler) GetVerbosity() slog.Level {
+	return slog.Level(h.level.Load())
+}
+
+// GetVmodule returns the glog verbosity pattern last set with Vmodule.
+func (h *GlogHandler) GetVmodule() string {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	return h.ruleset
+}
+
+// BacktraceAt sets the glog backtrace location. Records emitted from that call
+// site carry the stack traces of all goroutines in a top level "stack"
+// attribute, outside of the groups of the handler. An empty location disables
+// it.
+//
+// The syntax of the argument is the file name, without directories, and the
+// line number of the call:
+//
+//	location="gopher.go:123"
+func (h *GlogHandler) BacktraceAt(location string) error {
+	if location != "" {
+		file, line, found := strings.Cut(location, ":")
+		if !found || !strings.HasSuffix(file, ".go") || strings.ContainsRune(file, '/') {
+			return errTraceSyntax
+		}
+		if n, err := strconv.Atoi(line); err != nil || n <= 0 {
+			return errTraceSyntax
+		}
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.location = location
+	h.backtrace.Store(location != "")
+
+	return nil
+}
+
+// StackAt makes records at the given level and above carry the stack trace of
+// the goroutine that emitted them in a top level "stack" attribute, e.g.
+// StackAt(LevelError) to locate errors without a debugger. Pass a level above
+// LevelCrit to disable it.
+func (h *GlogHandler) StackAt(level slog.Level) {
+	h.stackLevel.Store(int32(level))
+	h.stacks.Store(level <= LevelCrit)
+}
+
 // Vmodule sets the glog verbosity pattern.
 //
 // The syntax of the argument is a comma-separated list of pattern=N, where the
@@ -74,6 +146,16 @@ func (h *GlogHandler) Verbosity(level slog.Level) {
 //
 //	pattern="foo/*=3"
 //	 sets V to 3 in all files of any packages whose import path contains "foo"
+//
+// A pattern whose last path element contains a dot, without being a Go file
+// name, matches fully qualified function names instead, with * matching any
+// characters. Closures are matched along with their enclosing function:
+//
+//	pattern="keystore.*=5"
+//	 sets V to 5 in all functions of packages whose import path ends in "keystore"
+//
+//	pattern="worker.(*Worker).Start=5"
+//	 sets V to 5 in the Start method of the Worker type of the worker package
 func (h *GlogHandler) Vmodule(ruleset string) error {
 	var filter []pattern
 	for _, rule := range strings.Split(ruleset, ",") {
@@ -101,6 +183,11 @@ func (h *GlogHandler) Vmodule(ruleset string) error {
 		if level == LevelCrit {
 			continue // Ignore. It's harmless but no point in paying the overhead.
 		}
+		// Function rules match the symbol from a package path boundary
+		if isFunctionPattern(parts[0]) {
+			filter = append(filter, pattern{functionMatcher(parts[0]), level, true})
+			continue
+		}
 		// Compile the rule pattern into a regular expression
 		matcher := ".*"
 		for _, comp := range strings.Split(parts[0], "/") {
@@ -116,19 +203,52 @@ func (h *GlogHandler) Vmodule(ruleset string) error {
 		matcher = matcher + "$"
 
 		re, _ := regexp.Compile(matcher)
-		filter = append(filter, pattern{re, level})
+		filter = append(filter, pattern{re, level, false})
 	}
 	// Swap out the vmodule pattern for the new filter system
 	h.lock.Lock()
 	defer h.lock.Unlock()
 
 	h.patterns = filter
+	h.ruleset = ruleset
 	h.siteCache = make(map[uintptr]slog.Level)
 	h.override.Store(len(filter) != 0)
 
 	return nil
 }
 
+// Function patterns end in a package name followed by a symbol: a function,
+// a method of a type or pointer type, or a wildcard. Versioned package names
+// such as yaml.v3 end in a major version instead.
+var (
+	functionSuffix = regexp.MustCompile(`^[\pL\pN_*]+\.(\(\*[\pL\pN_*]+\)|[\pL\pN_*]+)(\.[\pL\pN_*]+)*$`)
+	versionSuffix  = regexp.MustCompile(`\.v[0-9]+$`)
+)
+
+// isFunctionPattern reports whether a vmodule pattern names functions rather
+// than files, e.g. "keystore.*" as opposed to "keystore", "keystore/*.go" or
+// "gopkg.in/yaml.v3".
+func isFunctionPattern(pat string) bool {
+	last := pat[strings.LastIndexByte(pat, '/')+1:]
+	return functionSuffix.MatchString(last) && !strings.HasSuffix(last, ".go") && !versionSuffix.MatchString(last)
+}
+
+// functionMatcher compiles a function pattern into a regular expression
+// matching qualified function names and the closures within them. A * is a
+// wildcard, except in a pointer receiver like "(*Worker)".
+func functionMatcher(pat string) *regexp.Regexp {
+	var matcher strings.Builder
+	matcher.WriteString("(^|/)")
+	for i, part := range strings.Split(pat, "(*") {
+		if i > 0 {
+			matcher.WriteString(`\(\*`)
+		}
+		matcher.WriteString(strings.ReplaceAll(regexp.QuoteMeta(part), `\*`, ".*"))
+	}
+	matcher.WriteString(`(\.func\d+(\.\d+)*)?$`)
+	return regexp.MustCompile(matcher.String())
+}
+
 // Enabled implements slog.Handler, reporting whether the handler handles records
 // at the given level.
 func (h *GlogHandler) Enabled(ctx context.Context, lvl slog.Level) bool {
@@ -139,31 +259,36 @@ func (h *GlogHandler) Enabled(ctx context.Context, lvl slog.Level) bool {
 // WithAttrs implements slog.Handler, returning a new Handler whose attributes
 // consist of both the receiver's attributes and the arguments.
 func (h *GlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
-	h.lock.RLock()
-	siteCache := maps.Clone(h.siteCache)
-	h.lock.RUnlock()
-
-	patterns := []pattern{}
-	patterns = append(patterns, h.patterns...)
-
-	res := GlogHandler{
-		origin:    h.origin.WithAttrs(attrs),
-		patterns:  patterns,
-		siteCache: siteCache,
-		location:  h.location,
+	res := &GlogHandler{
+		origin:     h.origin.WithAttrs(attrs),
+		glogFilter: h.glogFilter,
 	}
-
-	res.level.Store(h.level.Load())
-	res.override.Store(h.override.Load())
-	return &res
+	if rooted := h.rooted; rooted != nil {
+		res.rooted = func(attr slog.Attr) slog.Handler {
+			return rooted(attr).WithAttrs(attrs)
+		}
+	}
+	return res
 }
 
 // WithGroup implements slog.Handler, returning a new Handler with the given
 // group appended to the receiver's existing groups.
-//
-// Note, this function is not implemented.
 func (h *GlogHandler) WithGroup(name string) slog.Handler {
-	panic("not implemented")
+	if name == "" {
+		return h
+	}
+	rooted := h.rooted
+	if rooted == nil {
+		origin := h.origin
+		rooted = func(attr slog.Attr) slog.Handler {
+			return origin.WithAttrs([]slog.Attr{attr})
+		}
+	}
+	return &GlogHandler{
+		origin:     h.origin.WithGroup(name),
+		rooted:     func(attr slog.Attr) slog.Handler { return rooted(attr).WithGroup(name) },
+		glogFilter: h.glogFilter,
+	}
 }
 
 // Handle implements slog.Handler, filtering a log record through the global,
@@ -171,7 +296,7 @@ func (h *GlogHandler) WithGroup(name string) slog.Handler {
 func (h *GlogHandler) Handle(_ context.Context, r slog.Record) error {
 	// If the global log level allows, fast track logging
 	if slog.Level(h.level.Load()) <= r.Level {
-		return h.origin.Handle(context.Background(), r)
+		return h.emit(r)
 	}
 
 	// Check callsite cache for previously calculated log levels
@@ -186,8 +311,13 @@ func (h *GlogHandler) Handle(_ context.Context, r slog.Record) error {
 		fs := runtime.CallersFrames([]uintptr{r.PC})
 		frame, _ := fs.Next()
 
+		file := "+" + frame.File
 		for _, rule := range h.patterns {
-			if rule.pattern.MatchString(fmt.Sprintf("+%s", frame.File)) {
+			target := file
+			if rule.function {
+				target = frame.Function
+			}
+			if rule.pattern.MatchString(target) {
 				h.siteCache[r.PC], lvl, ok = rule.level, rule.level, true
 			}
 		}
@@ -198,11 +328,62 @@ func (h *GlogHandler) Handle(_ context.Context, r slog.Record) error {
 		h.lock.Unlock()
 	}
 	if lvl <= r.Level {
-		return h.origin.Handle(context.Background(), r)
+		return h.emit(r)
 	}
 	return nil
 }
 
+// emit passes a record that went through the filters to the origin handler,
+// attaching a stack trace if it's emitted from the backtrace location or at a
+// level requiring one.
+func (h *GlogHandler) emit(r slog.Record) error {
+	var stack string
+	switch {
+	case h.backtrace.Load() && h.atLocation(r.PC):
+		stack = stackTrace(true)
+	case h.stacks.Load() && slog.Level(h.stackLevel.Load()) <= r.Level:
+		stack = stackTrace(false)
+	default:
+		return h.origin.Handle(context.Background(), r)
+	}
+	// Record attributes go in the open groups, the stack is added at the
+	// root by rebuilding the origin instead, which is rare enough.
+	if h.rooted != nil {
+		return h.rooted(slog.String("stack", stack)).Handle(context.Background(), r)
+	}
+	r = r.Clone()
+	r.AddAttrs(slog.String("stack", stack))
+	return h.origin.Handle(context.Background(), r)
+}
+
+// atLocation reports whether the call site is the backtrace location.
+func (h *GlogHandler) atLocation(pc uintptr) bool {
+	if pc == 0 {
+		return false
+	}
+	fs := runtime.CallersFrames([]uintptr{pc})
+	frame, _ := fs.Next()
+
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	file, line, _ := strings.Cut(h.location, ":")
+	return filepath.Base(frame.File) == file && strconv.Itoa(frame.Line) == line
+}
+
+// stackTrace returns the stack trace of the calling goroutine, or of all of
+// them, truncated to 1MB.
+func stackTrace(all bool) string {
+	buf := make([]byte, 4096)
+	for {
+		n := runtime.Stack(buf, all)
+		if n < len(buf) || len(buf) >= 1<<20 {
+			return string(buf[:n])
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
 type discardHandler struct{}
 
 // DiscardHandler returns a no-op handler
@@ -219,7 +400,7 @@ func (h *discardHandler) Enabled(_ context.Context, level slog.Level) bool {
 }
 
 func (h *discardHandler) WithGroup(name string) slog.Handler {
-	panic("not implemented")
+	return &discardHandler{}
 }
 
 func (h *discardHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
@@ -232,6 +413,9 @@ type TerminalHandler struct {
 	lvl      slog.Level
 	useColor bool
 	attrs    []slog.Attr
+	// group is the dotted prefix of the groups opened with WithGroup,
+	// e.g. "req.header.", applied to the keys of all subsequent attributes.
+	group string
 	// fieldPadding is a map with maximum field value lengths seen until now
 	// to allow padding log contexts in a bit smarter way.
 	fieldPadding map[string]int
@@ -276,16 +460,33 @@ func (h *TerminalHandler) Enabled(_ context.Context, level slog.Level) bool {
 	return level >= h.lvl
 }
 
+// WithGroup returns a handler that qualifies the keys of all subsequent
+// attributes with the group name, rendered as dotted keys: group.key=value.
 func (h *TerminalHandler) WithGroup(name string) slog.Handler {
-	panic("not implemented")
+	if name == "" {
+		return h
+	}
+	return &TerminalHandler{
+		wr:           h.wr,
+		lvl:          h.lvl,
+		useColor:     h.useColor,
+		attrs:        slices.Clip(h.attrs),
+		group:        h.group + name + ".",
+		fieldPadding: make(map[string]int),
+	}
 }
 
 func (h *TerminalHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	flat := slices.Clip(h.attrs)
+	for _, attr := range attrs {
+		flat = appendFlattenedAttr(flat, h.group, attr)
+	}
 	return &TerminalHandler{
 		wr:           h.wr,
 		lvl:          h.lvl,
 		useColor:     h.useColor,
-		attrs:        append(h.attrs, attrs...),
+		attrs:        flat,
+		group:        h.group,
 		fieldPadding: make(map[string]int),
 	}
 }
@@ -336,16 +537,22 @@ func LogfmtHandlerWithLevel(wr io.Writer, level slog.Level) slog.Handler {
 	})
 }
 
-func builtinReplaceLogfmt(_ []string, attr slog.Attr) slog.Attr {
-	return builtinReplace(nil, attr, true)
+func builtinReplaceLogfmt(groups []string, attr slog.Attr) slog.Attr {
+	return builtinReplace(groups, attr, true)
 }
 
-func builtinReplaceJSON(_ []string, attr slog.Attr) slog.Attr {
-	return builtinReplace(nil, attr, false)
+func builtinReplaceJSON(groups []string, attr slog.Attr) slog.Attr {
+	return builtinReplace(groups, attr, false)
 }
 
-func builtinReplace(_ []string, attr slog.Attr, logfmt bool) slog.Attr {
-	switch attr.Key {
+func builtinReplace(groups []string, attr slog.Attr, logfmt bool) slog.Attr {
+	// The builtin keys are only ever emitted at the top level, a "time" or
+	// "level" attribute inside a group belongs to the user.
+	key := attr.Key
+	if len(groups) > 0 {
+		key = ""
+	}
+	switch key {
 	case slog.TimeKey:
 		if attr.Value.Kind() == slog.KindTime {
 			if logfmt {
@@ -378,6 +585,15 @@ func builtinReplace(_ []string, attr slog.Attr, logfmt bool) slog.Attr {
 		} else {
 			attr.Value = slog.StringValue(v.Dec())
 		}
+	case error:
+		switch {
+		case v == nil || (reflect.ValueOf(v).Kind() == reflect.Pointer && reflect.ValueOf(v).IsNil()):
+			attr.Value = slog.StringValue("<nil>")
+		case logfmt:
+			attr.Value = slog.StringValue(compactError(v))
+		default:
+			attr.Value = slog.AnyValue(structuredError(v))
+		}
 	case fmt.Stringer:
 		if v == nil || (reflect.ValueOf(v).Kind() == reflect.Pointer && reflect.ValueOf(v).IsNil()) {
 			attr.Value = slog.StringValue("<nil>")
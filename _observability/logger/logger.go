@@ -2,10 +2,13 @@ package logger
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"math"
 	"os"
 	"runtime"
+	"strconv"
+	"strings"
 	"sync/atomic"
 	"time"
 )
@@ -103,6 +106,29 @@ func LevelString(l slog.Level) string {
 	}
 }
 
+// ParseLevel parses a level name as returned by LevelString, case-insensitively,
+// or a legacy verbosity from 0 (crit) to 5 (trace).
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "trace":
+		return LevelTrace, nil
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	case "crit":
+		return LevelCrit, nil
+	}
+	if lvl, err := strconv.Atoi(s); err == nil && lvl >= legacyLevelCrit && lvl <= legacyLevelTrace {
+		return FromLegacyLevel(lvl), nil
+	}
+	return 0, fmt.Errorf("unknown log level %q", s)
+}
+
 // A Logger writes key/value pairs to a Handler
 type Logger interface {
 	// With returns a new Logger that has this logger's attributes plus the given attributes
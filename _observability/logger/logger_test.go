@@ -1,17 +1,36 @@
 package logger
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"testing"
 	"time"
 
+	"github.com/golang/snappy"
 	"github.com/holiman/uint256"
 )
 
@@ -34,6 +53,90 @@ func TestLoggingWithVmodule(t *testing.T) {
 	}
 }
 
+// vmoduleSite logs from methods, to be matched by function vmodule rules.
+type vmoduleSite struct{ logger Logger }
+
+func (s *vmoduleSite) method(msg string) { s.logger.Trace(msg) }
+
+func (s *vmoduleSite) closure(msg string) {
+	func() { s.logger.Trace(msg) }()
+}
+
+// TestVmoduleFunctions checks that vmodule rules match packages and functions.
+func TestVmoduleFunctions(t *testing.T) {
+	out := new(bytes.Buffer)
+	glog := NewGlogHandler(NewTerminalHandlerWithLevel(out, LevelTrace, false))
+	glog.Verbosity(LevelCrit)
+	site := &vmoduleSite{logger: NewLogger(glog)}
+
+	for _, tt := range []struct {
+		rule string
+		want bool
+	}{
+		{"logger.*=5", true},
+		{"EncrypteDL/EncryrpteID/_observability/logger.*=5", true},
+		{"_observability/logger.(*vmoduleSite).*=5", true},
+		{"logger.(*vmoduleSite).method=5", true},
+		{"logger.(*vmoduleSite).method=5,logger.(*vmoduleSite).method=1", false},
+		{"logger.(*vmoduleSite).meth=5", false},
+		{"ogger.*=5", false},
+		{"keystore.*=5", false},
+		{"logger.(*vmoduleSite).closure=5", false}, // Only the closure logs
+	} {
+		if err := glog.Vmodule(tt.rule); err != nil {
+			t.Fatalf("rule %q: %v", tt.rule, err)
+		}
+		out.Reset()
+		site.method("method")
+		if have := out.Len() > 0; have != tt.want {
+			t.Errorf("rule %q: expected logged %v, got %v", tt.rule, tt.want, have)
+		}
+	}
+	glog.Vmodule("logger.(*vmoduleSite).closure=5")
+	out.Reset()
+	site.closure("closure")
+	if !strings.Contains(out.String(), "closure") {
+		t.Errorf("closure not matched by its enclosing function")
+	}
+	// File rules keep matching files alongside function rules.
+	glog.Vmodule("keystore.*=5,logger_test.go=5")
+	out.Reset()
+	site.method("method")
+	if out.Len() == 0 {
+		t.Errorf("file rule not applied along with a function rule")
+	}
+}
+
+func TestVmodulePatterns(t *testing.T) {
+	for pat, want := range map[string]bool{
+		"keystore.*":                    true,
+		"keystore.Load":                 true,
+		"worker.(*Worker).Start":        true,
+		"worker.Worker.Start":           true,
+		"gopkg.in/yaml.v3.Unmarshal":    true,
+		"keystore":                      false,
+		"keystore/*.go":                 false,
+		"logger_test.go":                false,
+		"gopkg.in/yaml.v3":              false,
+		"example.com/foo.v2/*":          false,
+		"example.com/foo.v2":            false,
+		"example.com/foo/bar-baz.thing": false,
+	} {
+		if have := isFunctionPattern(pat); have != want {
+			t.Errorf("pattern %q: expected function pattern %v, got %v", pat, want, have)
+		}
+	}
+
+	// Versioned package rules match the files of the package.
+	glog := NewGlogHandler(DiscardHandler())
+	if err := glog.Vmodule("gopkg.in/yaml.v3=4"); err != nil {
+		t.Fatal(err)
+	}
+	if rule := glog.patterns[0]; rule.function || !rule.pattern.MatchString("+/go/pkg/mod/gopkg.in/yaml.v3/decode.go") {
+		t.Errorf("versioned package rule %v doesn't match its files", rule.pattern)
+	}
+}
+
 func TestTerminalHandlerWithAttrs(t *testing.T) {
 	out := new(bytes.Buffer)
 	glog := NewGlogHandler(NewTerminalHandlerWithLevel(out, LevelTrace, false).WithAttrs([]slog.Attr{slog.String("baz", "bat")}))
@@ -190,3 +293,1447 @@ func TestTermTimeFormat(t *testing.T) {
 		t.Errorf("have != want\nhave: %q\nwant: %q\n", have, want)
 	}
 }
+
+func TestTerminalHandlerWithGroup(t *testing.T) {
+	out := new(bytes.Buffer)
+	glog := NewGlogHandler(NewTerminalHandlerWithLevel(out, LevelTrace, false))
+	glog.Verbosity(LevelTrace)
+	logger := slog.New(glog).With("node", 1).WithGroup("req").With("id", 7).WithGroup("hdr")
+	logger.Info("a message", "foo", "bar", slog.Group("auth", "scheme", "did"), slog.Group("empty"))
+	have := out.String()
+	// The timestamp is locale-dependent, so we want to trim that off
+	// "INFO [01-01|00:00:00.000] a message ..." -> "a message..."
+	have = strings.Split(have, "]")[1]
+	want := " a message                                node=1 req.id=7 req.hdr.foo=bar req.hdr.auth.scheme=did\n"
+	if have != want {
+		t.Errorf("\nhave: %q\nwant: %q\n", have, want)
+	}
+}
+
+func TestJSONHandlerWithGroup(t *testing.T) {
+	out := new(bytes.Buffer)
+	glog := NewGlogHandler(JSONHandler(out))
+	glog.Verbosity(LevelTrace)
+	SetDefault(NewLogger(glog))
+	defer SetDefault(NewLogger(DiscardHandler()))
+
+	slog.Default().WithGroup("req").With("id", 7).Info("a message", "level", "high")
+	have := out.String()
+	want := `"msg":"a message","req":{"id":7,"level":"high"}}` + "\n"
+	if !strings.HasSuffix(have, want) {
+		t.Errorf("\nhave: %q\nwant suffix: %q\n", have, want)
+	}
+
+	// The discard handler must not panic either.
+	slog.New(DiscardHandler()).WithGroup("req").Info("a message")
+}
+
+type holder struct{ name, did string }
+
+func (h holder) Redact() slog.Value {
+	return slog.GroupValue(slog.String("did", h.did))
+}
+
+// signer reveals its key when resolved, Redact must be called instead.
+type signer struct{ name, key string }
+
+func (s signer) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("name", s.name), slog.String("key", s.key))
+}
+
+func (s signer) Redact() slog.Value {
+	return slog.StringValue(s.name)
+}
+
+func TestRedactHandler(t *testing.T) {
+	out := new(bytes.Buffer)
+	handler, err := NewRedactHandler(NewTerminalHandlerWithLevel(out, LevelTrace, false), RedactOptions{
+		DropKeys:   []string{"password", "claims.ssn"},
+		HashKeys:   []string{"email"},
+		HashSecret: []byte("secret"),
+		Detectors:  DefaultDetectors(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := NewLogger(handler).With("password", "hunter2")
+
+	key := strings.Repeat("ab", 32)
+	logger.Info("issued credential to alice@example.com",
+		"email", "alice@example.com",
+		"claims", slog.GroupValue(slog.String("ssn", "123-45-6789"), slog.String("degree", "MSc")),
+		"holder", holder{"Alice", "did:example:123"},
+		"signer", signer{"issuer-1", "s3cr3t"},
+		"err", fmt.Errorf("signing with key 0x%s failed", key))
+
+	have := strings.Split(out.String(), "]")[1]
+	for _, leak := range []string{"hunter2", "alice@example.com", "123-45-6789", "Alice", "s3cr3t", key} {
+		if strings.Contains(have, leak) {
+			t.Errorf("output leaks %q: %s", leak, have)
+		}
+	}
+	for _, want := range []string{"<redacted:email>", "email=hmac:", "claims.degree=MSc", "holder.did=did:example:123", "signer=issuer-1", "<redacted:hexkey>"} {
+		if !strings.Contains(have, want) {
+			t.Errorf("output misses %q: %s", want, have)
+		}
+	}
+
+	// Hashed values stay correlatable across records.
+	out.Reset()
+	logger.Info("again", "email", "alice@example.com")
+	if again := strings.Split(out.String(), "]")[1]; !strings.Contains(have, strings.Fields(again)[1]) {
+		t.Errorf("hashed email should be stable, got %q and %q", have, again)
+	}
+
+	// Hashing with an empty key could be reversed with a dictionary.
+	if _, err := NewRedactHandler(DiscardHandler(), RedactOptions{HashKeys: []string{"email"}}); err == nil {
+		t.Error("expected an error hashing without a secret")
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "node.log")
+	file, err := NewRotatingFile(path, RotateOptions{MaxSize: 1024, MaxBackups: 2, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Several handlers write to the same file concurrently.
+	var wg sync.WaitGroup
+	for _, h := range []slog.Handler{NewTerminalHandler(file, false), JSONHandler(file), LogfmtHandler(file)} {
+		wg.Add(1)
+		go func(l Logger) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				l.Info("a message", "i", i)
+			}
+		}(NewLogger(h))
+	}
+	wg.Wait()
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, err := filepath.Glob(filepath.Join(dir, "node-*.log.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("expected 2 compressed backups, got %v", backups)
+	}
+	for _, name := range append(backups, path) {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.HasSuffix(name, ".gz") {
+			zr, err := gzip.NewReader(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if data, err = io.ReadAll(zr); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if len(data) > 1024 {
+			t.Errorf("%s exceeds the maximum size: %d bytes", name, len(data))
+		}
+		if !bytes.HasSuffix(data, []byte("\n")) {
+			t.Errorf("%s holds a partial record", name)
+		}
+	}
+}
+
+func TestRotatingFileReopen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "node.log")
+	file, err := NewRotatingFile(path, RotateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	logger := NewLogger(LogfmtHandler(file))
+	logger.Info("before")
+
+	// An external tool moves the file away, new records go to a new file
+	// once it's reopened.
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("after")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "msg=after") || strings.Contains(string(data), "msg=before") {
+		t.Errorf("unexpected content after reopen: %q", data)
+	}
+}
+
+func TestRotatingFileCleanup(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "node.log")
+	var mu sync.Mutex
+	var errs []error
+	file, err := NewRotatingFile(path, RotateOptions{MaxBackups: 1, Compress: true, OnError: func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, err)
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Pruning doesn't remove the files still being compressed.
+	for i := 0; i < 50; i++ {
+		fmt.Fprintf(file, "rotation %d\n", i)
+		if err := file.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) > 0 {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+	names, err := filepath.Glob(filepath.Join(dir, "node-*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || !strings.HasSuffix(names[0], ".log.gz") {
+		t.Fatalf("expected a single compressed backup, got %v", names)
+	}
+}
+
+func TestRotatingFileFailure(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "logs", "node.log")
+	var errs []error
+	file, err := NewRotatingFile(path, RotateOptions{MaxSize: 16, OnError: func(err error) { errs = append(errs, err) }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	fmt.Fprintln(file, "first")
+
+	// The directory is replaced by a file, so neither rotating nor reopening
+	// can succeed. Writes go on to the open file.
+	if err := os.Rename(filepath.Join(dir, "logs"), filepath.Join(dir, "moved")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "logs"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"second record", "third record"} {
+		if _, err := fmt.Fprintln(file, line); err != nil {
+			t.Fatalf("write after failed rotation: %v", err)
+		}
+	}
+	if len(errs) != 1 {
+		t.Errorf("expected the failed rotation to be reported once, got %v", errs)
+	}
+	if err := file.Reopen(); err == nil {
+		t.Error("expected reopen to fail")
+	}
+	if _, err := fmt.Fprintln(file, "fourth"); err != nil {
+		t.Fatalf("write after failed reopen: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "moved", "node.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "first\nsecond record\nthird record\nfourth\n"; string(data) != want {
+		t.Errorf("expected %q, got %q", want, data)
+	}
+}
+
+func TestRotatingFileInterval(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "node.log")
+	file, err := NewRotatingFile(path, RotateOptions{Interval: 24 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	// Daily rotations happen at midnight in the zone of the clock.
+	zone := time.FixedZone("CEST", 2*60*60)
+	if next := file.next(time.Date(2024, 7, 15, 0, 30, 0, 0, zone)); !next.Equal(time.Date(2024, 7, 16, 0, 0, 0, 0, zone)) {
+		t.Errorf("expected the next rotation at midnight, got %v", next.In(zone))
+	}
+
+	// An empty file isn't rotated when it's due, its rotation is postponed.
+	file.mu.Lock()
+	file.rotate = time.Now().Add(-time.Second)
+	file.mu.Unlock()
+	fmt.Fprintln(file, "a record")
+	if err := file.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	backups, err := file.backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 1 {
+		t.Errorf("expected only the written file to be rotated, got %v", backups)
+	}
+	if !file.rotate.After(time.Now()) {
+		t.Errorf("expected the next rotation to be scheduled, got %v", file.rotate)
+	}
+}
+
+func TestRotatingFilePrune(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	file, err := NewRotatingFile(path, RotateOptions{MaxBackups: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Files sharing the prefix aren't backups.
+	others := []string{"app-errors.log", "app-2024-07-15.log", "app-2024-07-15T10-30-00.000.x.log"}
+	for _, name := range others {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Rotations within a millisecond are numbered, the last one is kept.
+	for i := 0; i < 3; i++ {
+		fmt.Fprintf(file, "rotation %d\n", i)
+		if err := file.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, err := file.backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 1 {
+		t.Fatalf("expected 1 backup, got %v", backups)
+	}
+	if data, err := os.ReadFile(backups[0]); err != nil || string(data) != "rotation 2\n" {
+		t.Errorf("expected the last backup to be kept, got %q, %v", data, err)
+	}
+	for _, name := range others {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("unrelated file removed: %v", err)
+		}
+	}
+}
+
+// slowWriter blocks every write until released.
+type slowWriter struct {
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	release chan struct{}
+}
+
+func (w *slowWriter) Write(p []byte) (int, error) {
+	<-w.release
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *slowWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
+
+// waitAsyncBusy waits until the writer of the handler picked up a batch.
+func waitAsyncBusy(h *AsyncHandler) {
+	for {
+		h.queue.mu.Lock()
+		busy := h.queue.busy
+		h.queue.mu.Unlock()
+		if busy {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestAsyncHandler(t *testing.T) {
+	out := &slowWriter{release: make(chan struct{})}
+	handler := NewAsyncHandler(LogfmtHandler(out), AsyncOptions{BufferSize: 4, Overflow: OverflowDropOldest})
+	logger := NewLogger(handler).With("node", 1)
+
+	// The first record is picked up by the writer and blocks it, the next
+	// ones fill the buffer and push each other out.
+	logger.Info("first")
+	waitAsyncBusy(handler)
+	for i := 0; i < 10; i++ {
+		logger.Info("burst", "i", i)
+	}
+	close(out.release)
+	handler.Flush()
+
+	if n := handler.Dropped(); n == 0 {
+		t.Error("expected records to be dropped on overflow")
+	}
+	have := out.String()
+	if !strings.Contains(have, "msg=first node=1") || !strings.Contains(have, "i=9") {
+		t.Errorf("expected the first and newest records, got:\n%s", have)
+	}
+	if err := handler.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Records are written synchronously once closed.
+	logger.Info("closed")
+	if !strings.Contains(out.String(), "msg=closed") {
+		t.Error("expected records to be written after close")
+	}
+}
+
+func TestAsyncHandlerDropBelow(t *testing.T) {
+	out := &slowWriter{release: make(chan struct{})}
+	handler := NewAsyncHandler(LogfmtHandler(out), AsyncOptions{BufferSize: 1, Overflow: OverflowDropBelow, DropLevel: LevelWarn})
+	logger := NewLogger(handler)
+
+	logger.Info("first")
+	waitAsyncBusy(handler)
+	logger.Info("buffered")
+	logger.Info("dropped")
+
+	// An error must not be dropped, it waits for room instead, and a crit
+	// record is flushed before returning.
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(out.release)
+	}()
+	logger.Error("kept")
+	handler.Handle(context.Background(), slog.NewRecord(time.Now(), LevelCrit, "fatal", 0))
+
+	have := out.String()
+	for _, want := range []string{"msg=first", "msg=buffered", "msg=kept", "msg=fatal"} {
+		if !strings.Contains(have, want) {
+			t.Errorf("expected %q in output:\n%s", want, have)
+		}
+	}
+	if strings.Contains(have, "msg=dropped") || handler.Dropped() != 1 {
+		t.Errorf("expected exactly the info record to be dropped, dropped %d:\n%s", handler.Dropped(), have)
+	}
+	handler.Close()
+}
+
+func TestMultiHandler(t *testing.T) {
+	var term, file, remote bytes.Buffer
+	handler := NewMultiHandler(
+		Sink{Handler: NewTerminalHandler(&term, false), Level: LevelDebug},
+		Sink{Handler: JSONHandler(&file), Level: LevelInfo},
+		Sink{Handler: LogfmtHandler(&remote), Level: LevelError, Filter: func(_ context.Context, r slog.Record) bool {
+			return r.Message != "ignored"
+		}},
+	)
+	if handler.Enabled(context.Background(), LevelTrace) {
+		t.Error("expected trace records to be rejected")
+	}
+	logger := NewLogger(handler).With("node", 1)
+
+	logger.Trace("trace")
+	logger.Debug("debug")
+	logger.Info("info")
+	logger.Error("error")
+	logger.Error("ignored")
+
+	for _, tt := range []struct {
+		name string
+		have string
+		want []string
+		not  []string
+	}{
+		{"terminal", term.String(), []string{"debug", "info", "error", "node=1"}, []string{"trace"}},
+		{"json", file.String(), []string{`"msg":"info"`, `"msg":"error"`, `"node":1`}, []string{"debug"}},
+		{"logfmt", remote.String(), []string{"msg=error node=1"}, []string{"info", "ignored"}},
+	} {
+		for _, want := range tt.want {
+			if !strings.Contains(tt.have, want) {
+				t.Errorf("%s: expected %q in output:\n%s", tt.name, want, tt.have)
+			}
+		}
+		for _, not := range tt.not {
+			if strings.Contains(tt.have, not) {
+				t.Errorf("%s: unexpected %q in output:\n%s", tt.name, not, tt.have)
+			}
+		}
+	}
+}
+
+// fakeLoki is a Loki push endpoint recording the received lines by stream.
+type fakeLoki struct {
+	mu      sync.Mutex
+	fail    int // Number of requests to reject with a 500 before accepting
+	calls   int
+	streams map[string][]string
+	labels  []map[string]string // Labels of the received streams
+	raw     [][]byte
+}
+
+func (l *fakeLoki) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.calls++
+	if l.fail > 0 {
+		l.fail--
+		http.Error(w, "try again", http.StatusInternalServerError)
+		return
+	}
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body = zr
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.Header.Get("Content-Type") == "application/x-protobuf" {
+		// Decoding the protobuf is left to Loki, keep the raw request.
+		if data, err = snappy.Decode(nil, data); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		l.raw = append(l.raw, data)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	var req struct {
+		Streams []struct {
+			Stream map[string]string `json:"stream"`
+			Values [][2]string       `json:"values"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if l.streams == nil {
+		l.streams = make(map[string][]string)
+	}
+	for _, s := range req.Streams {
+		l.labels = append(l.labels, s.Stream)
+		key := fmt.Sprintf("%s/%s", s.Stream["level"], s.Stream["tenant"])
+		for _, v := range s.Values {
+			l.streams[key] = append(l.streams[key], v[1])
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestLokiHandler(t *testing.T) {
+	loki := &fakeLoki{fail: 2}
+	srv := httptest.NewServer(loki)
+	defer srv.Close()
+
+	handler, err := NewLokiHandler(LokiOptions{
+		URL:         srv.URL,
+		Labels:      map[string]string{"service": "did"},
+		LabelKeys:   []string{"tenant"},
+		Compression: LokiGzip,
+		BatchWait:   time.Hour,
+		MinBackoff:  time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := NewLogger(handler)
+	logger.With("tenant", "acme").Info("created", "id", 1)
+	logger.Info("started", "tenant", "globex")
+	logger.Error("failed", "tenant", "acme")
+	NewLogger(handler.WithGroup("req")).Info("grouped", "tenant", "hidden")
+	handler.Flush()
+
+	loki.mu.Lock()
+	for key, want := range map[string]string{
+		"info/acme":   "msg=created tenant=acme id=1",
+		"info/globex": "msg=started tenant=globex",
+		"error/acme":  "msg=failed tenant=acme",
+		"info/":       "msg=grouped req.tenant=hidden",
+	} {
+		if lines := loki.streams[key]; len(lines) != 1 || !strings.Contains(lines[0], want) {
+			t.Errorf("stream %s: expected %q, got %q", key, want, lines)
+		}
+	}
+	if loki.calls != 3 {
+		t.Errorf("expected 2 retries before success, got %d calls", loki.calls)
+	}
+	loki.mu.Unlock()
+
+	if err := handler.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := handler.Dropped(); n != 0 {
+		t.Errorf("expected no drops, got %d", n)
+	}
+}
+
+func TestLokiLabelNames(t *testing.T) {
+	labels := map[string]string{
+		"trace.id": "a",
+		"k8s-pod":  "b",
+		"a=b,c}":   "c",
+		"9lives":   "d",
+		"trace-id": "e",
+		"level":    "f",
+		"":         "g",
+	}
+	want := `{level="info",_="g",_9lives="d",a_b_c_="c",k8s_pod="b",trace_id="e"}`
+	if have := streamSelector(labels, "info"); have != want {
+		t.Errorf("expected selector %s, got %s", want, have)
+	}
+	if parsed := parseStreamSelector(want); len(parsed) != 6 || parsed["k8s_pod"] != "b" {
+		t.Errorf("selector doesn't parse back: %v", parsed)
+	}
+
+	// Values are escaped as Prometheus does, non-ASCII characters are kept.
+	labels = map[string]string{"city": "Zürich\u00a0\t\"HB\"\n\\"}
+	want = "{level=\"info\",city=\"Zürich\u00a0\t" + `\"HB\"\n\\"}`
+	if have := streamSelector(labels, "info"); have != want {
+		t.Errorf("expected selector %s, got %s", want, have)
+	}
+	if parsed := parseStreamSelector(want); parsed["city"] != labels["city"] {
+		t.Errorf("selector doesn't parse back: %q", parsed)
+	}
+
+	// Labels collected from attributes are renamed too.
+	loki := new(fakeLoki)
+	srv := httptest.NewServer(loki)
+	defer srv.Close()
+	handler, err := NewLokiHandler(LokiOptions{URL: srv.URL, LabelKeys: []string{"tenant.id"}, BatchWait: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	NewLogger(handler).Info("dotted", "tenant.id", "acme")
+	handler.Close()
+	loki.mu.Lock()
+	defer loki.mu.Unlock()
+	if len(loki.labels) != 1 || loki.labels[0]["tenant_id"] != "acme" {
+		t.Errorf("unexpected stream labels %v", loki.labels)
+	}
+}
+
+func TestLokiHandlerSnappy(t *testing.T) {
+	loki := new(fakeLoki)
+	srv := httptest.NewServer(loki)
+	defer srv.Close()
+
+	handler, err := NewLokiHandler(LokiOptions{
+		URL:         srv.URL,
+		Labels:      map[string]string{"service": "did"},
+		Compression: LokiSnappy,
+		JSON:        true,
+		BatchWait:   time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	NewLogger(handler).Warn("snappy", "n", 7)
+	handler.Close()
+
+	loki.mu.Lock()
+	defer loki.mu.Unlock()
+	if len(loki.raw) != 1 {
+		t.Fatalf("expected one protobuf request, got %d", len(loki.raw))
+	}
+	for _, want := range []string{`{level="warn",service="did"}`, `"msg":"snappy"`, `"n":7`} {
+		if !bytes.Contains(loki.raw[0], []byte(want)) {
+			t.Errorf("expected %q in request %q", want, loki.raw[0])
+		}
+	}
+}
+
+func TestLokiHandlerMemoryCap(t *testing.T) {
+	loki := &fakeLoki{fail: 1 << 30}
+	srv := httptest.NewServer(loki)
+	defer srv.Close()
+
+	var mu sync.Mutex
+	var errs []error
+	handler, err := NewLokiHandler(LokiOptions{
+		URL:            srv.URL,
+		BatchWait:      time.Hour,
+		MaxBufferBytes: 256,
+		MaxRetries:     -1,
+		OnError: func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			errs = append(errs, err)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := NewLogger(handler)
+	for i := 0; i < 20; i++ {
+		logger.Info("filling the buffer", "i", i)
+	}
+	if n := handler.Dropped(); n == 0 {
+		t.Error("expected the oldest records to be dropped beyond the memory cap")
+	}
+	handler.Close()
+
+	// The batches Loki rejected are reported.
+	mu.Lock()
+	defer mu.Unlock()
+	if len(errs) == 0 || !strings.Contains(errs[0].Error(), "500") {
+		t.Errorf("expected the rejected pushes to be reported, got %v", errs)
+	}
+}
+
+func TestLevelHandler(t *testing.T) {
+	var out bytes.Buffer
+	audit := &slowWriter{release: make(chan struct{})}
+	close(audit.release) // The restoration is logged from a timer
+	glog := NewGlogHandler(NewTerminalHandlerWithLevel(&out, LevelTrace, false))
+	glog.Verbosity(LevelInfo)
+	logger := NewLogger(glog).With("module", "test")
+
+	admin := NewLevelHandler(glog, LevelHandlerOptions{Logger: NewLogger(LogfmtHandler(audit))})
+	defer admin.Close()
+	srv := httptest.NewServer(admin)
+	defer srv.Close()
+
+	do := func(method, body string) (int, LevelSettings) {
+		t.Helper()
+		req, _ := http.NewRequest(method, srv.URL, strings.NewReader(body))
+		req.SetBasicAuth("alice", "secret")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var settings LevelSettings
+		json.NewDecoder(resp.Body).Decode(&settings)
+		return resp.StatusCode, settings
+	}
+	if code, have := do(http.MethodGet, ""); code != http.StatusOK || have.Verbosity != 3 || have.Level != "info" {
+		t.Fatalf("unexpected initial settings: %d %+v", code, have)
+	}
+	if code, _ := do(http.MethodPut, `{"verbosity": 9}`); code != http.StatusBadRequest {
+		t.Errorf("expected out of range verbosity to be rejected, got %d", code)
+	}
+	if code, _ := do(http.MethodPut, `{"vmodule": "foo"}`); code != http.StatusBadRequest {
+		t.Errorf("expected invalid vmodule to be rejected, got %d", code)
+	}
+
+	// Loggers derived before the change follow it.
+	code, have := do(http.MethodPut, `{"verbosity": 5, "vmodule": "worker/*=4", "ttl": "100ms"}`)
+	if code != http.StatusOK || have.Verbosity != 5 || have.Vmodule != "worker/*=4" || have.RestoreAt == nil {
+		t.Fatalf("unexpected settings after change: %d %+v", code, have)
+	}
+	logger.Debug("visible")
+	if !strings.Contains(out.String(), "visible") {
+		t.Error("expected debug records after raising the verbosity")
+	}
+	if have := audit.String(); !strings.Contains(have, "by=alice@127.0.0.1") || !strings.Contains(have, "previous.verbosity=3") {
+		t.Errorf("expected the change to be logged with its author:\n%s", have)
+	}
+
+	// The previous settings come back once the ttl elapses.
+	time.Sleep(300 * time.Millisecond)
+	if _, have := do(http.MethodGet, ""); have.Verbosity != 3 || have.Vmodule != "" || have.RestoreAt != nil {
+		t.Errorf("expected settings to be restored, got %+v", have)
+	}
+	out.Reset()
+	logger.Debug("hidden")
+	if out.Len() != 0 {
+		t.Errorf("expected debug records to be filtered again, got %q", out.String())
+	}
+	if !strings.Contains(audit.String(), "Log verbosity restored") {
+		t.Errorf("expected the restoration to be logged:\n%s", audit.String())
+	}
+}
+
+func TestLevelHandlerRace(t *testing.T) {
+	glog := NewGlogHandler(NewTerminalHandler(io.Discard, false))
+	glog.Verbosity(LevelInfo)
+	admin := NewLevelHandler(glog, LevelHandlerOptions{Logger: NewLogger(DiscardHandler())})
+	defer admin.Close()
+
+	short, long := 5, 4
+	for i := 0; i < 20; i++ {
+		// The first timer fires and waits for the lock, which the second
+		// change is likely to get first.
+		if _, err := admin.apply(levelChange{Verbosity: &short, TTL: "1ns"}, "test"); err != nil {
+			t.Fatal(err)
+		}
+		admin.mu.Lock()
+		time.Sleep(time.Millisecond)
+		admin.mu.Unlock()
+		if _, err := admin.apply(levelChange{Verbosity: &long, TTL: "1h"}, "test"); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond)
+
+		admin.mu.Lock()
+		have := admin.current()
+		admin.mu.Unlock()
+		if have.Verbosity != long || have.RestoreAt == nil {
+			t.Fatalf("iteration %d: expected the last change to hold, got %+v", i, have)
+		}
+		admin.Close()
+		glog.Verbosity(LevelInfo)
+	}
+}
+
+func TestGlogBacktrace(t *testing.T) {
+	out := new(bytes.Buffer)
+	glog := NewGlogHandler(JSONHandler(out))
+	glog.Verbosity(LevelInfo)
+	logger := NewLogger(glog).With("module", "test")
+
+	for _, location := range []string{"logger_test.go", "dir/logger_test.go:12", "logger_test.go:x", "logger_test:12"} {
+		if err := glog.BacktraceAt(location); err == nil {
+			t.Errorf("expected %q to be rejected", location)
+		}
+	}
+	_, _, line, _ := runtime.Caller(0)
+	if err := glog.BacktraceAt(fmt.Sprintf("logger_test.go:%d", line+5)); err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("elsewhere")
+	logger.Info("here")
+
+	stacks := func() map[string]string {
+		res := make(map[string]string)
+		for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+			var rec map[string]any
+			if err := json.Unmarshal([]byte(line), &rec); err != nil {
+				t.Fatal(err)
+			}
+			stack, _ := rec["stack"].(string)
+			res[rec["msg"].(string)] = stack
+		}
+		out.Reset()
+		return res
+	}
+	have := stacks()
+	if have["elsewhere"] != "" {
+		t.Error("expected no stack away from the backtrace location")
+	}
+	if !strings.Contains(have["here"], "TestGlogBacktrace") || !strings.Contains(have["here"], "goroutine") {
+		t.Errorf("expected a backtrace at the location, got %q", have["here"])
+	}
+
+	glog.BacktraceAt("")
+	glog.StackAt(LevelError)
+	logger.Warn("warning")
+	logger.Error("failure")
+	have = stacks()
+	if have["warning"] != "" {
+		t.Error("expected no stack below the stack level")
+	}
+	if !strings.Contains(have["failure"], "TestGlogBacktrace") {
+		t.Errorf("expected a stack at error level, got %q", have["failure"])
+	}
+
+	// The stack stays at the root of grouped records.
+	NewLogger(glog.WithGroup("req")).With("id", 7).Error("grouped", "did", "did:example:1")
+	var rec struct {
+		Stack string
+		Req   map[string]any
+	}
+	if err := json.Unmarshal(out.Bytes(), &rec); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if !strings.Contains(rec.Stack, "TestGlogBacktrace") || len(rec.Req) != 2 || rec.Req["did"] != "did:example:1" {
+		t.Errorf("expected a top level stack beside the group, got %+v", rec)
+	}
+
+	glog.StackAt(LevelCrit + 1)
+	logger.Error("failure")
+	if have = stacks(); have["failure"] != "" {
+		t.Error("expected stacks to be disabled")
+	}
+}
+
+// testKeys is a KeyLookup over in memory RSA keys.
+type testKeys map[string]*rsa.PrivateKey
+
+func (k testKeys) PrivateKey(kid string) (string, error) {
+	key, ok := k[kid]
+	if !ok {
+		return "", errors.New("kid lookup failed")
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})), nil
+}
+
+func (k testKeys) PublicKey(kid string) (string, error) {
+	key, ok := k[kid]
+	if !ok {
+		return "", errors.New("kid lookup failed")
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
+
+func TestAuditHandler(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := testKeys{"audit": key}
+
+	var out bytes.Buffer
+	var anchored []AuditCheckpoint
+	handler, err := NewAuditHandler(&out, AuditOptions{
+		Keys:            keys,
+		KeyID:           "audit",
+		CheckpointEvery: 3,
+		OnCheckpoint:    func(cp AuditCheckpoint) { anchored = append(anchored, cp) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := NewLogger(handler).With("actor", "admin")
+	for i := 0; i < 5; i++ {
+		logger.Info("did updated", "did", fmt.Sprintf("did:ethr:0x%02d", i), "seq", "user value")
+	}
+	NewLogger(handler.WithGroup("req")).Warn("did deleted", "hash", "user value")
+
+	report, err := VerifyAuditLog(bytes.NewReader(out.Bytes()), AuditHead{}, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 6 records plus a checkpoint after every 3 of them.
+	if report.Records != 8 || len(report.Checkpoints) != 2 || report.Head != handler.Head() {
+		t.Fatalf("unexpected report: %+v, head %+v", report, handler.Head())
+	}
+	if len(anchored) != 2 || VerifyCheckpoint(anchored[1], keys) != nil {
+		t.Errorf("expected 2 valid checkpoints to anchor, got %+v", anchored)
+	}
+
+	// Appending to the same file continues the chain.
+	resumed, err := NewAuditHandler(&out, AuditOptions{Head: report.Head})
+	if err != nil {
+		t.Fatal(err)
+	}
+	NewLogger(resumed).Info("restarted")
+	if report, err := VerifyAuditLog(bytes.NewReader(out.Bytes()), AuditHead{}, keys); err != nil || report.Records != 9 {
+		t.Fatalf("expected resumed chain to verify, got %v, %+v", err, report)
+	}
+
+	lines := strings.SplitAfter(strings.TrimSuffix(out.String(), "\n"), "\n")
+	tamper := func(f func([]string) []string) string {
+		return strings.Join(f(slices.Clone(lines)), "")
+	}
+	otherKeys := testKeys{"audit": func() *rsa.PrivateKey { k, _ := rsa.GenerateKey(rand.Reader, 2048); return k }()}
+	for _, tt := range []struct {
+		name string
+		log  string
+		keys KeyLookup
+		want error
+	}{
+		{"edit", tamper(func(l []string) []string {
+			l[1] = strings.Replace(l[1], "did:ethr:0x01", "did:ethr:0x09", 1)
+			return l
+		}), nil, ErrAuditHash},
+		{"delete", tamper(func(l []string) []string { return slices.Delete(l, 2, 3) }), nil, ErrAuditChain},
+		{"reorder", tamper(func(l []string) []string {
+			l[1], l[2] = l[2], l[1]
+			return l
+		}), nil, ErrAuditChain},
+		{"garbage", tamper(func(l []string) []string { return slices.Insert(l, 1, "not an audit record\n") }), nil, ErrAuditFormat},
+		{"signature", out.String(), otherKeys, ErrAuditSignature},
+	} {
+		if _, err := VerifyAuditLog(strings.NewReader(tt.log), AuditHead{}, tt.keys); !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, err)
+		}
+	}
+
+	// Records can't pass for checkpoints, whether they would verify or not.
+	out.Reset()
+	forger, err := NewAuditHandler(&out, AuditOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	head := forger.Head()
+	NewLogger(forger).Info(auditCheckpointMsg, slog.Group("checkpoint",
+		slog.Uint64("seq", head.Seq), slog.String("hash", head.Hash), slog.String("kid", "audit"), slog.String("sig", "AAAA")))
+	NewLogger(forger.WithGroup("checkpoint")).Info(auditCheckpointMsg, "seq", 1)
+	for _, keys := range []KeyLookup{nil, keys} {
+		report, err := VerifyAuditLog(bytes.NewReader(out.Bytes()), AuditHead{}, keys)
+		if err != nil || report.Records != 2 || len(report.Checkpoints) != 0 || report.Last != nil {
+			t.Errorf("expected forged checkpoints to be plain records, got %v, %+v", err, report)
+		}
+	}
+}
+
+func TestSampleHandler(t *testing.T) {
+	out := &slowWriter{release: make(chan struct{})}
+	close(out.release) // Summaries are written from the background goroutine
+	handler := NewSampleHandler(LogfmtHandler(out), SampleOptions{Interval: time.Hour, First: 3})
+	logger := NewLogger(handler).With("peer", "p1")
+
+	for i := 0; i < 50; i++ {
+		logger.Warn("Peer reconnect", "i", i)
+	}
+	logger.Error("Peer reconnect") // Different level, counted apart
+	logger.Warn("Invalid signature")
+	handler.Close()
+
+	have := out.String()
+	if n := strings.Count(have, `msg="Peer reconnect"`); n != 4 {
+		t.Errorf("expected 3 warnings and 1 error through, got %d:\n%s", n, have)
+	}
+	if !strings.Contains(have, "i=2\n") || strings.Contains(have, "i=3\n") {
+		t.Errorf("expected the first records to be let through:\n%s", have)
+	}
+	if !strings.Contains(have, `lvl=warn msg="Suppressed similar messages" message="Peer reconnect" count=47`) {
+		t.Errorf("expected a summary of the suppressed records:\n%s", have)
+	}
+	if strings.Count(have, "Suppressed similar messages") != 1 {
+		t.Errorf("expected a single summary:\n%s", have)
+	}
+}
+
+func TestSampleHandlerThereafter(t *testing.T) {
+	out := &slowWriter{release: make(chan struct{})}
+	close(out.release)
+	handler := NewSampleHandler(LogfmtHandler(out), SampleOptions{Interval: 50 * time.Millisecond, First: 3, Thereafter: 10})
+	defer handler.Close()
+	logger := NewLogger(handler)
+
+	for i := 1; i <= 23; i++ {
+		logger.Info("flood", "i", i)
+	}
+	have := out.String()
+	for _, i := range []int{1, 2, 3, 13, 23} {
+		if !strings.Contains(have, fmt.Sprintf("i=%d\n", i)) {
+			t.Errorf("expected record %d to be sampled in:\n%s", i, have)
+		}
+	}
+	if n := strings.Count(have, "msg=flood"); n != 5 {
+		t.Errorf("expected 5 records through, got %d", n)
+	}
+
+	// The summary comes once the interval is over, without waiting for
+	// another similar record.
+	time.Sleep(200 * time.Millisecond)
+	if !strings.Contains(out.String(), "message=flood count=18") {
+		t.Errorf("expected a periodic summary:\n%s", out.String())
+	}
+}
+
+func TestRingHandler(t *testing.T) {
+	ring := NewRingHandler(5)
+	logger := NewLogger(ring).With("peer", "p1")
+	start := time.Now()
+	for i := 0; i < 8; i++ {
+		logger.Info("Peer message", "i", i)
+	}
+	logger.Warn("Peer dropped", "reason", "too many peers")
+	NewLogger(ring.WithGroup("req")).Error("Request failed", "id", 7)
+
+	// Only the last 5 records are kept.
+	all := ring.Records(RingQuery{})
+	if len(all) != 5 || all[0].Message != "Peer message" || all[4].Message != "Request failed" {
+		t.Fatalf("unexpected records kept: %v", all)
+	}
+	for _, tt := range []struct {
+		name string
+		q    RingQuery
+		want []string
+	}{
+		{"level", RingQuery{Level: LevelWarn}, []string{"Peer dropped", "Request failed"}},
+		{"message", RingQuery{Message: "dropped"}, []string{"Peer dropped"}},
+		{"attrs", RingQuery{Attrs: map[string]string{"peer": "p1", "i": "7"}}, []string{"Peer message"}},
+		{"group", RingQuery{Attrs: map[string]string{"req.id": "7"}}, []string{"Request failed"}},
+		{"limit", RingQuery{Limit: 2}, []string{"Peer dropped", "Request failed"}},
+		{"since", RingQuery{Since: start.Add(time.Hour)}, nil},
+		{"until", RingQuery{Until: start.Add(-time.Hour)}, nil},
+	} {
+		var have []string
+		for _, r := range ring.Records(tt.q) {
+			have = append(have, r.Message)
+		}
+		if !slices.Equal(have, tt.want) {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, have)
+		}
+	}
+
+	srv := httptest.NewServer(ring)
+	defer srv.Close()
+	get := func(query string) (int, string) {
+		t.Helper()
+		resp, err := http.Get(srv.URL + "?" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+	code, body := get("level=warn&since=1h&attr=reason=too+many+peers")
+	if code != http.StatusOK || strings.Count(body, "\n") != 1 || !strings.Contains(body, `"peer":"p1","reason":"too many peers"`) {
+		t.Errorf("unexpected json response %d:\n%s", code, body)
+	}
+	code, body = get("level=error&format=text")
+	if code != http.StatusOK || !strings.Contains(body, "ERROR") || !strings.Contains(body, "req.id=7") {
+		t.Errorf("unexpected text response %d:\n%s", code, body)
+	}
+	for _, query := range []string{"level=loud", "since=yesterday", "attr=peer", "limit=-1", "format=xml"} {
+		if code, _ := get(query); code != http.StatusBadRequest {
+			t.Errorf("%s: expected bad request, got %d", query, code)
+		}
+	}
+}
+
+func TestParseRecord(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		handler func(io.Writer) slog.Handler
+	}{
+		{"json", JSONHandler},
+		{"logfmt", LogfmtHandler},
+	} {
+		var out bytes.Buffer
+		logger := NewLogger(tt.handler(&out).WithGroup("req"))
+		logger.Warn("Request failed", "id", 42, "path", "/did/1 2", "ok", false, "ratio", 0.5)
+
+		r, err := ParseRecord(out.Bytes())
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if r.Level != LevelWarn || r.Message != "Request failed" || time.Since(r.Time) > time.Minute {
+			t.Errorf("%s: unexpected builtin fields: %v %q %v", tt.name, r.Level, r.Message, r.Time)
+		}
+		var term bytes.Buffer
+		NewTerminalHandler(&term, false).Handle(context.Background(), r)
+		if have, want := term.String(), `req.id=42 req.path="/did/1 2" req.ok=false req.ratio=0.500`; !strings.Contains(have, want) {
+			t.Errorf("%s: expected %q in %q", tt.name, want, have)
+		}
+		if !(&RingQuery{Level: LevelWarn, Attrs: map[string]string{"req.id": "42"}}).Match(r) {
+			t.Errorf("%s: expected the query to match", tt.name)
+		}
+	}
+	for _, line := range []string{"", "panic: boom", "{not json", "key=value", `{"a":1}`} {
+		if _, err := ParseRecord([]byte(line)); err == nil {
+			t.Errorf("expected %q not to be a record", line)
+		}
+	}
+}
+
+// notFoundError is an error type carrying its own fields.
+type notFoundError struct{ did string }
+
+func (e *notFoundError) Error() string            { return "did not found" }
+func (e *notFoundError) ErrorFields() []slog.Attr { return []slog.Attr{slog.String("did", e.did)} }
+
+func TestStructuredErrors(t *testing.T) {
+	cause := &notFoundError{did: "did:ethr:0x01"}
+	err := Errorf("resolving document: %w", WithFields(cause, "block", 42))
+	if !errors.Is(err, cause) || err.Error() != "resolving document: did not found" {
+		t.Fatalf("unexpected wrapped error: %v", err)
+	}
+	if again := WithStack(err); again != err {
+		t.Error("expected no second stack on an error carrying one")
+	}
+
+	var out bytes.Buffer
+	NewLogger(JSONHandler(&out)).Error("Resolution failed", "err", err)
+	var rec struct {
+		Err struct {
+			Msg    string
+			Type   string
+			Fields map[string]any
+			Causes []struct{ Msg, Type string }
+			Stack  []string
+		}
+	}
+	if err := json.Unmarshal(out.Bytes(), &rec); err != nil {
+		t.Fatalf("invalid json %q: %v", out.String(), err)
+	}
+	e := rec.Err
+	if e.Msg != "resolving document: did not found" || e.Type != "*fmt.wrapError" {
+		t.Errorf("unexpected message or type: %+v", e)
+	}
+	if len(e.Causes) != 1 || e.Causes[0].Type != "*logger.notFoundError" {
+		t.Errorf("unexpected cause chain: %+v", e.Causes)
+	}
+	if e.Fields["did"] != "did:ethr:0x01" || e.Fields["block"] != float64(42) {
+		t.Errorf("unexpected fields: %+v", e.Fields)
+	}
+	if len(e.Stack) == 0 || !strings.Contains(e.Stack[0], "TestStructuredErrors") {
+		t.Errorf("expected the stack of the wrap site, got %q", e.Stack)
+	}
+
+	// Logfmt and terminal stay on one line.
+	want := `"resolving document: did not found [block=42 did=did:ethr:0x01]"`
+	out.Reset()
+	NewLogger(LogfmtHandler(&out)).Error("Resolution failed", "err", err)
+	if !strings.Contains(out.String(), "err="+want) {
+		t.Errorf("unexpected logfmt output: %s", out.String())
+	}
+	out.Reset()
+	NewLogger(NewTerminalHandler(&out, false)).Error("Resolution failed", "err", err)
+	if !strings.Contains(out.String(), "err="+want) {
+		t.Errorf("unexpected terminal output: %s", out.String())
+	}
+
+	// Plain errors are unchanged in logfmt, and structured in JSON.
+	out.Reset()
+	NewLogger(JSONHandler(&out)).Error("Failed", "err", errors.New("boom"))
+	if !strings.Contains(out.String(), `"err":{"msg":"boom","type":"*errors.errorString"}`) {
+		t.Errorf("unexpected json output for a plain error: %s", out.String())
+	}
+}
+
+func TestSyslogHandler(t *testing.T) {
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer udp.Close()
+
+	handler, err := NewSyslogHandler(SyslogOptions{
+		Network:  "udp",
+		Address:  udp.LocalAddr().String(),
+		Facility: 3,
+		AppName:  "encrypteid",
+		Hostname: "node 1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer handler.Close()
+	logger := NewLogger(handler.WithAttrs([]slog.Attr{slog.String("node", "n1")}))
+
+	read := func() string {
+		t.Helper()
+		buf := make([]byte, 4096)
+		udp.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := udp.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(buf[:n])
+	}
+	for _, tt := range []struct {
+		level slog.Level
+		pri   string
+	}{
+		{LevelTrace, "<31>"}, {LevelDebug, "<31>"}, {LevelInfo, "<30>"},
+		{LevelWarn, "<28>"}, {LevelError, "<27>"}, {LevelCrit, "<26>"},
+	} {
+		logger.Write(tt.level, "Leveled")
+		if have := read(); !strings.HasPrefix(have, tt.pri+"1 ") {
+			t.Errorf("level %v: expected priority %s, got %q", tt.level, tt.pri, have)
+		}
+	}
+
+	NewLogger(handler.WithGroup("req")).Warn("Request failed", "bad key", `a "quoted"] value`, "err", errors.New("boom"))
+	have := read()
+	want := fmt.Sprintf(` node_1 encrypteid %d - [attrs@32473 req.bad_key="a \"quoted\"\] value" req.err="boom"] Request failed`, os.Getpid())
+	if !strings.HasPrefix(have, "<28>1 ") || !strings.HasSuffix(have, want) {
+		t.Errorf("unexpected message:\n have %q\n want suffix %q", have, want)
+	}
+	logger.Info("No attributes")
+	if have := read(); !strings.Contains(have, `- [attrs@32473 node="n1"] No attributes`) {
+		t.Errorf("unexpected message %q", have)
+	}
+}
+
+func TestSyslogHandlerTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	frames := make(chan string, 2)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		br := bufio.NewReader(conn)
+		for {
+			size, err := br.ReadString(' ')
+			if err != nil {
+				return
+			}
+			n, _ := strconv.Atoi(strings.TrimSpace(size))
+			msg := make([]byte, n)
+			if _, err := io.ReadFull(br, msg); err != nil {
+				return
+			}
+			frames <- string(msg)
+		}
+	}()
+	handler, err := NewSyslogHandler(SyslogOptions{Network: "tcp", Address: ln.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer handler.Close()
+	logger := NewLogger(handler)
+	logger.Info("first\nline")
+	logger.Error("second")
+
+	for _, want := range []string{"- - first\nline", "- - second"} {
+		select {
+		case have := <-frames:
+			if !strings.HasSuffix(have, want) {
+				t.Errorf("expected frame ending with %q, got %q", want, have)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for frame")
+		}
+	}
+}
+
+// readJournalEntry reads an entry sent to a fake journald socket, directly or
+// through a passed file descriptor.
+func readJournalEntry(t *testing.T, conn *net.UnixConn) map[string]string {
+	t.Helper()
+	buf, oob := make([]byte, 1<<20), make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := buf[:n]
+	if oobn > 0 {
+		msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+		if err != nil {
+			t.Fatal(err)
+		}
+		fds, err := syscall.ParseUnixRights(&msgs[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		f := os.NewFile(uintptr(fds[0]), "journal")
+		defer f.Close()
+		if data, err = io.ReadAll(io.NewSectionReader(f, 0, 1<<30)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fields := make(map[string]string)
+	for len(data) > 0 {
+		eol := bytes.IndexByte(data, '\n')
+		line := string(data[:eol])
+		name, value, found := strings.Cut(line, "=")
+		if _, dup := fields[name]; dup {
+			t.Errorf("duplicate field %s", name)
+		}
+		if found {
+			fields[name] = value
+			data = data[eol+1:]
+			continue
+		}
+		size := binary.LittleEndian.Uint64(data[eol+1:])
+		fields[line] = string(data[eol+9 : eol+9+int(size)])
+		data = data[eol+9+int(size)+1:]
+	}
+	return fields
+}
+
+func TestJournalHandler(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "journal.socket")
+	journald, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer journald.Close()
+
+	handler, err := NewJournalHandler(JournalOptions{Socket: socket, Identifier: "encrypteid"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer handler.Close()
+	logger := NewLogger(handler.WithGroup("req"))
+
+	logger.Error("Request failed", "id", 42, "trace-id", "abc", "body", "multi\nline")
+	have := readJournalEntry(t, journald)
+	for name, want := range map[string]string{
+		"MESSAGE":           "Request failed",
+		"PRIORITY":          "3",
+		"SYSLOG_IDENTIFIER": "encrypteid",
+		"REQ_ID":            "42",
+		"REQ_TRACE_ID":      "abc",
+		"REQ_BODY":          "multi\nline",
+		"CODE_FUNC":         "EncrypteDL/EncryrpteID/_observability/logger.TestJournalHandler",
+	} {
+		if have[name] != want {
+			t.Errorf("field %s: expected %q, got %q", name, want, have[name])
+		}
+	}
+
+	if stamp, err := time.Parse(time.Stamp, have["SYSLOG_TIMESTAMP"]); err != nil || stamp.Day() != time.Now().Day() {
+		t.Errorf("invalid syslog timestamp %q", have["SYSLOG_TIMESTAMP"])
+	}
+
+	// Field names are limited to 64 characters, long keys stay distinct.
+	long := strings.Repeat("x", 64)
+	NewLogger(handler).Info("Long keys", long+"_a", 1, long+"_b", 2)
+	have = readJournalEntry(t, journald)
+	var values []string
+	for name, value := range have {
+		if strings.HasPrefix(name, "XXX") {
+			if len(name) != 64 {
+				t.Errorf("field name %s longer than 64 characters", name)
+			}
+			values = append(values, value)
+		}
+	}
+	if slices.Sort(values); !slices.Equal(values, []string{"1", "2"}) {
+		t.Errorf("expected both long keys, got %v", have)
+	}
+
+	// Entries too large for a datagram are passed in a file.
+	if _, err := os.Stat("/dev/shm"); err != nil {
+		t.Skip("no /dev/shm to pass large entries")
+	}
+	// Attributes can't pass for the fields of the handler or journald.
+	NewLogger(handler).Info("Login", "message", "forged", "priority", 0, "code_line", 1, "message_id", "x")
+	have = readJournalEntry(t, journald)
+	for name, want := range map[string]string{
+		"MESSAGE":         "Login",
+		"PRIORITY":        "6",
+		"ATTR_MESSAGE":    "forged",
+		"ATTR_PRIORITY":   "0",
+		"ATTR_CODE_LINE":  "1",
+		"ATTR_MESSAGE_ID": "x",
+	} {
+		if have[name] != want {
+			t.Errorf("field %s: expected %q, got %q", name, want, have[name])
+		}
+	}
+	if _, ok := have["MESSAGE_ID"]; ok {
+		t.Error("attribute written as MESSAGE_ID")
+	}
+
+	large := strings.Repeat("x", 1<<20)
+	NewLogger(handler).Log(LevelTrace, "Large", "blob", large)
+	if have := readJournalEntry(t, journald); have["BLOB"] != large || have["PRIORITY"] != "7" {
+		t.Errorf("unexpected large entry: %d bytes, priority %s", len(have["BLOB"]), have["PRIORITY"])
+	}
+}
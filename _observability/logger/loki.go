@@ -0,0 +1,620 @@
+package logger
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// LokiCompression selects the encoding of the requests sent to Loki.
+type LokiCompression int
+
+const (
+	// LokiNoCompression sends plain JSON.
+	LokiNoCompression LokiCompression = iota
+
+	// LokiGzip sends gzipped JSON.
+	LokiGzip
+
+	// LokiSnappy sends snappy compressed protobuf, Loki's native format.
+	LokiSnappy
+)
+
+// LokiOptions configures a LokiHandler.
+type LokiOptions struct {
+	// URL is the push endpoint, e.g. http://loki:3100/loki/api/v1/push.
+	URL string
+
+	// Labels are the static labels attached to every stream, e.g. the
+	// service and node names.
+	Labels map[string]string
+
+	// LabelKeys lists the top level attributes turned into stream labels.
+	// The level of the record is always a label. Characters not allowed in
+	// label names, such as dots and dashes, are replaced with underscores in
+	// these and the static labels. Keep the cardinality of
+	// these attributes low, Loki creates a stream per distinct label set.
+	LabelKeys []string
+
+	// JSON formats the lines as JSON instead of logfmt.
+	JSON bool
+
+	// Compression selects how requests are encoded.
+	Compression LokiCompression
+
+	// BatchSize is the size in bytes of the lines after which a batch is
+	// pushed. Defaults to 1MiB.
+	BatchSize int
+
+	// BatchWait is the maximum time a record waits before being pushed.
+	// Defaults to 1 second.
+	BatchWait time.Duration
+
+	// MaxBufferBytes caps the memory held by records waiting to be pushed,
+	// including while retrying. Once reached, the oldest records are
+	// dropped. Defaults to 16MiB.
+	MaxBufferBytes int
+
+	// MaxRetries is the number of times a failed push is retried, with an
+	// exponential backoff from MinBackoff to MaxBackoff. Defaults to 5
+	// retries, from 100ms up to 5s.
+	MaxRetries int
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// Client sends the requests. Defaults to a client with a 10s timeout.
+	Client *http.Client
+
+	// OnError is called with the error of every batch dropped after its
+	// retries. Nil drops the errors, the records are counted by Dropped
+	// either way.
+	OnError func(error)
+}
+
+// lokiEntry is a formatted record waiting to be pushed.
+type lokiEntry struct {
+	labels string // Stream selector, e.g. {level="info",service="api"}
+	ts     time.Time
+	line   string
+}
+
+// lokiCore holds the state shared by a LokiHandler and the handlers derived
+// from it.
+type lokiCore struct {
+	opts LokiOptions
+
+	fmtLock sync.Mutex    // Serializes the formatting of lines into fmtBuf
+	fmtBuf  *bytes.Buffer // Buffer all the formatting handlers write to
+
+	mu      sync.Mutex
+	pending []lokiEntry
+	size    int // Bytes of the pending lines
+
+	dropped atomic.Uint64
+	kick    chan struct{}
+	flush   chan chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+// LokiHandler is a slog.Handler pushing records to Loki's HTTP push API in
+// batches.
+type LokiHandler struct {
+	core   *lokiCore
+	format slog.Handler      // Formats a record into a line
+	labels map[string]string // Labels collected from attributes
+	groups bool              // Whether a group is open, hiding label keys
+}
+
+// NewLokiHandler returns a handler pushing records to Loki. Call Close on
+// shutdown to push the records still buffered.
+func NewLokiHandler(opts LokiOptions) (*LokiHandler, error) {
+	if opts.URL == "" {
+		return nil, errors.New("loki push url is required")
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 1 << 20
+	}
+	if opts.BatchWait <= 0 {
+		opts.BatchWait = time.Second
+	}
+	if opts.MaxBufferBytes <= 0 {
+		opts.MaxBufferBytes = 16 << 20
+	}
+	if opts.MaxRetries < 0 {
+		opts.MaxRetries = 0
+	} else if opts.MaxRetries == 0 {
+		opts.MaxRetries = 5
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = 100 * time.Millisecond
+	}
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = max(5*time.Second, opts.MinBackoff)
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	core := &lokiCore{
+		opts:   opts,
+		fmtBuf: new(bytes.Buffer),
+		kick:   make(chan struct{}, 1),
+		flush:  make(chan chan struct{}),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	format := LogfmtHandlerWithLevel(core.fmtBuf, levelMaxVerbosity)
+	if opts.JSON {
+		format = JSONHandler(core.fmtBuf)
+	}
+	go core.loop()
+
+	return &LokiHandler{
+		core:   core,
+		format: format,
+		labels: maps.Clone(opts.Labels),
+	}, nil
+}
+
+// Enabled implements slog.Handler. Filtering is left to wrapping handlers.
+func (h *LokiHandler) Enabled(_ context.Context, _ slog.Level) bool {
+	return true
+}
+
+// Handle implements slog.Handler, formatting the record and adding it to the
+// current batch.
+func (h *LokiHandler) Handle(ctx context.Context, r slog.Record) error {
+	labels := h.labels
+	if !h.groups && len(h.core.opts.LabelKeys) > 0 {
+		var cloned bool
+		r.Attrs(func(attr slog.Attr) bool {
+			if slices.Contains(h.core.opts.LabelKeys, attr.Key) {
+				if !cloned {
+					labels, cloned = cloneLabels(h.labels), true
+				}
+				labels[attr.Key] = string(FormatSlogValue(attr.Value, nil))
+			}
+			return true
+		})
+	}
+
+	c := h.core
+	c.fmtLock.Lock()
+	c.fmtBuf.Reset()
+	err := h.format.Handle(ctx, r)
+	line := strings.TrimSuffix(c.fmtBuf.String(), "\n")
+	c.fmtLock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	c.add(lokiEntry{
+		labels: streamSelector(labels, LevelString(r.Level)),
+		ts:     r.Time,
+		line:   line,
+	})
+	return nil
+}
+
+// WithAttrs implements slog.Handler. Attributes listed in LabelKeys become
+// labels of all the records of the returned handler.
+func (h *LokiHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	res := *h
+	res.format = h.format.WithAttrs(attrs)
+	if !h.groups {
+		var cloned bool
+		for _, attr := range attrs {
+			if slices.Contains(h.core.opts.LabelKeys, attr.Key) {
+				if !cloned {
+					res.labels, cloned = cloneLabels(h.labels), true
+				}
+				res.labels[attr.Key] = string(FormatSlogValue(attr.Value, nil))
+			}
+		}
+	}
+	return &res
+}
+
+// WithGroup implements slog.Handler. Attributes inside groups never become
+// labels.
+func (h *LokiHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	res := *h
+	res.format = h.format.WithGroup(name)
+	res.groups = true
+	return &res
+}
+
+// Dropped returns the number of records discarded because the memory cap
+// was reached or Loki kept rejecting them.
+func (h *LokiHandler) Dropped() uint64 {
+	return h.core.dropped.Load()
+}
+
+// Flush pushes the buffered records and waits for the push to complete.
+func (h *LokiHandler) Flush() {
+	ack := make(chan struct{})
+	select {
+	case h.core.flush <- ack:
+		<-ack
+	case <-h.core.done:
+	}
+}
+
+// Close pushes the buffered records and stops the background goroutine. It
+// affects all the handlers derived from the same NewLokiHandler call.
+func (h *LokiHandler) Close() error {
+	h.core.once.Do(func() {
+		close(h.core.stop)
+	})
+	<-h.core.done
+	return nil
+}
+
+// add buffers an entry, dropping the oldest ones beyond the memory cap.
+// Entries added after Close are dropped.
+func (c *lokiCore) add(e lokiEntry) {
+	select {
+	case <-c.done:
+		c.dropped.Add(1)
+		return
+	default:
+	}
+	c.mu.Lock()
+	c.pending = append(c.pending, e)
+	c.size += len(e.line)
+	c.trim()
+	full := c.size >= c.opts.BatchSize
+	c.mu.Unlock()
+
+	if full {
+		select {
+		case c.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// trim drops the oldest entries beyond the memory cap. It must be called
+// with the lock held.
+func (c *lokiCore) trim() {
+	var n int
+	for c.size > c.opts.MaxBufferBytes && n < len(c.pending) {
+		c.size -= len(c.pending[n].line)
+		n++
+	}
+	if n > 0 {
+		c.pending = slices.Delete(c.pending, 0, n)
+		c.dropped.Add(uint64(n))
+	}
+}
+
+// loop pushes batches when they are full or old enough.
+func (c *lokiCore) loop() {
+	defer close(c.done)
+
+	ticker := time.NewTicker(c.opts.BatchWait)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			c.pushAll()
+			return
+		case ack := <-c.flush:
+			c.pushAll()
+			close(ack)
+		case <-c.kick:
+			c.pushAll()
+		case <-ticker.C:
+			c.pushAll()
+		}
+	}
+}
+
+// pushAll pushes everything that is buffered, one batch at a time.
+func (c *lokiCore) pushAll() {
+	for {
+		c.mu.Lock()
+		var n, size int
+		for n < len(c.pending) && (n == 0 || size+len(c.pending[n].line) <= c.opts.BatchSize) {
+			size += len(c.pending[n].line)
+			n++
+		}
+		batch := slices.Clone(c.pending[:n])
+		c.mu.Unlock()
+
+		if len(batch) == 0 {
+			return
+		}
+
+		err := c.pushWithRetry(batch)
+
+		// The entries stay buffered while retrying, so they count against
+		// the memory cap and may have been trimmed in the meantime.
+		c.mu.Lock()
+		n = 0
+		for n < len(c.pending) && n < len(batch) && c.pending[n] == batch[n] {
+			c.size -= len(c.pending[n].line)
+			n++
+		}
+		c.pending = slices.Delete(c.pending, 0, n)
+		c.mu.Unlock()
+
+		if err != nil {
+			c.dropped.Add(uint64(len(batch)))
+			if c.opts.OnError != nil {
+				c.opts.OnError(fmt.Errorf("pushing to loki: %w", err))
+			}
+		}
+	}
+}
+
+// pushWithRetry pushes a batch, retrying with an exponential backoff on
+// network errors, throttling and server errors.
+func (c *lokiCore) pushWithRetry(batch []lokiEntry) error {
+	body, contentType, encoding, err := c.encode(batch)
+	if err != nil {
+		return err
+	}
+	backoff := c.opts.MinBackoff
+	for attempt := 0; ; attempt++ {
+		retry, err := c.push(body, contentType, encoding)
+		if err == nil || !retry || attempt == c.opts.MaxRetries {
+			return err
+		}
+		select {
+		case <-time.After(backoff):
+		case <-c.stop:
+			// Make one last attempt without waiting when closing.
+			_, err = c.push(body, contentType, encoding)
+			return err
+		}
+		backoff = min(2*backoff, c.opts.MaxBackoff)
+	}
+}
+
+// push sends a single request. It reports whether a failure is worth
+// retrying.
+func (c *lokiCore) push(body []byte, contentType, encoding string) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, c.opts.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+	resp, err := c.opts.Client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 == 2 {
+		io.Copy(io.Discard, resp.Body)
+		return false, nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	err = fmt.Errorf("loki responded %s: %s", resp.Status, bytes.TrimSpace(msg))
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode/100 == 5, err
+}
+
+// encode builds the request body of a batch, grouping the entries by stream.
+func (c *lokiCore) encode(batch []lokiEntry) ([]byte, string, string, error) {
+	var selectors []string
+	streams := make(map[string][]lokiEntry)
+	for _, e := range batch {
+		if _, exists := streams[e.labels]; !exists {
+			selectors = append(selectors, e.labels)
+		}
+		streams[e.labels] = append(streams[e.labels], e)
+	}
+
+	if c.opts.Compression == LokiSnappy {
+		return snappy.Encode(nil, encodeLokiProto(selectors, streams)), "application/x-protobuf", "", nil
+	}
+
+	body, err := encodeLokiJSON(selectors, streams)
+	if err != nil {
+		return nil, "", "", err
+	}
+	if c.opts.Compression != LokiGzip {
+		return body, "application/json", "", nil
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(body)
+	if err := zw.Close(); err != nil {
+		return nil, "", "", err
+	}
+	return buf.Bytes(), "application/json", "gzip", nil
+}
+
+// encodeLokiJSON encodes the streams in the JSON push format:
+//
+//	{"streams": [{"stream": {"label": "value"}, "values": [["<unix ns>", "<line>"]]}]}
+func encodeLokiJSON(selectors []string, streams map[string][]lokiEntry) ([]byte, error) {
+	type stream struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	}
+	req := struct {
+		Streams []stream `json:"streams"`
+	}{}
+	for _, sel := range selectors {
+		s := stream{Stream: parseStreamSelector(sel)}
+		for _, e := range streams[sel] {
+			s.Values = append(s.Values, [2]string{strconv.FormatInt(e.ts.UnixNano(), 10), e.line})
+		}
+		req.Streams = append(req.Streams, s)
+	}
+	return json.Marshal(req)
+}
+
+// encodeLokiProto encodes the streams as a logproto.PushRequest:
+//
+//	message PushRequest { repeated Stream streams = 1; }
+//	message Stream { string labels = 1; repeated Entry entries = 2; }
+//	message Entry { google.protobuf.Timestamp timestamp = 1; string line = 2; }
+func encodeLokiProto(selectors []string, streams map[string][]lokiEntry) []byte {
+	var req []byte
+	for _, sel := range selectors {
+		var stream []byte
+		stream = protowire.AppendTag(stream, 1, protowire.BytesType)
+		stream = protowire.AppendString(stream, sel)
+		for _, e := range streams[sel] {
+			var ts []byte
+			ts = protowire.AppendTag(ts, 1, protowire.VarintType)
+			ts = protowire.AppendVarint(ts, uint64(e.ts.Unix()))
+			ts = protowire.AppendTag(ts, 2, protowire.VarintType)
+			ts = protowire.AppendVarint(ts, uint64(e.ts.Nanosecond()))
+
+			var entry []byte
+			entry = protowire.AppendTag(entry, 1, protowire.BytesType)
+			entry = protowire.AppendBytes(entry, ts)
+			entry = protowire.AppendTag(entry, 2, protowire.BytesType)
+			entry = protowire.AppendString(entry, e.line)
+
+			stream = protowire.AppendTag(stream, 2, protowire.BytesType)
+			stream = protowire.AppendBytes(stream, entry)
+		}
+		req = protowire.AppendTag(req, 1, protowire.BytesType)
+		req = protowire.AppendBytes(req, stream)
+	}
+	return req
+}
+
+// streamSelector renders the labels, plus the level, in the Prometheus
+// format Loki uses to identify streams: {key="value", ...}, sorted by key.
+// Keys are made valid label names with lokiLabelName; of the keys giving the
+// same name, the first in sort order is kept.
+func streamSelector(labels map[string]string, level string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	values := map[string]string{"level": level}
+	names := make([]string, 0, len(keys))
+	for _, k := range keys {
+		name := lokiLabelName(k)
+		if _, dup := values[name]; dup {
+			continue
+		}
+		values[name] = labels[k]
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var b strings.Builder
+	b.WriteByte('{')
+	b.WriteString(`level=`)
+	b.WriteString(quoteLabelValue(level))
+	for _, name := range names {
+		b.WriteByte(',')
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(quoteLabelValue(values[name]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// lokiLabelName makes a key a valid label name, matching
+// [a-zA-Z_][a-zA-Z0-9_]*, by replacing the other characters with
+// underscores, e.g. "trace.id" becomes trace_id. Names can't start with a
+// digit, an underscore is prepended to those.
+func lokiLabelName(key string) string {
+	name := []byte(key)
+	for i, c := range name {
+		if c != '_' && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			name[i] = '_'
+		}
+	}
+	if len(name) == 0 || name[0] >= '0' && name[0] <= '9' {
+		return "_" + string(name)
+	}
+	return string(name)
+}
+
+// labelValueEscaper escapes label values the way Prometheus does.
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// quoteLabelValue quotes a label value for a stream selector. Only
+// backslashes, double quotes and newlines are escaped, other characters,
+// including non-ASCII and control ones, are kept as is.
+func quoteLabelValue(value string) string {
+	return `"` + labelValueEscaper.Replace(value) + `"`
+}
+
+// unquoteLabelValue is the inverse of quoteLabelValue for the quoted value at
+// the start of s. It returns the value and the length of its quoted form.
+func unquoteLabelValue(s string) (string, int, bool) {
+	if !strings.HasPrefix(s, `"`) {
+		return "", 0, false
+	}
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"':
+			return b.String(), i + 1, true
+		case '\\':
+			if i++; i == len(s) {
+				return "", 0, false
+			}
+			if s[i] == 'n' {
+				b.WriteByte('\n')
+			} else {
+				b.WriteByte(s[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, false
+}
+
+// parseStreamSelector is the inverse of streamSelector.
+func parseStreamSelector(sel string) map[string]string {
+	labels := make(map[string]string)
+	sel = strings.TrimSuffix(strings.TrimPrefix(sel, "{"), "}")
+	for sel != "" {
+		key, rest, _ := strings.Cut(sel, "=")
+		value, n, ok := unquoteLabelValue(rest)
+		if !ok {
+			break
+		}
+		labels[key] = value
+		sel = strings.TrimPrefix(rest[n:], ",")
+	}
+	return labels
+}
+
+// cloneLabels returns a copy of the labels that can be modified.
+func cloneLabels(labels map[string]string) map[string]string {
+	res := make(map[string]string, len(labels)+1)
+	maps.Copy(res, labels)
+	return res
+}
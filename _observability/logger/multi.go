@@ -0,0 +1,110 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+)
+
+// Sink is one of the destinations of a MultiHandler.
+type Sink struct {
+	// Handler formats and writes the records sent to this sink.
+	Handler slog.Handler
+
+	// Level is the minimum level of the records sent to this sink. When nil,
+	// the decision is left to the handler alone.
+	Level slog.Leveler
+
+	// Filter, when set, must return true for a record to be sent to this
+	// sink.
+	Filter func(context.Context, slog.Record) bool
+}
+
+// MultiHandler is a slog.Handler sending every record to several sinks, each
+// with its own level, format and filter. For example, a colored terminal at
+// debug, JSON to a file at info and errors to a remote collector.
+type MultiHandler struct {
+	sinks []Sink
+
+	// floor is the lowest level any sink accepts, used to reject records in
+	// Enabled without asking every sink. It is only known when all the sink
+	// levels are fixed.
+	floor slog.Level
+}
+
+// NewMultiHandler returns a handler fanning out records to the given sinks.
+func NewMultiHandler(sinks ...Sink) *MultiHandler {
+	h := &MultiHandler{
+		sinks: sinks,
+		floor: LevelCrit + 1,
+	}
+	for _, s := range sinks {
+		lvl, fixed := s.Level.(slog.Level)
+		if !fixed {
+			h.floor = levelMaxVerbosity
+			break
+		}
+		h.floor = min(h.floor, lvl)
+	}
+	return h
+}
+
+// Enabled implements slog.Handler, reporting whether any sink handles records
+// at the given level.
+func (h *MultiHandler) Enabled(ctx context.Context, lvl slog.Level) bool {
+	if lvl < h.floor {
+		return false
+	}
+	for i := range h.sinks {
+		if h.sinks[i].enabled(ctx, lvl) {
+			return true
+		}
+	}
+	return false
+}
+
+// Handle implements slog.Handler, sending the record to every sink whose
+// level and filter accept it. Errors from the sinks are joined.
+func (h *MultiHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for i := range h.sinks {
+		s := &h.sinks[i]
+		if !s.enabled(ctx, r.Level) {
+			continue
+		}
+		if s.Filter != nil && !s.Filter(ctx, r) {
+			continue
+		}
+		if err := s.Handler.Handle(ctx, r.Clone()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// WithAttrs implements slog.Handler, adding the attributes to every sink.
+func (h *MultiHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	sinks := make([]Sink, len(h.sinks))
+	for i, s := range h.sinks {
+		s.Handler = s.Handler.WithAttrs(attrs)
+		sinks[i] = s
+	}
+	return NewMultiHandler(sinks...)
+}
+
+// WithGroup implements slog.Handler, opening the group in every sink.
+func (h *MultiHandler) WithGroup(name string) slog.Handler {
+	sinks := make([]Sink, len(h.sinks))
+	for i, s := range h.sinks {
+		s.Handler = s.Handler.WithGroup(name)
+		sinks[i] = s
+	}
+	return NewMultiHandler(sinks...)
+}
+
+func (s *Sink) enabled(ctx context.Context, lvl slog.Level) bool {
+	if s.Level != nil && lvl < s.Level.Level() {
+		return false
+	}
+	return s.Handler.Enabled(ctx, lvl)
+}
@@ -0,0 +1,219 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errNotRecord is returned when a line is neither JSON nor logfmt output.
+var errNotRecord = errors.New("not a JSON or logfmt log record")
+
+// ParseRecord parses a line written by JSONHandler or LogfmtHandler back into
+// a record, so it can be filtered and rendered again, e.g. by a
+// TerminalHandler. Attributes inside groups are flattened to dotted keys, as
+// logfmt writes them. The source location of the record is lost.
+func ParseRecord(line []byte) (slog.Record, error) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return slog.Record{}, errNotRecord
+	}
+	var (
+		attrs []slog.Attr
+		err   error
+	)
+	if line[0] == '{' {
+		attrs, err = parseJSONAttrs(line)
+	} else {
+		attrs, err = parseLogfmtAttrs(line)
+	}
+	if err != nil {
+		return slog.Record{}, err
+	}
+
+	var (
+		r                         slog.Record
+		hasTime, hasLevel, hasMsg bool
+		others                    = make([]slog.Attr, 0, len(attrs))
+	)
+	r.Level = slog.LevelInfo
+	for _, attr := range attrs {
+		switch {
+		case attr.Key == "t" && !hasTime:
+			if t, ok := parseRecordTime(attr.Value); ok {
+				r.Time, hasTime = t, true
+				continue
+			}
+		case attr.Key == "lvl" && !hasLevel:
+			if lvl, err := ParseLevel(attr.Value.String()); err == nil {
+				r.Level, hasLevel = lvl, true
+				continue
+			}
+		case attr.Key == "msg" && !hasMsg:
+			if attr.Value.Kind() == slog.KindString {
+				r.Message, hasMsg = attr.Value.String(), true
+				continue
+			}
+		}
+		others = append(others, attr)
+	}
+	if !hasTime && !hasLevel && !hasMsg {
+		return slog.Record{}, errNotRecord
+	}
+	r.AddAttrs(others...)
+	return r, nil
+}
+
+// parseRecordTime parses the time of a record in either output format.
+func parseRecordTime(v slog.Value) (time.Time, bool) {
+	if v.Kind() != slog.KindString {
+		return time.Time{}, false
+	}
+	for _, layout := range []string{time.RFC3339Nano, timeFormat} {
+		if t, err := time.Parse(layout, v.String()); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseJSONAttrs parses a JSON object, keeping the order of its keys.
+func parseJSONAttrs(line []byte) ([]slog.Attr, error) {
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+
+	attrs, err := decodeJSONObject(dec, "", nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errNotRecord, err)
+	}
+	return attrs, nil
+}
+
+// decodeJSONObject decodes the object the decoder is at, appending its
+// members to dst with their keys qualified by prefix.
+func decodeJSONObject(dec *json.Decoder, prefix string, dst []slog.Attr) ([]slog.Attr, error) {
+	if tok, err := dec.Token(); err != nil {
+		return nil, err
+	} else if tok != json.Delim('{') {
+		return nil, errors.New("expected object")
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key := prefix + tok.(string)
+
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, err
+		}
+		if len(raw) > 0 && raw[0] == '{' {
+			sub := json.NewDecoder(bytes.NewReader(raw))
+			sub.UseNumber()
+			if dst, err = decodeJSONObject(sub, key+".", dst); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		var v any
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, err
+		}
+		dst = append(dst, jsonAttr(key, v, raw))
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	return dst, nil
+}
+
+// jsonAttr converts a decoded JSON value to an attribute.
+func jsonAttr(key string, v any, raw json.RawMessage) slog.Attr {
+	switch v := v.(type) {
+	case string:
+		return slog.String(key, v)
+	case bool:
+		return slog.Bool(key, v)
+	case float64:
+		if n, err := strconv.ParseInt(string(raw), 10, 64); err == nil {
+			return slog.Int64(key, n)
+		}
+		if n, err := strconv.ParseUint(string(raw), 10, 64); err == nil {
+			return slog.Uint64(key, n)
+		}
+		return slog.Float64(key, v)
+	case nil:
+		return slog.Any(key, nil)
+	default:
+		// Arrays are kept as they were written.
+		return slog.String(key, string(raw))
+	}
+}
+
+// parseLogfmtAttrs parses key=value pairs as written by slog's text handler,
+// where keys and values are quoted when needed.
+func parseLogfmtAttrs(line []byte) ([]slog.Attr, error) {
+	var attrs []slog.Attr
+	s := string(line)
+	for {
+		s = trimLeftSpace(s)
+		if s == "" {
+			return attrs, nil
+		}
+		key, rest, err := logfmtToken(s, true)
+		if err != nil || key == "" || rest == "" || rest[0] != '=' {
+			return nil, errNotRecord
+		}
+		value, rest, err := logfmtToken(rest[1:], false)
+		if err != nil {
+			return nil, errNotRecord
+		}
+		attrs = append(attrs, logfmtAttr(key, value))
+		s = rest
+	}
+}
+
+// logfmtToken reads a quoted or bare token, ending at a space or, for keys,
+// at an equal sign.
+func logfmtToken(s string, key bool) (string, string, error) {
+	if s != "" && s[0] == '"' {
+		quoted, err := strconv.QuotedPrefix(s)
+		if err != nil {
+			return "", "", err
+		}
+		token, err := strconv.Unquote(quoted)
+		return token, s[len(quoted):], err
+	}
+	end := 0
+	for end < len(s) && s[end] != ' ' && !(key && s[end] == '=') {
+		end++
+	}
+	return s[:end], s[end:], nil
+}
+
+// logfmtAttr types a logfmt value, which is always written as text.
+func logfmtAttr(key, value string) slog.Attr {
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return slog.Int64(key, n)
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil && strings.ContainsAny(value, ".eE") {
+		return slog.Float64(key, f)
+	}
+	if b, err := strconv.ParseBool(value); err == nil && (value == "true" || value == "false") {
+		return slog.Bool(key, b)
+	}
+	return slog.String(key, value)
+}
+
+func trimLeftSpace(s string) string {
+	for s != "" && s[0] == ' ' {
+		s = s[1:]
+	}
+	return s
+}
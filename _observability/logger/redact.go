@@ -0,0 +1,224 @@
+package logger
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Redactable is implemented by types carrying identity data, such as names,
+// claims or keys, to control how they are rendered in logs. RedactHandler
+// logs the returned value instead of the original one.
+type Redactable interface {
+	Redact() slog.Value
+}
+
+// Detector finds sensitive data inside string values by pattern. Matches are
+// replaced by "<redacted:Name>".
+type Detector struct {
+	Name    string
+	Pattern *regexp.Regexp
+}
+
+// DefaultDetectors returns detectors for email addresses, 32 byte hex strings
+// that look like secp256k1 or ed25519 private keys, and PEM private keys.
+//
+// Note, only strings and errors are scanned: hashes and addresses logged as
+// their own types, e.g. common.Hash, are left alone.
+func DefaultDetectors() []Detector {
+	return []Detector{
+		{"email", regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)},
+		{"pem", regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`)},
+		{"hexkey", regexp.MustCompile(`\b(0x)?[0-9a-fA-F]{64}\b`)},
+	}
+}
+
+// RedactOptions configures the rules applied by a RedactHandler. Keys are
+// matched case-insensitively, either by their name alone ("email") or by
+// their full dotted path including groups ("holder.email").
+type RedactOptions struct {
+	// DropKeys lists the attributes removed from records.
+	DropKeys []string
+
+	// HashKeys lists the attributes whose values are replaced by a keyed
+	// HMAC-SHA256, so the same value can still be correlated across records
+	// without being revealed.
+	HashKeys []string
+
+	// HashSecret is the HMAC key used for HashKeys, required when HashKeys
+	// isn't empty. It must be kept secret, otherwise low entropy values such
+	// as emails can be brute forced.
+	HashSecret []byte
+
+	// Detectors are applied to the message and to every string and error
+	// value that was not dropped or hashed.
+	Detectors []Detector
+}
+
+// RedactHandler is a slog.Handler that removes identity data from records
+// before handing them to the wrapped handler. As it rewrites the attributes
+// themselves, it works with any output format.
+type RedactHandler struct {
+	origin    slog.Handler
+	drop      map[string]struct{}
+	hash      map[string]struct{}
+	secret    []byte
+	detectors []Detector
+	groups    []string
+}
+
+// NewRedactHandler returns a handler applying the redaction rules to every
+// record before passing it to h. It fails if keys are hashed without a
+// secret.
+func NewRedactHandler(h slog.Handler, opts RedactOptions) (*RedactHandler, error) {
+	if len(opts.HashKeys) > 0 && len(opts.HashSecret) == 0 {
+		return nil, errors.New("hashing keys requires a hash secret")
+	}
+	keySet := func(keys []string) map[string]struct{} {
+		set := make(map[string]struct{}, len(keys))
+		for _, k := range keys {
+			set[strings.ToLower(k)] = struct{}{}
+		}
+		return set
+	}
+	return &RedactHandler{
+		origin:    h,
+		drop:      keySet(opts.DropKeys),
+		hash:      keySet(opts.HashKeys),
+		secret:    slices.Clone(opts.HashSecret),
+		detectors: slices.Clone(opts.Detectors),
+	}, nil
+}
+
+// Enabled implements slog.Handler, deferring to the wrapped handler.
+func (h *RedactHandler) Enabled(ctx context.Context, lvl slog.Level) bool {
+	return h.origin.Enabled(ctx, lvl)
+}
+
+// Handle implements slog.Handler, redacting the message and attributes of the
+// record before passing it on.
+func (h *RedactHandler) Handle(ctx context.Context, r slog.Record) error {
+	res := slog.NewRecord(r.Time, r.Level, h.scrub(r.Message), r.PC)
+	r.Attrs(func(attr slog.Attr) bool {
+		if attr, keep := h.redact(h.groups, attr); keep {
+			res.AddAttrs(attr)
+		}
+		return true
+	})
+	return h.origin.Handle(ctx, res)
+}
+
+// WithAttrs implements slog.Handler, redacting the attributes once so they
+// don't have to be checked on every record.
+func (h *RedactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, 0, len(attrs))
+	for _, attr := range attrs {
+		if attr, keep := h.redact(h.groups, attr); keep {
+			redacted = append(redacted, attr)
+		}
+	}
+	res := *h
+	res.origin = h.origin.WithAttrs(redacted)
+	return &res
+}
+
+// WithGroup implements slog.Handler, keeping track of the group so keys can
+// be matched by their full path.
+func (h *RedactHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	res := *h
+	res.origin = h.origin.WithGroup(name)
+	res.groups = append(slices.Clip(h.groups), name)
+	return &res
+}
+
+// redact applies the rules to a single attribute. It reports false if the
+// attribute must be dropped.
+func (h *RedactHandler) redact(groups []string, attr slog.Attr) (slog.Attr, bool) {
+	path := attr.Key
+	if len(groups) > 0 {
+		path = strings.Join(groups, ".") + "." + attr.Key
+	}
+	if h.matches(h.drop, attr.Key, path) {
+		return attr, false
+	}
+
+	// Let the type decide how it's logged before looking at the value, or
+	// resolving it: a LogValuer could otherwise reveal what Redact hides. Only
+	// these two kinds can hold a type implementing Redactable.
+	if kind := attr.Value.Kind(); kind == slog.KindAny || kind == slog.KindLogValuer {
+		if r, ok := attr.Value.Any().(Redactable); ok {
+			attr.Value = r.Redact()
+		}
+	}
+	attr.Value = attr.Value.Resolve()
+
+	if h.matches(h.hash, attr.Key, path) {
+		attr.Value = slog.StringValue(h.hmac(attr.Value))
+		return attr, true
+	}
+
+	switch attr.Value.Kind() {
+	case slog.KindGroup:
+		if attr.Key != "" {
+			groups = append(slices.Clip(groups), attr.Key)
+		}
+		members := attr.Value.Group()
+		redacted := make([]slog.Attr, 0, len(members))
+		for _, ga := range members {
+			if ga, keep := h.redact(groups, ga); keep {
+				redacted = append(redacted, ga)
+			}
+		}
+		attr.Value = slog.GroupValue(redacted...)
+
+	case slog.KindString:
+		s := attr.Value.String()
+		if scrubbed := h.scrub(s); scrubbed != s {
+			attr.Value = slog.StringValue(scrubbed)
+		}
+
+	case slog.KindAny:
+		if err, ok := attr.Value.Any().(error); ok && err != nil {
+			s := err.Error()
+			if scrubbed := h.scrub(s); scrubbed != s {
+				attr.Value = slog.StringValue(scrubbed)
+			}
+		}
+	}
+	return attr, true
+}
+
+func (h *RedactHandler) matches(set map[string]struct{}, key, path string) bool {
+	if len(set) == 0 {
+		return false
+	}
+	if _, ok := set[strings.ToLower(key)]; ok {
+		return true
+	}
+	_, ok := set[strings.ToLower(path)]
+	return ok
+}
+
+// scrub replaces every match of the detectors in s.
+func (h *RedactHandler) scrub(s string) string {
+	for _, d := range h.detectors {
+		s = d.Pattern.ReplaceAllLiteralString(s, "<redacted:"+d.Name+">")
+	}
+	return s
+}
+
+// hmac returns a short keyed digest of the value, stable across records.
+func (h *RedactHandler) hmac(v slog.Value) string {
+	mac := hmac.New(sha256.New, h.secret)
+	mac.Write(FormatSlogValue(v, nil))
+	return "hmac:" + hex.EncodeToString(mac.Sum(nil)[:8])
+}
@@ -0,0 +1,269 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultRingSize is the number of records a RingHandler keeps when no size
+// is configured.
+const defaultRingSize = 4096
+
+// RingQuery selects records kept by a RingHandler. The zero value matches
+// all of them.
+type RingQuery struct {
+	// Level is the minimum level of the records, all levels when nil.
+	Level slog.Leveler
+
+	// Since and Until bound the time of the records, when not zero.
+	Since time.Time
+	Until time.Time
+
+	// Message is a substring the message must contain.
+	Message string
+
+	// Attrs are the attribute values the records must have, by key. Keys
+	// inside groups are dotted, e.g. "req.id".
+	Attrs map[string]string
+
+	// Limit keeps the most recent records only, when positive.
+	Limit int
+}
+
+// ringBuffer holds the records shared by a RingHandler and the handlers
+// derived from it.
+type ringBuffer struct {
+	mu      sync.RWMutex
+	records []slog.Record
+	next    int  // Index the next record is written to
+	full    bool // Whether the buffer wrapped around
+}
+
+// RingHandler is a slog.Handler keeping the most recent records in memory,
+// along with their attributes, so they can be inspected on a misbehaving
+// node without shipping logs anywhere. It is also an http.Handler serving
+// queries over the records kept:
+//
+//	GET ?level=warn&since=10m&msg=peer&attr=peer=p1&limit=100&format=text
+//
+// where since and until are RFC 3339 times or durations before now, attr can
+// be repeated, and format is json (the default, one object per line) or text
+// (terminal format).
+type RingHandler struct {
+	buf   *ringBuffer
+	attrs []slog.Attr // Attributes of WithAttrs, flattened to dotted keys
+	group string      // Dotted prefix of the groups opened with WithGroup
+}
+
+// NewRingHandler returns a handler keeping the last size records. Zero keeps
+// 4096 of them.
+func NewRingHandler(size int) *RingHandler {
+	if size <= 0 {
+		size = defaultRingSize
+	}
+	return &RingHandler{
+		buf: &ringBuffer{records: make([]slog.Record, size)},
+	}
+}
+
+// Enabled implements slog.Handler. All records are kept, filtering is left to
+// wrapping handlers.
+func (h *RingHandler) Enabled(_ context.Context, _ slog.Level) bool {
+	return true
+}
+
+// Handle implements slog.Handler, storing the record with its attributes
+// flattened, in place of the oldest one when the buffer is full.
+func (h *RingHandler) Handle(_ context.Context, r slog.Record) error {
+	rec := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	rec.AddAttrs(flattenRecord(h.attrs, h.group, r)...)
+
+	b := h.buf
+	b.mu.Lock()
+	b.records[b.next] = rec
+	b.next = (b.next + 1) % len(b.records)
+	if b.next == 0 {
+		b.full = true
+	}
+	b.mu.Unlock()
+	return nil
+}
+
+// WithAttrs implements slog.Handler. The returned handler shares the buffer of
+// the receiver.
+func (h *RingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &RingHandler{
+		buf:   h.buf,
+		attrs: appendFlattenedAttrs(h.attrs, h.group, attrs),
+		group: h.group,
+	}
+}
+
+// WithGroup implements slog.Handler. The returned handler shares the buffer of
+// the receiver.
+func (h *RingHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &RingHandler{
+		buf:   h.buf,
+		attrs: slices.Clip(h.attrs),
+		group: h.group + name + ".",
+	}
+}
+
+// Records returns the records matching the query, oldest first.
+func (h *RingHandler) Records(q RingQuery) []slog.Record {
+	b := h.buf
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var res []slog.Record
+	n, start := b.next, 0
+	if b.full {
+		n, start = len(b.records), b.next
+	}
+	// Walk from the most recent record so the limit is cheap.
+	for i := n - 1; i >= 0; i-- {
+		r := b.records[(start+i)%len(b.records)]
+		if !q.Match(r) {
+			continue
+		}
+		res = append(res, r.Clone())
+		if q.Limit > 0 && len(res) == q.Limit {
+			break
+		}
+	}
+	slices.Reverse(res)
+	return res
+}
+
+// ServeHTTP implements http.Handler, returning the records matching the query
+// parameters.
+func (h *RingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q, err := parseRingQuery(r, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var (
+		out         bytes.Buffer
+		format      slog.Handler
+		contentType string
+	)
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+		format, contentType = JSONHandler(&out), "application/x-ndjson"
+	case "text":
+		format, contentType = NewTerminalHandler(&out, false), "text/plain; charset=utf-8"
+	default:
+		http.Error(w, "format must be json or text", http.StatusBadRequest)
+		return
+	}
+	for _, rec := range h.Records(q) {
+		format.Handle(r.Context(), rec)
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write(out.Bytes())
+}
+
+// Match reports whether a record is selected by the query.
+func (q *RingQuery) Match(r slog.Record) bool {
+	if q.Level != nil && r.Level < q.Level.Level() {
+		return false
+	}
+	if !q.Since.IsZero() && r.Time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && r.Time.After(q.Until) {
+		return false
+	}
+	if q.Message != "" && !strings.Contains(r.Message, q.Message) {
+		return false
+	}
+	if len(q.Attrs) == 0 {
+		return true
+	}
+	found := make(map[string]bool, len(q.Attrs))
+	r.Attrs(func(attr slog.Attr) bool {
+		if want, ok := q.Attrs[attr.Key]; ok && attrString(attr.Value) == want {
+			found[attr.Key] = true
+		}
+		return len(found) < len(q.Attrs)
+	})
+	return len(found) == len(q.Attrs)
+}
+
+// attrString renders a value the way it's compared against in queries.
+func attrString(v slog.Value) string {
+	if v.Kind() == slog.KindString {
+		return v.String()
+	}
+	return string(FormatSlogValue(v, nil))
+}
+
+// parseRingQuery builds a query from the parameters of a request.
+func parseRingQuery(r *http.Request, now time.Time) (RingQuery, error) {
+	params := r.URL.Query()
+
+	var (
+		q   RingQuery
+		err error
+	)
+	if s := params.Get("level"); s != "" {
+		lvl, err := ParseLevel(s)
+		if err != nil {
+			return q, err
+		}
+		q.Level = lvl
+	}
+	if q.Since, err = ParseTime(params.Get("since"), now); err != nil {
+		return q, fmt.Errorf("invalid since: %w", err)
+	}
+	if q.Until, err = ParseTime(params.Get("until"), now); err != nil {
+		return q, fmt.Errorf("invalid until: %w", err)
+	}
+	q.Message = params.Get("msg")
+	for _, attr := range params["attr"] {
+		key, value, found := strings.Cut(attr, "=")
+		if !found || key == "" {
+			return q, fmt.Errorf("invalid attr %q, expect key=value", attr)
+		}
+		if q.Attrs == nil {
+			q.Attrs = make(map[string]string)
+		}
+		q.Attrs[key] = value
+	}
+	if s := params.Get("limit"); s != "" {
+		if q.Limit, err = strconv.Atoi(s); err != nil || q.Limit < 0 {
+			return q, fmt.Errorf("invalid limit %q", s)
+		}
+	}
+	return q, nil
+}
+
+// ParseTime parses a time bound of a RingQuery, given as an RFC 3339 time or
+// a duration before now, e.g. 15m. An empty string is the zero time, no
+// bound.
+func ParseTime(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
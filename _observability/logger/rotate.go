@@ -0,0 +1,423 @@
+package logger
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// backupTimeFormat is used in the names of rotated files. It sorts in
+// chronological order and holds no characters that need escaping.
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// rotateRetryDelay is how long writes go on to the current file after a
+// failed rotation before rotating is tried again.
+const rotateRetryDelay = time.Minute
+
+// rotatedBacklog is the number of rotated files waiting to be compressed and
+// pruned before rotations block.
+const rotatedBacklog = 16
+
+// RotateOptions configures when a RotatingFile rotates and which of the
+// rotated files it keeps.
+type RotateOptions struct {
+	// MaxSize is the size in bytes the file can grow to before it's rotated.
+	// Zero disables size based rotation.
+	MaxSize int64
+
+	// Interval rotates the file at every multiple of the interval in local
+	// time, e.g. every hour on the hour or every day at midnight. Zero
+	// disables time based rotation.
+	Interval time.Duration
+
+	// MaxBackups is the number of rotated files to keep. Zero keeps all.
+	MaxBackups int
+
+	// Compress gzips rotated files in the background.
+	Compress bool
+
+	// OnError is called with the errors that can't be returned to a caller,
+	// such as a failed compression or reopening on SIGHUP. It can be called
+	// concurrently. Nil drops them.
+	OnError func(error)
+}
+
+// RotatingFile is an io.Writer appending to a file that is rotated by size
+// and/or time. Rotated files are renamed with a timestamp, e.g. node.log
+// becomes node-2024-07-15T10-30-00.000.log, optionally compressed, and the
+// oldest ones are removed. It is safe for concurrent use, so several handlers
+// can share one.
+type RotatingFile struct {
+	path string
+	opts RotateOptions
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	rotate time.Time // Next time based rotation, zero if disabled
+	retry  time.Time // No automatic rotation before, set when one failed
+
+	rotated chan string   // Rotated files to compress and prune, nil if neither is enabled
+	done    chan struct{} // Closed once the rotated files are all handled
+}
+
+// NewRotatingFile opens, or creates, the file at path for appending.
+func NewRotatingFile(path string, opts RotateOptions) (*RotatingFile, error) {
+	if opts.MaxSize < 0 || opts.Interval < 0 || opts.MaxBackups < 0 {
+		return nil, errors.New("rotate options can't be negative")
+	}
+	f := &RotatingFile{
+		path: path,
+		opts: opts,
+	}
+	file, size, err := f.open()
+	if err != nil {
+		return nil, err
+	}
+	f.use(file, size)
+	if opts.Compress || opts.MaxBackups > 0 {
+		f.rotated = make(chan string, rotatedBacklog)
+		f.done = make(chan struct{})
+		go f.cleanup()
+	}
+	return f, nil
+}
+
+// Write implements io.Writer, rotating the file first if the write would
+// exceed the maximum size or the rotation interval has elapsed. If the
+// rotation fails, the error goes to OnError and p is written to the current
+// file, rotating is only tried again after a delay.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.due(int64(len(p))) {
+		if err := f.rotateLocked(); err != nil {
+			f.retry = time.Now().Add(rotateRetryDelay)
+			f.reportError(fmt.Errorf("rotating %s: %w", f.path, err))
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Rotate closes the current file, renames it with a timestamp and opens a
+// new one. An empty file isn't rotated, so no backup is ever empty.
+func (f *RotatingFile) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return os.ErrClosed
+	}
+	return f.rotateLocked()
+}
+
+// Reopen opens the file at the configured path again and closes the previous
+// one. Use it after an external tool such as logrotate moved the file away.
+// Writes go on to the previous file if the file can't be opened.
+func (f *RotatingFile) Reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return os.ErrClosed
+	}
+	file, size, err := f.open()
+	if err != nil {
+		return err
+	}
+	old := f.file
+	f.use(file, size)
+	return old.Close()
+}
+
+// ReopenOnSIGHUP reopens the file every time the process receives SIGHUP,
+// until the returned function is called.
+func (f *RotatingFile) ReopenOnSIGHUP() (stop func()) {
+	sigs := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(sigs, syscall.SIGHUP)
+
+	go func() {
+		for {
+			select {
+			case <-sigs:
+				if err := f.Reopen(); err != nil {
+					f.reportError(fmt.Errorf("reopening %s: %w", f.path, err))
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(sigs)
+			close(done)
+		})
+	}
+}
+
+// Close closes the file and waits for pending compressions to finish.
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	var err error
+	if f.file != nil {
+		err = f.file.Close()
+		f.file = nil
+		if f.rotated != nil {
+			close(f.rotated)
+		}
+	}
+	f.mu.Unlock()
+
+	if f.done != nil {
+		<-f.done
+	}
+	return err
+}
+
+// due reports whether the file must be rotated before writing n bytes.
+func (f *RotatingFile) due(n int64) bool {
+	if time.Now().Before(f.retry) {
+		return false
+	}
+	if f.opts.MaxSize > 0 && f.size > 0 && f.size+n > f.opts.MaxSize {
+		return true
+	}
+	return !f.rotate.IsZero() && !time.Now().Before(f.rotate)
+}
+
+// open opens, or creates, the file at the configured path for appending and
+// returns it with its size.
+func (f *RotatingFile) open() (*os.File, int64, error) {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o755); err != nil {
+		return nil, 0, err
+	}
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, 0, err
+	}
+	return file, info.Size(), nil
+}
+
+// use makes file the current file and schedules its time based rotation.
+func (f *RotatingFile) use(file *os.File, size int64) {
+	f.file = file
+	f.size = size
+	f.retry = time.Time{}
+	if f.opts.Interval > 0 {
+		f.rotate = f.next(time.Now())
+	}
+}
+
+// next returns the first multiple of the rotation interval after t. The
+// multiples are counted in the zone of t rather than in UTC, which
+// time.Truncate would use.
+func (f *RotatingFile) next(t time.Time) time.Time {
+	_, offset := t.Zone()
+	shift := time.Duration(offset) * time.Second
+	return t.Add(shift).Truncate(f.opts.Interval).Add(f.opts.Interval - shift)
+}
+
+// rotateLocked renames the file and opens a new one before closing it, so
+// that writes go on to the renamed file if the rotation fails. An empty file
+// is kept and only its next time based rotation is scheduled.
+func (f *RotatingFile) rotateLocked() error {
+	if f.size == 0 {
+		if f.opts.Interval > 0 {
+			f.rotate = f.next(time.Now())
+		}
+		return nil
+	}
+	backup := f.backupName(time.Now())
+	if err := os.Rename(f.path, backup); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	file, size, err := f.open()
+	if err != nil {
+		return err
+	}
+	old := f.file
+	f.use(file, size)
+	if err := old.Close(); err != nil {
+		f.reportError(fmt.Errorf("closing %s: %w", backup, err))
+	}
+
+	if f.rotated != nil {
+		f.rotated <- backup
+	}
+	return nil
+}
+
+// cleanup prunes the oldest rotated files and compresses the others, one
+// rotation at a time so that pruning never races a compression. Backups still
+// waiting when newer ones prune them aren't compressed.
+func (f *RotatingFile) cleanup() {
+	defer close(f.done)
+	for backup := range f.rotated {
+		f.prune()
+		if f.opts.Compress && exists(backup) {
+			if err := compressFile(backup); err != nil {
+				f.reportError(fmt.Errorf("compressing %s: %w", backup, err))
+			}
+		}
+	}
+}
+
+// reportError hands an error to the OnError hook, if any.
+func (f *RotatingFile) reportError(err error) {
+	if f.opts.OnError != nil {
+		f.opts.OnError(err)
+	}
+}
+
+// backupName returns an unused name for a file rotated at the given time.
+func (f *RotatingFile) backupName(t time.Time) string {
+	ext := filepath.Ext(f.path)
+	base := strings.TrimSuffix(f.path, ext)
+	name := fmt.Sprintf("%s-%s%s", base, t.Format(backupTimeFormat), ext)
+	for i := 1; exists(name) || exists(name+".gz"); i++ {
+		name = fmt.Sprintf("%s-%s.%d%s", base, t.Format(backupTimeFormat), i, ext)
+	}
+	return name
+}
+
+// backups returns the rotated files, oldest first, without the .gz suffix of
+// the compressed ones. A file being compressed is only returned once. Other
+// files sharing the prefix, e.g. node-errors.log, are ignored.
+func (f *RotatingFile) backups() ([]string, error) {
+	ext := filepath.Ext(f.path)
+	base := strings.TrimSuffix(f.path, ext)
+	matches, err := filepath.Glob(base + "-*" + ext + "*")
+	if err != nil {
+		return nil, err
+	}
+
+	type backup struct {
+		name string
+		time time.Time
+		seq  int
+	}
+	var res []backup
+	for _, m := range matches {
+		m = strings.TrimSuffix(m, ".gz")
+		if !strings.HasSuffix(m, ext) {
+			continue
+		}
+		if t, seq, ok := parseBackupName(strings.TrimSuffix(strings.TrimPrefix(m, base+"-"), ext)); ok {
+			res = append(res, backup{m, t, seq})
+		}
+	}
+	slices.SortFunc(res, func(a, b backup) int {
+		if c := a.time.Compare(b.time); c != 0 {
+			return c
+		}
+		return a.seq - b.seq
+	})
+
+	names := make([]string, len(res))
+	for i, b := range res {
+		names[i] = b.name
+	}
+	return slices.Compact(names), nil
+}
+
+// parseBackupName parses the timestamp and sequence number between the base
+// and the extension of a rotated file name, as written by backupName.
+func parseBackupName(s string) (time.Time, int, bool) {
+	if len(s) < len(backupTimeFormat) {
+		return time.Time{}, 0, false
+	}
+	t, err := time.Parse(backupTimeFormat, s[:len(backupTimeFormat)])
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+	rest := s[len(backupTimeFormat):]
+	if rest == "" {
+		return t, 0, true
+	}
+	seq, err := strconv.Atoi(strings.TrimPrefix(rest, "."))
+	if !strings.HasPrefix(rest, ".") || err != nil || seq <= 0 || strconv.Itoa(seq) != rest[1:] {
+		return time.Time{}, 0, false
+	}
+	return t, seq, true
+}
+
+// prune removes the oldest rotated files beyond the configured maximum.
+func (f *RotatingFile) prune() {
+	if f.opts.MaxBackups == 0 {
+		return
+	}
+	backups, err := f.backups()
+	if err != nil {
+		return
+	}
+	for len(backups) > f.opts.MaxBackups {
+		os.Remove(backups[0])
+		os.Remove(backups[0] + ".gz")
+		backups = backups[1:]
+	}
+}
+
+// compressFile gzips the file and removes the original.
+func compressFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp := name + ".gz.tmp"
+	dst, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	if _, err := io.Copy(zw, src); err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, name+".gz"); err != nil {
+		return err
+	}
+	src.Close()
+	return os.Remove(name)
+}
+
+func exists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
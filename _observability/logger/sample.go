@@ -0,0 +1,216 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// SampleOptions configures a SampleHandler.
+type SampleOptions struct {
+	// Interval is the window over which similar records are counted.
+	// Defaults to 1 second.
+	Interval time.Duration
+
+	// First is the number of similar records let through in every interval.
+	// Defaults to 10.
+	First int
+
+	// Thereafter lets every Thereafter-th record through once First is
+	// reached. Zero suppresses all of them until the next interval.
+	Thereafter int
+}
+
+// sampleKey identifies similar records.
+type sampleKey struct {
+	level slog.Level
+	msg   string
+}
+
+// sampleCounter tracks the similar records of the current interval.
+type sampleCounter struct {
+	start      time.Time
+	count      int // Records seen in the interval
+	suppressed int // Records dropped in the interval
+}
+
+// sampler is the state shared by a SampleHandler and the handlers derived
+// from it.
+type sampler struct {
+	opts SampleOptions
+	root slog.Handler // Handler without attributes, for the summaries
+
+	mu       sync.Mutex
+	counters map[sampleKey]*sampleCounter
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// SampleHandler is a slog.Handler limiting floods of similar records, that is
+// records with the same level and message. In every interval, the first
+// records are let through, the next ones are sampled or suppressed. Once the
+// interval is over, a summary record reports how many were suppressed:
+//
+//	WARN [..] Suppressed similar messages  message="Invalid signature" count=4821 interval=1s
+type SampleHandler struct {
+	origin  slog.Handler
+	sampler *sampler
+}
+
+// NewSampleHandler returns a handler sampling the records passed to h. Call
+// Close on shutdown to stop the background goroutine and report the last
+// suppressed records.
+func NewSampleHandler(h slog.Handler, opts SampleOptions) *SampleHandler {
+	if opts.Interval <= 0 {
+		opts.Interval = time.Second
+	}
+	if opts.First <= 0 {
+		opts.First = 10
+	}
+	if opts.Thereafter < 0 {
+		opts.Thereafter = 0
+	}
+	s := &sampler{
+		opts:     opts,
+		root:     h,
+		counters: make(map[sampleKey]*sampleCounter),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go s.loop()
+
+	return &SampleHandler{
+		origin:  h,
+		sampler: s,
+	}
+}
+
+// Enabled implements slog.Handler, deferring to the wrapped handler.
+func (h *SampleHandler) Enabled(ctx context.Context, lvl slog.Level) bool {
+	return h.origin.Enabled(ctx, lvl)
+}
+
+// Handle implements slog.Handler, passing the record on unless it's one too
+// many of its kind in the current interval.
+func (h *SampleHandler) Handle(ctx context.Context, r slog.Record) error {
+	if !h.sampler.allow(ctx, r.Level, r.Message) {
+		return nil
+	}
+	return h.origin.Handle(ctx, r)
+}
+
+// WithAttrs implements slog.Handler. Records of the returned handler are
+// counted along with the ones of the receiver.
+func (h *SampleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &SampleHandler{
+		origin:  h.origin.WithAttrs(attrs),
+		sampler: h.sampler,
+	}
+}
+
+// WithGroup implements slog.Handler. Records of the returned handler are
+// counted along with the ones of the receiver.
+func (h *SampleHandler) WithGroup(name string) slog.Handler {
+	return &SampleHandler{
+		origin:  h.origin.WithGroup(name),
+		sampler: h.sampler,
+	}
+}
+
+// Close stops the background goroutine, reporting the records suppressed in
+// the current intervals. It affects all the handlers derived from the same
+// NewSampleHandler call; records handled afterwards are still sampled, but
+// only summarized when their interval is over and a similar record comes in.
+func (h *SampleHandler) Close() error {
+	h.sampler.once.Do(func() {
+		close(h.sampler.stop)
+	})
+	<-h.sampler.done
+	return nil
+}
+
+// allow counts a record and reports whether it must be let through.
+func (s *sampler) allow(ctx context.Context, level slog.Level, msg string) bool {
+	now := time.Now()
+	key := sampleKey{level, msg}
+
+	s.mu.Lock()
+	c := s.counters[key]
+	if c == nil {
+		c = &sampleCounter{start: now}
+		s.counters[key] = c
+	}
+	var summary int
+	if now.Sub(c.start) >= s.opts.Interval {
+		summary = c.suppressed
+		*c = sampleCounter{start: now}
+	}
+	c.count++
+
+	allow := c.count <= s.opts.First ||
+		(s.opts.Thereafter > 0 && (c.count-s.opts.First)%s.opts.Thereafter == 0)
+	if !allow {
+		c.suppressed++
+	}
+	s.mu.Unlock()
+
+	if summary > 0 {
+		s.summarize(ctx, key, summary)
+	}
+	return allow
+}
+
+// loop reports the suppressed records of the intervals that are over and
+// forgets the records not seen anymore.
+func (s *sampler) loop() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.sweep(false)
+		case <-s.stop:
+			s.sweep(true)
+			return
+		}
+	}
+}
+
+// sweep summarizes and removes the counters whose interval is over, or all of
+// them.
+func (s *sampler) sweep(all bool) {
+	now := time.Now()
+	summaries := make(map[sampleKey]int)
+
+	s.mu.Lock()
+	for key, c := range s.counters {
+		if all || now.Sub(c.start) >= s.opts.Interval {
+			if c.suppressed > 0 {
+				summaries[key] = c.suppressed
+			}
+			delete(s.counters, key)
+		}
+	}
+	s.mu.Unlock()
+
+	for key, n := range summaries {
+		s.summarize(context.Background(), key, n)
+	}
+}
+
+// summarize emits a record reporting suppressed records.
+func (s *sampler) summarize(ctx context.Context, key sampleKey, n int) {
+	r := slog.NewRecord(time.Now(), key.level, "Suppressed similar messages", 0)
+	r.AddAttrs(
+		slog.String("message", key.msg),
+		slog.Int("count", n),
+		slog.Duration("interval", s.opts.Interval),
+	)
+	s.root.Handle(ctx, r)
+}
@@ -0,0 +1,549 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/holiman/uint256"
+)
+
+// Syslog severities, from RFC 5424 section 6.2.1.
+const (
+	severityCrit    = 2
+	severityErr     = 3
+	severityWarning = 4
+	severityInfo    = 6
+	severityDebug   = 7
+)
+
+// syslogSeverity maps a level to a syslog severity. Trace and debug are both
+// debug, as syslog has nothing below it.
+func syslogSeverity(lvl slog.Level) int {
+	switch {
+	case lvl >= LevelCrit:
+		return severityCrit
+	case lvl >= slog.LevelError:
+		return severityErr
+	case lvl >= slog.LevelWarn:
+		return severityWarning
+	case lvl >= slog.LevelInfo:
+		return severityInfo
+	default:
+		return severityDebug
+	}
+}
+
+// SyslogOptions configures a SyslogHandler.
+type SyslogOptions struct {
+	// Network is "udp", "tcp", "unix" or "unixgram", and Address the address
+	// of the server. When both are empty, the local syslog socket is used.
+	Network string
+	Address string
+
+	// Facility is the syslog facility, defaults to 1 (user-level messages).
+	Facility int
+
+	// AppName and Hostname identify the sender, they default to the name of
+	// the program and the host name.
+	AppName  string
+	Hostname string
+
+	// SDID is the structured data element holding the attributes. Defaults
+	// to "attrs@32473", a placeholder private enterprise number.
+	SDID string
+
+	// DialTimeout bounds connecting to the server, defaults to 5 seconds.
+	DialTimeout time.Duration
+}
+
+// syslogConn is the connection shared by a SyslogHandler and the handlers
+// derived from it.
+type syslogConn struct {
+	opts   SyslogOptions
+	procID string
+
+	mu     sync.Mutex
+	conn   net.Conn
+	stream bool // Whether messages are framed by octet counting (RFC 6587)
+	buf    bytes.Buffer
+}
+
+// SyslogHandler is a slog.Handler sending RFC 5424 messages to a syslog
+// server, such as rsyslog, with the attributes as structured data:
+//
+//	<11>1 2024-07-15T10:30:00.000000Z node1 encrypteid 4242 - [attrs@32473 did="did:ethr:0x01"] Resolution failed
+type SyslogHandler struct {
+	conn  *syslogConn
+	attrs []slog.Attr // Attributes of WithAttrs, flattened to dotted keys
+	group string      // Dotted prefix of the groups opened with WithGroup
+}
+
+// NewSyslogHandler connects to a syslog server.
+func NewSyslogHandler(opts SyslogOptions) (*SyslogHandler, error) {
+	if opts.Facility == 0 {
+		opts.Facility = 1
+	}
+	if opts.Facility < 0 || opts.Facility > 23 {
+		return nil, fmt.Errorf("invalid syslog facility %d", opts.Facility)
+	}
+	if opts.AppName == "" {
+		opts.AppName = filepath.Base(os.Args[0])
+	}
+	if opts.Hostname == "" {
+		opts.Hostname, _ = os.Hostname()
+	}
+	if opts.SDID == "" {
+		opts.SDID = "attrs@32473"
+	}
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = 5 * time.Second
+	}
+	c := &syslogConn{
+		opts:   opts,
+		procID: strconv.Itoa(os.Getpid()),
+	}
+	if err := c.dial(); err != nil {
+		return nil, err
+	}
+	return &SyslogHandler{conn: c}, nil
+}
+
+// Enabled implements slog.Handler. Filtering is left to wrapping handlers.
+func (h *SyslogHandler) Enabled(_ context.Context, _ slog.Level) bool {
+	return true
+}
+
+// Handle implements slog.Handler, sending the record as one syslog message.
+// A broken connection is reestablished once before giving up.
+func (h *SyslogHandler) Handle(_ context.Context, r slog.Record) error {
+	c := h.conn
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.buf.Reset()
+	c.format(&c.buf, r, flattenRecord(h.attrs, h.group, r))
+	msg := c.buf.Bytes()
+
+	err := c.write(msg)
+	if err != nil {
+		if err = c.dial(); err == nil {
+			err = c.write(msg)
+		}
+	}
+	return err
+}
+
+// WithAttrs implements slog.Handler. The returned handler shares the
+// connection of the receiver.
+func (h *SyslogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &SyslogHandler{
+		conn:  h.conn,
+		attrs: appendFlattenedAttrs(h.attrs, h.group, attrs),
+		group: h.group,
+	}
+}
+
+// WithGroup implements slog.Handler. The returned handler shares the
+// connection of the receiver.
+func (h *SyslogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &SyslogHandler{
+		conn:  h.conn,
+		attrs: slices.Clip(h.attrs),
+		group: h.group + name + ".",
+	}
+}
+
+// Close closes the connection to the server.
+func (h *SyslogHandler) Close() error {
+	h.conn.mu.Lock()
+	defer h.conn.mu.Unlock()
+
+	if h.conn.conn == nil {
+		return nil
+	}
+	err := h.conn.conn.Close()
+	h.conn.conn = nil
+	return err
+}
+
+// dial connects to the server, replacing the current connection. It must be
+// called with the lock held.
+func (c *syslogConn) dial() error {
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
+	network, address := c.opts.Network, c.opts.Address
+	if network == "" && address == "" {
+		// The local socket is a datagram one on most systems.
+		var err error
+		for _, path := range []string{"/dev/log", "/var/run/syslog", "/var/run/log"} {
+			for _, network := range []string{"unixgram", "unix"} {
+				if c.conn, err = net.DialTimeout(network, path, c.opts.DialTimeout); err == nil {
+					c.stream = network == "unix"
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf("connecting to local syslog: %w", err)
+	}
+	conn, err := net.DialTimeout(network, address, c.opts.DialTimeout)
+	if err != nil {
+		return fmt.Errorf("connecting to syslog: %w", err)
+	}
+	c.conn = conn
+	c.stream = network == "tcp" || network == "tcp4" || network == "tcp6" || network == "unix"
+	return nil
+}
+
+// write sends a message, framed by its length on stream connections. It
+// must be called with the lock held.
+func (c *syslogConn) write(msg []byte) error {
+	if c.conn == nil {
+		return errors.New("syslog connection closed")
+	}
+	if c.stream {
+		frame := make([]byte, 0, len(msg)+8)
+		frame = strconv.AppendInt(frame, int64(len(msg)), 10)
+		frame = append(frame, ' ')
+		msg = append(frame, msg...)
+	}
+	_, err := c.conn.Write(msg)
+	return err
+}
+
+// format writes a record as an RFC 5424 message.
+func (c *syslogConn) format(buf *bytes.Buffer, r slog.Record, attrs []slog.Attr) {
+	buf.WriteByte('<')
+	buf.WriteString(strconv.Itoa(c.opts.Facility*8 + syslogSeverity(r.Level)))
+	buf.WriteString(">1 ")
+	if r.Time.IsZero() {
+		buf.WriteByte('-')
+	} else {
+		buf.WriteString(r.Time.Format("2006-01-02T15:04:05.000000Z07:00"))
+	}
+	buf.WriteByte(' ')
+	buf.WriteString(syslogHeaderField(c.opts.Hostname, 255))
+	buf.WriteByte(' ')
+	buf.WriteString(syslogHeaderField(c.opts.AppName, 48))
+	buf.WriteByte(' ')
+	buf.WriteString(c.procID)
+	buf.WriteString(" - ")
+
+	if len(attrs) == 0 {
+		buf.WriteByte('-')
+	} else {
+		buf.WriteByte('[')
+		buf.WriteString(c.opts.SDID)
+		for _, attr := range attrs {
+			buf.WriteByte(' ')
+			buf.WriteString(sdName(attr.Key))
+			buf.WriteString(`="`)
+			for _, ch := range plainValue(attr.Value) {
+				if ch == '"' || ch == '\\' || ch == ']' {
+					buf.WriteByte('\\')
+				}
+				buf.WriteRune(ch)
+			}
+			buf.WriteByte('"')
+		}
+		buf.WriteByte(']')
+	}
+	if r.Message != "" {
+		buf.WriteByte(' ')
+		buf.WriteString(r.Message)
+	}
+}
+
+// syslogHeaderField makes a header field valid: printable ASCII without
+// spaces, "-" when empty.
+func syslogHeaderField(s string, max int) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return '_'
+		}
+		return r
+	}, s)
+	if len(s) > max {
+		s = s[:max]
+	}
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// sdName makes an attribute key a valid SD-NAME: up to 32 printable ASCII
+// characters, except '=', ' ', ']' and '"'.
+func sdName(key string) string {
+	key = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 || r == '=' || r == ']' || r == '"' {
+			return '_'
+		}
+		return r
+	}, key)
+	if len(key) > 32 {
+		key = key[:32]
+	}
+	if key == "" {
+		return "_"
+	}
+	return key
+}
+
+// JournalOptions configures a JournalHandler.
+type JournalOptions struct {
+	// Socket is the journald socket, defaults to
+	// /run/systemd/journal/socket.
+	Socket string
+
+	// Identifier is the SYSLOG_IDENTIFIER of the entries, defaults to the
+	// name of the program.
+	Identifier string
+}
+
+// journalConn is the socket shared by a JournalHandler and the handlers
+// derived from it.
+type journalConn struct {
+	opts JournalOptions
+	conn *net.UnixConn
+	addr *net.UnixAddr
+
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+// JournalHandler is a slog.Handler sending entries to journald with its
+// native protocol. Attributes become journal fields, with their keys upper
+// cased and sanitized, e.g. "req.id" becomes REQ_ID, so they can be queried
+// with journalctl REQ_ID=42. Attributes named like the fields journald
+// interprets, e.g. "message" or "priority", are prefixed with ATTR_.
+type JournalHandler struct {
+	conn  *journalConn
+	attrs []slog.Attr
+	group string
+}
+
+// NewJournalHandler returns a handler writing to the journald socket.
+func NewJournalHandler(opts JournalOptions) (*JournalHandler, error) {
+	if opts.Socket == "" {
+		opts.Socket = "/run/systemd/journal/socket"
+	}
+	if opts.Identifier == "" {
+		opts.Identifier = filepath.Base(os.Args[0])
+	}
+	if _, err := os.Stat(opts.Socket); err != nil {
+		return nil, fmt.Errorf("journald socket: %w", err)
+	}
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	return &JournalHandler{
+		conn: &journalConn{
+			opts: opts,
+			conn: conn,
+			addr: &net.UnixAddr{Name: opts.Socket, Net: "unixgram"},
+		},
+	}, nil
+}
+
+// Enabled implements slog.Handler. Filtering is left to wrapping handlers.
+func (h *JournalHandler) Enabled(_ context.Context, _ slog.Level) bool {
+	return true
+}
+
+// Handle implements slog.Handler, sending the record as one journal entry.
+func (h *JournalHandler) Handle(_ context.Context, r slog.Record) error {
+	c := h.conn
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.buf.Reset()
+	appendJournalField(&c.buf, "MESSAGE", r.Message)
+	appendJournalField(&c.buf, "PRIORITY", strconv.Itoa(syslogSeverity(r.Level)))
+	appendJournalField(&c.buf, "SYSLOG_IDENTIFIER", c.opts.Identifier)
+	if !r.Time.IsZero() {
+		// journald parses the field as the timestamp of a syslog message.
+		appendJournalField(&c.buf, "SYSLOG_TIMESTAMP", r.Time.Format(time.Stamp))
+	}
+	if r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		appendJournalField(&c.buf, "CODE_FILE", frame.File)
+		appendJournalField(&c.buf, "CODE_LINE", strconv.Itoa(frame.Line))
+		appendJournalField(&c.buf, "CODE_FUNC", frame.Function)
+	}
+	for _, attr := range flattenRecord(h.attrs, h.group, r) {
+		name := journalFieldName(attr.Key)
+		if journalReservedFields[name] {
+			name = journalFieldName("ATTR_" + name)
+		}
+		appendJournalField(&c.buf, name, plainValue(attr.Value))
+	}
+	return c.send(c.buf.Bytes())
+}
+
+// WithAttrs implements slog.Handler. The returned handler shares the socket
+// of the receiver.
+func (h *JournalHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &JournalHandler{
+		conn:  h.conn,
+		attrs: appendFlattenedAttrs(h.attrs, h.group, attrs),
+		group: h.group,
+	}
+}
+
+// WithGroup implements slog.Handler. The returned handler shares the socket
+// of the receiver.
+func (h *JournalHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &JournalHandler{
+		conn:  h.conn,
+		attrs: slices.Clip(h.attrs),
+		group: h.group + name + ".",
+	}
+}
+
+// Close closes the socket.
+func (h *JournalHandler) Close() error {
+	return h.conn.conn.Close()
+}
+
+// send writes an entry in a datagram or, if too large for one, passes it in
+// a temporary file as journald expects.
+func (c *journalConn) send(entry []byte) error {
+	_, _, err := c.conn.WriteMsgUnix(entry, nil, c.addr)
+	if err == nil || !errors.Is(err, syscall.EMSGSIZE) && !errors.Is(err, syscall.ENOBUFS) {
+		return err
+	}
+	f, err := os.CreateTemp("/dev/shm", "journal-")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := os.Remove(f.Name()); err != nil {
+		return err
+	}
+	if _, err := f.Write(entry); err != nil {
+		return err
+	}
+	_, _, err = c.conn.WriteMsgUnix(nil, syscall.UnixRights(int(f.Fd())), c.addr)
+	return err
+}
+
+// appendJournalField appends a field in the journal export format, using the
+// binary form for values spanning several lines.
+func appendJournalField(buf *bytes.Buffer, name, value string) {
+	buf.WriteString(name)
+	if !strings.ContainsRune(value, '\n') {
+		buf.WriteByte('=')
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+		return
+	}
+	buf.WriteByte('\n')
+	binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+// journalReservedFields are the fields written by JournalHandler or given a
+// meaning by journald, which attributes can't override.
+var journalReservedFields = map[string]bool{
+	"MESSAGE":            true,
+	"MESSAGE_ID":         true,
+	"PRIORITY":           true,
+	"CODE_FILE":          true,
+	"CODE_LINE":          true,
+	"CODE_FUNC":          true,
+	"ERRNO":              true,
+	"INVOCATION_ID":      true,
+	"USER_INVOCATION_ID": true,
+	"SYSLOG_FACILITY":    true,
+	"SYSLOG_IDENTIFIER":  true,
+	"SYSLOG_PID":         true,
+	"SYSLOG_TIMESTAMP":   true,
+	"SYSLOG_RAW":         true,
+	"DOCUMENTATION":      true,
+	"TID":                true,
+	"UNIT":               true,
+	"USER_UNIT":          true,
+}
+
+// journalFieldName makes an attribute key a valid user field name: upper case
+// letters, digits and underscores, not starting with an underscore or a
+// digit, up to 64 characters. Longer names are cut and end with a hash of
+// the whole name, so that keys sharing a long prefix stay apart.
+func journalFieldName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+	name = strings.TrimLeft(name, "_0123456789")
+	if name == "" {
+		name = "FIELD"
+	}
+	if len(name) > 64 {
+		hash := fnv.New32a()
+		hash.Write([]byte(name))
+		name = fmt.Sprintf("%s_%08X", name[:55], hash.Sum32())
+	}
+	return name
+}
+
+// plainValue renders a value without the quoting and digit grouping of the
+// terminal format, for outputs that have their own escaping.
+func plainValue(v slog.Value) string {
+	switch v.Kind() {
+	case slog.KindString:
+		return v.String()
+	case slog.KindTime:
+		return v.Time().Format(time.RFC3339Nano)
+	case slog.KindAny:
+		a := v.Any()
+		if a == nil || (reflect.ValueOf(a).Kind() == reflect.Pointer && reflect.ValueOf(a).IsNil()) {
+			return "<nil>"
+		}
+		switch a := a.(type) {
+		case error:
+			return compactError(a)
+		case *uint256.Int:
+			return a.Dec()
+		case TerminalStringer:
+			return a.TerminalString()
+		case fmt.Stringer:
+			return a.String()
+		}
+		return fmt.Sprintf("%+v", a)
+	default:
+		return v.String()
+	}
+}
@@ -0,0 +1,321 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule describes when a recurring job should run.
+type Schedule interface {
+
+	// Next returns the first activation time strictly after the given time,
+	// or the zero time if there is none.
+	Next(time.Time) time.Time
+}
+
+// interval is a Schedule that activates at a fixed interval.
+type interval time.Duration
+
+// Every returns a Schedule that activates once every d. A non positive
+// duration never activates.
+func Every(d time.Duration) Schedule {
+	return interval(d)
+}
+
+// Next implements the Schedule interface.
+func (i interval) Next(t time.Time) time.Time {
+	if i <= 0 {
+		return time.Time{}
+	}
+
+	return t.Add(time.Duration(i))
+}
+
+// starBit is set on a field that was given as "*" or "?". The day of month
+// and day of week fields need it to know which of them restricts the days.
+const starBit = 1 << 63
+
+// bounds holds the accepted values of a cron field.
+type bounds struct {
+	min, max uint
+	names    map[string]uint
+}
+
+var (
+	seconds = bounds{0, 59, nil}
+	minutes = bounds{0, 59, nil}
+	hours   = bounds{0, 23, nil}
+	dom     = bounds{1, 31, nil}
+	months  = bounds{1, 12, map[string]uint{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dow = bounds{0, 7, map[string]uint{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// descriptors maps the predefined schedules to their cron expression.
+var descriptors = map[string]string{
+	"@yearly":   "0 0 0 1 1 *",
+	"@annually": "0 0 0 1 1 *",
+	"@monthly":  "0 0 0 1 * *",
+	"@weekly":   "0 0 0 * * 0",
+	"@daily":    "0 0 0 * * *",
+	"@midnight": "0 0 0 * * *",
+	"@hourly":   "0 0 * * * *",
+}
+
+// errCronSyntax is returned when a cron expression is invalid.
+var errCronSyntax = errors.New("expect 5 or 6 space separated fields, a descriptor or @every <duration>")
+
+// cronSchedule is a Schedule built from a cron expression. Each field holds
+// a bit per accepted value.
+type cronSchedule struct {
+	second, minute, hour, dom, month, dow uint64
+}
+
+// ParseCron parses a cron expression into a Schedule. It accepts the
+// standard five fields (minute, hour, day of month, month, day of week), an
+// optional leading seconds field, the @yearly, @monthly, @weekly, @daily and
+// @hourly descriptors and "@every <duration>". Activation times are computed
+// in the location of the time handed to Next.
+//
+// Each field supports "*", lists ("1,15"), ranges ("1-5"), steps ("*/10",
+// "0-30/5") and, for months and days of the week, three letter names.
+func ParseCron(expr string) (Schedule, error) {
+	expr = strings.TrimSpace(expr)
+
+	if strings.HasPrefix(expr, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(expr, "@every ")))
+		if err != nil {
+			return nil, fmt.Errorf("parsing %q: %w", expr, err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("parsing %q: duration must be positive", expr)
+		}
+		return Every(d), nil
+	}
+
+	if spec, exists := descriptors[strings.ToLower(expr)]; exists {
+		expr = spec
+	}
+
+	fields := strings.Fields(expr)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("parsing %q: %w", expr, errCronSyntax)
+	}
+
+	var s cronSchedule
+	for i, f := range []struct {
+		bits *uint64
+		b    bounds
+	}{
+		{&s.second, seconds},
+		{&s.minute, minutes},
+		{&s.hour, hours},
+		{&s.dom, dom},
+		{&s.month, months},
+		{&s.dow, dow},
+	} {
+		bits, err := parseField(fields[i], f.b)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %q: %w", expr, err)
+		}
+		*f.bits = bits
+	}
+
+	// Sunday can be written as 0 or 7.
+	if s.dow&(1<<7) != 0 {
+		s.dow = s.dow&^(1<<7) | 1
+	}
+
+	return &s, nil
+}
+
+// Next implements the Schedule interface.
+func (s *cronSchedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+
+	// Start at the next whole second and give up after five years, which
+	// covers schedules like "0 0 29 2 *" that only match on leap years.
+	t = t.Add(time.Second - time.Duration(t.Nanosecond()))
+	yearLimit := t.Year() + 5
+
+	// added tracks whether a field was already moved forward, after which
+	// the lower fields must start from their first value.
+	added := false
+
+wrap:
+	if t.Year() > yearLimit {
+		return time.Time{}
+	}
+
+	for s.month&(1<<uint(t.Month())) == 0 {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+		}
+		t = t.AddDate(0, 1, 0)
+		if t.Month() == time.January {
+			goto wrap
+		}
+	}
+
+	for !s.dayMatches(t) {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		}
+		t = t.AddDate(0, 0, 1)
+		if t.Day() == 1 {
+			goto wrap
+		}
+	}
+
+	for s.hour&(1<<uint(t.Hour())) == 0 {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc)
+		}
+		t = t.Add(time.Hour)
+		if t.Hour() == 0 {
+			goto wrap
+		}
+	}
+
+	for s.minute&(1<<uint(t.Minute())) == 0 {
+		if !added {
+			added = true
+			t = t.Truncate(time.Minute)
+		}
+		t = t.Add(time.Minute)
+		if t.Minute() == 0 {
+			goto wrap
+		}
+	}
+
+	for s.second&(1<<uint(t.Second())) == 0 {
+		if !added {
+			added = true
+			t = t.Truncate(time.Second)
+		}
+		t = t.Add(time.Second)
+		if t.Second() == 0 {
+			goto wrap
+		}
+	}
+
+	return t
+}
+
+// dayMatches follows the cron convention: when both the day of month and the
+// day of week are restricted, a day matching either of them is accepted.
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+
+	if s.dom&starBit != 0 || s.dow&starBit != 0 {
+		return domMatch && dowMatch
+	}
+
+	return domMatch || dowMatch
+}
+
+// parseField parses a comma separated list of ranges into a bit set.
+func parseField(field string, b bounds) (uint64, error) {
+	var bits uint64
+	for _, expr := range strings.Split(field, ",") {
+		r, err := parseRange(expr, b)
+		if err != nil {
+			return 0, err
+		}
+		bits |= r
+	}
+
+	return bits, nil
+}
+
+// parseRange parses a single "*", "N", "N-M" with an optional "/step".
+func parseRange(expr string, b bounds) (uint64, error) {
+	rangeAndStep := strings.Split(expr, "/")
+	lowAndHigh := strings.Split(rangeAndStep[0], "-")
+
+	var start, end uint
+	var star bool
+
+	switch {
+	case len(rangeAndStep) > 2 || len(lowAndHigh) > 2:
+		return 0, fmt.Errorf("invalid range %q", expr)
+
+	case lowAndHigh[0] == "*" || lowAndHigh[0] == "?":
+		if len(lowAndHigh) != 1 {
+			return 0, fmt.Errorf("invalid range %q", expr)
+		}
+		start, end, star = b.min, b.max, true
+
+	default:
+		var err error
+		if start, err = parseValue(lowAndHigh[0], b); err != nil {
+			return 0, err
+		}
+		end = start
+		if len(lowAndHigh) == 2 {
+			if end, err = parseValue(lowAndHigh[1], b); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	step := uint(1)
+	if len(rangeAndStep) == 2 {
+		n, err := strconv.ParseUint(rangeAndStep[1], 10, 8)
+		if err != nil || n == 0 {
+			return 0, fmt.Errorf("invalid step in %q", expr)
+		}
+		step = uint(n)
+
+		// "N/step" means from N to the end of the range.
+		if !star && len(lowAndHigh) == 1 {
+			end = b.max
+		}
+
+		// A stepped "*" no longer matches every value.
+		star = false
+	}
+
+	if start < b.min || end > b.max || start > end {
+		return 0, fmt.Errorf("range %q out of bounds [%d, %d]", expr, b.min, b.max)
+	}
+
+	var bits uint64
+	for i := start; i <= end; i += step {
+		bits |= 1 << i
+	}
+	if star {
+		bits |= starBit
+	}
+
+	return bits, nil
+}
+
+// parseValue parses a number or one of the names accepted by the field.
+func parseValue(v string, b bounds) (uint, error) {
+	if n, exists := b.names[strings.ToLower(v)]; exists {
+		return n, nil
+	}
+
+	n, err := strconv.ParseUint(v, 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", v)
+	}
+
+	return uint(n), nil
+}
@@ -0,0 +1,550 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/storage"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// Key prefixes used in the durable store.
+var (
+	prefixJob   = []byte("j/") // j/<id> => record
+	prefixReady = []byte("r/") // r/<visible at><id> => nothing
+	prefixDead  = []byte("d/") // d/<id> => record
+)
+
+// Handler executes a durable job from its payload. Returning nil acknowledges
+// the job, any error makes it visible again for another delivery.
+type Handler func(ctx context.Context, payload []byte) error
+
+// record is the persisted form of a durable job.
+type record struct {
+	ID        string            `json:"id"`
+	Kind      string            `json:"kind"`
+	Payload   []byte            `json:"payload"`
+	Priority  Priority          `json:"priority"`
+	Tenant    string            `json:"tenant,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Attempts  int               `json:"attempts"`
+	Leased    bool              `json:"leased,omitempty"`
+	VisibleAt time.Time         `json:"visible_at"`
+	LastError string            `json:"last_error,omitempty"`
+}
+
+// DurableQueue persists jobs in an embedded store so they survive restarts
+// and hands them to a Worker with at-least-once delivery. A delivered job is
+// leased for the visibility timeout: if it's not acknowledged by then, it's
+// cancelled and delivered again.
+type DurableQueue struct {
+	w           *Worker
+	db          *leveldb.DB
+	visibility  time.Duration
+	retryDelay  time.Duration
+	maxAttempts int
+	poll        time.Duration
+	onError     func(error)
+
+	mu       sync.RWMutex
+	handlers map[string]Handler
+	inflight map[string]struct{}
+
+	notify   chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// DurableOption configures a DurableQueue at construction time.
+type DurableOption func(*DurableQueue)
+
+// WithVisibilityTimeout sets how long a delivered job is leased before it's
+// delivered again. It also acts as the deadline of every run. Defaults to
+// 30 seconds.
+func WithVisibilityTimeout(d time.Duration) DurableOption {
+	return func(q *DurableQueue) {
+		q.visibility = d
+	}
+}
+
+// WithRetryDelay sets how long a failed job waits before it's delivered
+// again. Defaults to 1 second.
+func WithRetryDelay(d time.Duration) DurableOption {
+	return func(q *DurableQueue) {
+		q.retryDelay = d
+	}
+}
+
+// WithMaxAttempts moves a job to the dead letters after it failed n times.
+// A value of 0, the default, retries forever.
+func WithMaxAttempts(n int) DurableOption {
+	return func(q *DurableQueue) {
+		q.maxAttempts = n
+	}
+}
+
+// WithPollInterval sets how often the store is checked for jobs becoming
+// visible. Defaults to 100 milliseconds.
+func WithPollInterval(d time.Duration) DurableOption {
+	return func(q *DurableQueue) {
+		q.poll = d
+	}
+}
+
+// WithErrorHandler sets the function called with the errors of the background
+// delivery, such as a job that couldn't be acknowledged and will run again
+// once its lease expires. It can be called concurrently. By default errors
+// are dropped.
+func WithErrorHandler(fn func(error)) DurableOption {
+	return func(q *DurableQueue) {
+		q.onError = fn
+	}
+}
+
+// NewDurableQueue opens the store at path and starts delivering its jobs to
+// the worker. An empty path keeps the store in memory, which is only useful
+// for tests. Jobs that were in flight when the process last stopped are made
+// visible again right away.
+func NewDurableQueue(w *Worker, path string, opts ...DurableOption) (*DurableQueue, error) {
+	var db *leveldb.DB
+	var err error
+	switch path {
+	case "":
+		db, err = leveldb.Open(storage.NewMemStorage(), nil)
+	default:
+		db, err = leveldb.OpenFile(path, nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening store: %w", err)
+	}
+
+	q := DurableQueue{
+		w:          w,
+		db:         db,
+		visibility: 30 * time.Second,
+		retryDelay: time.Second,
+		poll:       100 * time.Millisecond,
+		handlers:   make(map[string]Handler),
+		inflight:   make(map[string]struct{}),
+		notify:     make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(&q)
+	}
+
+	if q.visibility <= 0 || q.poll <= 0 || q.retryDelay < 0 || q.maxAttempts < 0 {
+		db.Close()
+		return nil, errors.New("invalid durable queue options")
+	}
+
+	if err := q.recover(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("recovering in-flight jobs: %w", err)
+	}
+
+	go q.pump()
+
+	return &q, nil
+}
+
+// Register sets the handler executing jobs of the given kind. Jobs of a kind
+// without a handler stay in the store until one is registered.
+func (q *DurableQueue) Register(kind string, h Handler) {
+	q.mu.Lock()
+	q.handlers[kind] = h
+	q.mu.Unlock()
+
+	q.wake()
+}
+
+// Enqueue persists a job of the given kind. The priority, tenant and labels
+// job options are kept with the job and applied on every delivery. The returned
+// id identifies the job in the store.
+func (q *DurableQueue) Enqueue(kind string, payload []byte, opts ...JobOption) (string, error) {
+	o := newJobOptions(opts)
+
+	rec := record{
+		ID:        uuid.NewString(),
+		Kind:      kind,
+		Payload:   payload,
+		Priority:  o.priority,
+		Tenant:    o.tenant,
+		Labels:    o.labels,
+		VisibleAt: time.Now(),
+	}
+
+	batch := new(leveldb.Batch)
+	if err := putRecord(batch, prefixJob, rec); err != nil {
+		return "", err
+	}
+	batch.Put(readyKey(rec.VisibleAt, rec.ID), nil)
+
+	if err := q.db.Write(batch, nil); err != nil {
+		return "", fmt.Errorf("writing job: %w", err)
+	}
+
+	q.wake()
+
+	return rec.ID, nil
+}
+
+// Pending returns the number of jobs in the store that were not acknowledged
+// yet, in flight or not.
+func (q *DurableQueue) Pending() (int, error) {
+	return q.count(prefixJob)
+}
+
+// DeadLetters returns the jobs that ran out of attempts.
+func (q *DurableQueue) DeadLetters() ([]string, error) {
+	var ids []string
+
+	iter := q.db.NewIterator(util.BytesPrefix(prefixDead), nil)
+	defer iter.Release()
+
+	for iter.Next() {
+		ids = append(ids, string(iter.Key()[len(prefixDead):]))
+	}
+
+	return ids, iter.Error()
+}
+
+// Close stops delivering jobs and closes the store. Shut the worker down
+// first so running jobs get a chance to be acknowledged; jobs that weren't
+// are delivered again on the next start.
+func (q *DurableQueue) Close() error {
+	q.stopOnce.Do(func() {
+		close(q.stop)
+	})
+	<-q.done
+
+	return q.db.Close()
+}
+
+// reportError hands an error of the background delivery to the error handler,
+// if any.
+func (q *DurableQueue) reportError(err error) {
+	if q.onError != nil {
+		q.onError(err)
+	}
+}
+
+// wake tells the pump there may be work to deliver without waiting for the
+// next poll.
+func (q *DurableQueue) wake() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// recover makes every leased job visible immediately. Nothing is in flight
+// yet when the queue opens, so all the leases belong to a previous process.
+func (q *DurableQueue) recover() error {
+	now := time.Now()
+	batch := new(leveldb.Batch)
+
+	iter := q.db.NewIterator(util.BytesPrefix(prefixJob), nil)
+	for iter.Next() {
+		var rec record
+		if err := json.Unmarshal(iter.Value(), &rec); err != nil {
+			iter.Release()
+			return fmt.Errorf("decoding job: %w", err)
+		}
+
+		if !rec.Leased {
+			continue
+		}
+
+		batch.Delete(readyKey(rec.VisibleAt, rec.ID))
+		rec.Leased = false
+		rec.VisibleAt = now
+		if err := putRecord(batch, prefixJob, rec); err != nil {
+			iter.Release()
+			return err
+		}
+		batch.Put(readyKey(rec.VisibleAt, rec.ID), nil)
+	}
+	iter.Release()
+
+	if err := iter.Error(); err != nil {
+		return err
+	}
+
+	return q.db.Write(batch, nil)
+}
+
+// pump delivers visible jobs to the worker until the queue is closed or the
+// worker shuts down.
+func (q *DurableQueue) pump() {
+	defer close(q.done)
+
+	ticker := time.NewTicker(q.poll)
+	defer ticker.Stop()
+
+	for {
+		if err := q.deliver(); errors.Is(err, ErrShuttingDown) {
+			return
+		} else if err != nil {
+			q.reportError(fmt.Errorf("delivering jobs: %w", err))
+		}
+
+		select {
+		case <-q.stop:
+			return
+		case <-q.w.isShutdown:
+			return
+		case <-q.notify:
+		case <-ticker.C:
+		}
+	}
+}
+
+// deliver leases every visible job that has a handler and starts it on the
+// worker, waiting for slots as needed.
+func (q *DurableQueue) deliver() error {
+	now := time.Now()
+
+	var ids []string
+	iter := q.db.NewIterator(&util.Range{
+		Start: prefixReady,
+		Limit: readyKey(now.Add(time.Nanosecond), ""),
+	}, nil)
+	for iter.Next() {
+		ids = append(ids, string(iter.Key()[len(prefixReady)+8:]))
+	}
+	iter.Release()
+
+	if err := iter.Error(); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		select {
+		case <-q.stop:
+			return nil
+		default:
+		}
+
+		rec, h, err := q.lease(id)
+		if err != nil {
+			return err
+		}
+		if h == nil {
+			continue
+		}
+
+		if err := q.start(rec, h); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// lease marks the job as in flight until the visibility timeout expires. It
+// returns a nil handler if the job can't be delivered right now.
+func (q *DurableQueue) lease(id string) (record, Handler, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, running := q.inflight[id]; running {
+		return record{}, nil, nil
+	}
+
+	rec, err := q.get(id)
+	if err != nil || rec.ID == "" {
+		return record{}, nil, err
+	}
+
+	h, exists := q.handlers[rec.Kind]
+	if !exists {
+		return record{}, nil, nil
+	}
+
+	batch := new(leveldb.Batch)
+	batch.Delete(readyKey(rec.VisibleAt, rec.ID))
+	rec.Attempts++
+	rec.Leased = true
+	rec.VisibleAt = time.Now().Add(q.visibility)
+	if err := putRecord(batch, prefixJob, rec); err != nil {
+		return record{}, nil, err
+	}
+	batch.Put(readyKey(rec.VisibleAt, rec.ID), nil)
+
+	if err := q.db.Write(batch, nil); err != nil {
+		return record{}, nil, fmt.Errorf("leasing job: %w", err)
+	}
+
+	q.inflight[id] = struct{}{}
+
+	return rec, h, nil
+}
+
+// start runs a leased job on the worker. The run's deadline is the end of
+// the lease so a job is never executed twice at the same time.
+func (q *DurableQueue) start(rec record, h Handler) error {
+	ctx, cancel := context.WithDeadline(context.Background(), rec.VisibleAt)
+	defer cancel()
+
+	run := func(ctx context.Context) {
+		err := h(ctx, rec.Payload)
+		if err == nil {
+			if err = q.ack(rec); err != nil {
+				q.reportError(fmt.Errorf("acknowledging job %s: %w", rec.ID, err))
+			}
+		} else if err = q.nack(rec, err); err != nil {
+			q.reportError(fmt.Errorf("releasing failed job %s: %w", rec.ID, err))
+		}
+
+		q.mu.Lock()
+		delete(q.inflight, rec.ID)
+		q.mu.Unlock()
+
+		// The job might be due again right away.
+		if err == nil {
+			q.wake()
+		}
+	}
+
+	_, err := q.w.Start(ctx, run, WithPriority(rec.Priority), WithTenant(rec.Tenant), WithLabels(rec.Labels))
+	if err == nil {
+		return nil
+	}
+
+	// The job never ran: give the lease back so the attempt doesn't count
+	// and the job is delivered again on the next poll, or on the next start.
+	q.mu.Lock()
+	releaseErr := q.unlease(rec)
+	delete(q.inflight, rec.ID)
+	q.mu.Unlock()
+
+	if errors.Is(err, ErrShuttingDown) {
+		return err
+	}
+	if releaseErr != nil {
+		return fmt.Errorf("releasing job %s: %w", rec.ID, releaseErr)
+	}
+
+	return nil
+}
+
+// unlease reverts the lease of a job that couldn't be started, making it
+// visible right away with its attempts as they were.
+func (q *DurableQueue) unlease(rec record) error {
+	batch := new(leveldb.Batch)
+	batch.Delete(readyKey(rec.VisibleAt, rec.ID))
+
+	rec.Attempts--
+	rec.Leased = false
+	rec.VisibleAt = time.Now()
+	if err := putRecord(batch, prefixJob, rec); err != nil {
+		return err
+	}
+	batch.Put(readyKey(rec.VisibleAt, rec.ID), nil)
+
+	return q.db.Write(batch, nil)
+}
+
+// ack removes a successfully executed job from the store.
+func (q *DurableQueue) ack(rec record) error {
+	batch := new(leveldb.Batch)
+	batch.Delete(storeKey(prefixJob, rec.ID))
+	batch.Delete(readyKey(rec.VisibleAt, rec.ID))
+
+	return q.db.Write(batch, nil)
+}
+
+// nack makes a failed job visible again after the retry delay, or moves it
+// to the dead letters once it ran out of attempts.
+func (q *DurableQueue) nack(rec record, cause error) error {
+	batch := new(leveldb.Batch)
+	batch.Delete(readyKey(rec.VisibleAt, rec.ID))
+
+	rec.Leased = false
+	rec.LastError = cause.Error()
+
+	if q.maxAttempts > 0 && rec.Attempts >= q.maxAttempts {
+		batch.Delete(storeKey(prefixJob, rec.ID))
+		if err := putRecord(batch, prefixDead, rec); err != nil {
+			return err
+		}
+		return q.db.Write(batch, nil)
+	}
+
+	rec.VisibleAt = time.Now().Add(q.retryDelay)
+	if err := putRecord(batch, prefixJob, rec); err != nil {
+		return err
+	}
+	batch.Put(readyKey(rec.VisibleAt, rec.ID), nil)
+
+	return q.db.Write(batch, nil)
+}
+
+// get loads a job from the store. It returns an empty record if the job
+// doesn't exist anymore.
+func (q *DurableQueue) get(id string) (record, error) {
+	data, err := q.db.Get(storeKey(prefixJob, id), nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return record{}, nil
+	}
+	if err != nil {
+		return record{}, fmt.Errorf("reading job: %w", err)
+	}
+
+	var rec record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return record{}, fmt.Errorf("decoding job: %w", err)
+	}
+
+	return rec, nil
+}
+
+func (q *DurableQueue) count(prefix []byte) (int, error) {
+	iter := q.db.NewIterator(util.BytesPrefix(prefix), nil)
+	defer iter.Release()
+
+	var n int
+	for iter.Next() {
+		n++
+	}
+
+	return n, iter.Error()
+}
+
+func putRecord(batch *leveldb.Batch, prefix []byte, rec record) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("encoding job: %w", err)
+	}
+
+	batch.Put(storeKey(prefix, rec.ID), data)
+
+	return nil
+}
+
+func storeKey(prefix []byte, id string) []byte {
+	return append(prefix[:len(prefix):len(prefix)], id...)
+}
+
+// readyKey builds the index key ordering jobs by the time they become
+// visible.
+func readyKey(visibleAt time.Time, id string) []byte {
+	var buf bytes.Buffer
+	buf.Write(prefixReady)
+	binary.Write(&buf, binary.BigEndian, uint64(visibleAt.UnixNano()))
+	buf.WriteString(id)
+
+	return buf.Bytes()
+}
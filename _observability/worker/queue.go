@@ -0,0 +1,268 @@
+package worker
+
+import (
+	"context"
+	"maps"
+	"time"
+)
+
+// Priority defines the scheduling class of a job. Jobs of a higher priority
+// are always handed a free slot before jobs of a lower priority.
+type Priority int
+
+// Set of priority classes a job can be started with.
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+
+	numPriorities
+)
+
+// job represents a unit of work waiting for, or holding, a slot.
+type job struct {
+	key string
+	ctx context.Context
+	fn  JobFn
+	jobOptions
+
+	// Set once the job is launched.
+	cancel   context.CancelFunc
+	started  time.Time
+	deadline time.Time
+
+	// ready is closed once the job has been launched or dropped. When the
+	// job was dropped, err explains why.
+	ready chan struct{}
+	err   error
+}
+
+// jobOptions are the settings of a job given by JobOptions.
+type jobOptions struct {
+	priority Priority
+	tenant   string
+	labels   map[string]string
+}
+
+// JobOption configures a single job handed to Start or TryStart.
+type JobOption func(*jobOptions)
+
+// newJobOptions applies the options over the defaults.
+func newJobOptions(opts []JobOption) jobOptions {
+	o := jobOptions{priority: PriorityNormal}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// WithPriority sets the priority class of the job. Jobs default to
+// PriorityNormal.
+func WithPriority(p Priority) JobOption {
+	return func(o *jobOptions) {
+		if p < PriorityLow {
+			p = PriorityLow
+		}
+		if p > PriorityHigh {
+			p = PriorityHigh
+		}
+		o.priority = p
+	}
+}
+
+// WithTenant sets the tenant the job belongs to. Within a priority class,
+// waiting jobs are dispatched round robin across tenants so one tenant can't
+// starve the others.
+func WithTenant(tenant string) JobOption {
+	return func(o *jobOptions) {
+		o.tenant = tenant
+	}
+}
+
+// WithLabels attaches labels to the job. They are reported by Jobs and can be
+// matched by a Selector, for example to stop every job of a suspended tenant
+// or a deleted DID with StopAll.
+func WithLabels(labels map[string]string) JobOption {
+	return func(o *jobOptions) {
+		if o.labels == nil {
+			o.labels = make(map[string]string, len(labels))
+		}
+		maps.Copy(o.labels, labels)
+	}
+}
+
+// JobInfo describes a job known to the worker.
+type JobInfo struct {
+	Key      string
+	Priority Priority
+	Tenant   string
+	Labels   map[string]string
+
+	// Started and Deadline are zero while the job is waiting for a slot.
+	Started  time.Time
+	Deadline time.Time
+}
+
+// Running reports whether the job is executing.
+func (ji JobInfo) Running() bool {
+	return !ji.Started.IsZero()
+}
+
+// Selector reports whether a job should be included in a listing or bulk
+// operation.
+type Selector func(JobInfo) bool
+
+// MatchLabels selects the jobs carrying all of the given labels.
+func MatchLabels(labels map[string]string) Selector {
+	return func(ji JobInfo) bool {
+		for k, v := range labels {
+			if lv, exists := ji.Labels[k]; !exists || lv != v {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// MatchTenant selects the jobs belonging to the given tenant.
+func MatchTenant(tenant string) Selector {
+	return func(ji JobInfo) bool {
+		return ji.Tenant == tenant
+	}
+}
+
+func (j *job) info() JobInfo {
+	return JobInfo{
+		Key:      j.key,
+		Priority: j.priority,
+		Tenant:   j.tenant,
+		Labels:   maps.Clone(j.labels),
+		Started:  j.started,
+		Deadline: j.deadline,
+	}
+}
+
+// fairQueue holds the waiting jobs of a single priority class, grouped by
+// tenant and served round robin.
+type fairQueue struct {
+	tenants []string
+	jobs    map[string][]*job
+}
+
+func (q *fairQueue) push(j *job) {
+	if q.jobs == nil {
+		q.jobs = make(map[string][]*job)
+	}
+
+	if len(q.jobs[j.tenant]) == 0 {
+		q.tenants = append(q.tenants, j.tenant)
+	}
+	q.jobs[j.tenant] = append(q.jobs[j.tenant], j)
+}
+
+func (q *fairQueue) pop() *job {
+	if len(q.tenants) == 0 {
+		return nil
+	}
+
+	tenant := q.tenants[0]
+	q.tenants = q.tenants[1:]
+
+	jobs := q.jobs[tenant]
+	j := jobs[0]
+	jobs[0] = nil
+
+	// Move the tenant to the back of the line if it has more work waiting.
+	if len(jobs) > 1 {
+		q.jobs[tenant] = jobs[1:]
+		q.tenants = append(q.tenants, tenant)
+	} else {
+		delete(q.jobs, tenant)
+	}
+
+	return j
+}
+
+func (q *fairQueue) remove(j *job) bool {
+	jobs := q.jobs[j.tenant]
+	for i := range jobs {
+		if jobs[i] != j {
+			continue
+		}
+
+		jobs = append(jobs[:i], jobs[i+1:]...)
+		if len(jobs) > 0 {
+			q.jobs[j.tenant] = jobs
+			return true
+		}
+
+		delete(q.jobs, j.tenant)
+		for k, tenant := range q.tenants {
+			if tenant == j.tenant {
+				q.tenants = append(q.tenants[:k], q.tenants[k+1:]...)
+				break
+			}
+		}
+		return true
+	}
+
+	return false
+}
+
+// scheduler orders waiting jobs by priority class and tenant.
+type scheduler struct {
+	classes [numPriorities]fairQueue
+	byKey   map[string]*job
+}
+
+func newScheduler() *scheduler {
+	return &scheduler{
+		byKey: make(map[string]*job),
+	}
+}
+
+func (s *scheduler) len() int {
+	return len(s.byKey)
+}
+
+func (s *scheduler) push(j *job) {
+	s.classes[j.priority].push(j)
+	s.byKey[j.key] = j
+}
+
+// pop returns the next job to run, or nil if nothing is waiting.
+func (s *scheduler) pop() *job {
+	for p := numPriorities - 1; p >= PriorityLow; p-- {
+		if j := s.classes[p].pop(); j != nil {
+			delete(s.byKey, j.key)
+			return j
+		}
+	}
+
+	return nil
+}
+
+// remove takes the job with the given key out of the queue. It reports
+// false if the job is not waiting.
+func (s *scheduler) remove(key string) (*job, bool) {
+	j, exists := s.byKey[key]
+	if !exists {
+		return nil, false
+	}
+
+	s.classes[j.priority].remove(j)
+	delete(s.byKey, key)
+
+	return j, true
+}
+
+// drain empties the queue, returning every job that was waiting.
+func (s *scheduler) drain() []*job {
+	var jobs []*job
+	for j := s.pop(); j != nil; j = s.pop() {
+		jobs = append(jobs, j)
+	}
+
+	return jobs
+}
@@ -0,0 +1,240 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// missedRunGrace is how late an activation can fire before MissedRunSkip
+// considers it missed.
+const missedRunGrace = time.Second
+
+// MissedRunPolicy defines what a schedule does with activations that fell
+// due while it couldn't run them, for example because the worker was busy or
+// the process was suspended.
+type MissedRunPolicy int
+
+// Set of policies for missed activations.
+const (
+
+	// MissedRunCoalesce runs a single job for all the activations that fell
+	// due. This is the default.
+	MissedRunCoalesce MissedRunPolicy = iota
+
+	// MissedRunSkip drops late activations and waits for the next one.
+	MissedRunSkip
+
+	// MissedRunAll runs a job for every activation that fell due.
+	MissedRunAll
+)
+
+// entry is a recurring job registered with Schedule.
+type entry struct {
+	key           string
+	schedule      Schedule
+	fn            JobFn
+	jitter        time.Duration
+	timeout       time.Duration
+	skipIfRunning bool
+	missed        MissedRunPolicy
+	jobOpts       []JobOption
+
+	active atomic.Int32
+	stop   chan struct{}
+}
+
+// ScheduleOption configures a recurring job handed to Schedule.
+type ScheduleOption func(*entry)
+
+// WithJitter delays every activation by a random duration in [0, d) so
+// schedules shared by many nodes don't all fire at the same instant.
+func WithJitter(d time.Duration) ScheduleOption {
+	return func(e *entry) {
+		e.jitter = d
+	}
+}
+
+// WithTimeout sets the deadline of every run. It defaults to the time left
+// until the following activation.
+func WithTimeout(d time.Duration) ScheduleOption {
+	return func(e *entry) {
+		e.timeout = d
+	}
+}
+
+// WithSkipIfRunning skips an activation while the previous run is still
+// waiting for a slot or executing.
+func WithSkipIfRunning() ScheduleOption {
+	return func(e *entry) {
+		e.skipIfRunning = true
+	}
+}
+
+// WithMissedRunPolicy sets what happens to activations that fell due while
+// they couldn't run.
+func WithMissedRunPolicy(p MissedRunPolicy) ScheduleOption {
+	return func(e *entry) {
+		e.missed = p
+	}
+}
+
+// WithJobOptions sets the options every run is started with, such as its
+// priority or tenant.
+func WithJobOptions(opts ...JobOption) ScheduleOption {
+	return func(e *entry) {
+		e.jobOpts = append(e.jobOpts, opts...)
+	}
+}
+
+// Schedule registers a job to run on every activation of the schedule. Runs
+// are dispatched through Start, so they count against the concurrency limit
+// and queue like any other job. A schedule key is returned so the caller can
+// remove the schedule with Unschedule. All schedules stop on Shutdown.
+func (w *Worker) Schedule(s Schedule, jobFn JobFn, opts ...ScheduleOption) (string, error) {
+	e := entry{
+		key:      uuid.NewString(),
+		schedule: s,
+		fn:       jobFn,
+		stop:     make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(&e)
+	}
+
+	if e.jitter < 0 || e.timeout < 0 {
+		return "", errors.New("jitter and timeout can't be negative")
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.shutdown {
+		return "", ErrShuttingDown
+	}
+
+	w.schedules[e.key] = &e
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		w.runSchedule(&e)
+	}()
+
+	return e.key, nil
+}
+
+// Unschedule removes a recurring job. Runs already started are not affected
+// and can be cancelled with Stop.
+func (w *Worker) Unschedule(scheduleKey string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	e, exists := w.schedules[scheduleKey]
+	if !exists {
+		return fmt.Errorf("schedule[%s] does not exist", scheduleKey)
+	}
+
+	delete(w.schedules, scheduleKey)
+	close(e.stop)
+
+	return nil
+}
+
+// runSchedule waits for every activation of the entry and starts the runs
+// until the entry is removed or the worker shuts down.
+func (w *Worker) runSchedule(e *entry) {
+	next := e.schedule.Next(time.Now())
+
+	for !next.IsZero() {
+		var delay time.Duration
+		if e.jitter > 0 {
+			delay = rand.N(e.jitter)
+		}
+
+		timer := time.NewTimer(time.Until(next.Add(delay)))
+		select {
+		case <-w.isShutdown:
+			timer.Stop()
+			return
+		case <-e.stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		// Collect the activations that fell due while we were waiting,
+		// keeping next on the latest of them.
+		now := time.Now()
+		due := 1
+		following := e.schedule.Next(next)
+		for !following.IsZero() && !following.After(now) {
+			due++
+			next = following
+			following = e.schedule.Next(next)
+		}
+
+		runs := 1
+		switch e.missed {
+		case MissedRunAll:
+			runs = due
+		case MissedRunSkip:
+			if now.Sub(next) > delay+missedRunGrace {
+				runs = 0
+			}
+		}
+
+		for i := 0; i < runs; i++ {
+			if err := w.startRun(e, following); errors.Is(err, ErrShuttingDown) {
+				return
+			}
+		}
+
+		next = following
+	}
+}
+
+// startRun starts a single run of the entry, waiting for a slot if needed.
+func (w *Worker) startRun(e *entry, following time.Time) error {
+	if e.skipIfRunning && e.active.Load() > 0 {
+		return nil
+	}
+
+	timeout := e.timeout
+	if timeout == 0 {
+		timeout = time.Until(following)
+	}
+	if timeout <= 0 {
+		timeout = time.Second
+	}
+
+	// Stop waiting for a slot if the entry is removed in the meantime.
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-e.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	e.active.Add(1)
+	run := func(ctx context.Context) {
+		defer e.active.Add(-1)
+		e.fn(ctx)
+	}
+
+	if _, err := w.Start(ctx, run, e.jobOpts...); err != nil {
+		e.active.Add(-1)
+		return err
+	}
+
+	return nil
+}
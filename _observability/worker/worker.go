@@ -11,6 +11,12 @@ import (
 	"github.com/google/uuid"
 )
 
+// Set of errors returned when a job can't be started.
+var (
+	ErrShuttingDown = errors.New("shutting down")
+	ErrQueueFull    = errors.New("queue is full")
+)
+
 // JobFn defines a function that can execute work for a specific job.
 type JobFn func(ctx context.Context)
 
@@ -18,27 +24,53 @@ type JobFn func(ctx context.Context)
 type Worker struct {
 	wg         sync.WaitGroup
 	mu         sync.RWMutex
-	sem        chan bool
+	capacity   int
+	maxQueued  int
+	queue      *scheduler
+	shutdown   bool
 	isShutdown chan struct{}
-	running    map[string]context.CancelFunc
+	running    map[string]*job
+	schedules  map[string]*entry
+}
+
+// Option configures a Worker at construction time.
+type Option func(*Worker)
+
+// DefaultMaxQueued is the number of jobs that can wait for a free slot unless
+// set with WithMaxQueued.
+const DefaultMaxQueued = 1024
+
+// WithMaxQueued bounds the number of jobs that can wait for a free slot. Once
+// the queue is full, Start and TryStart fail with ErrQueueFull. Defaults to
+// DefaultMaxQueued.
+func WithMaxQueued(n int) Option {
+	return func(w *Worker) {
+		w.maxQueued = n
+	}
 }
 
 // New constructs a Worker for managing and executing jobs. The capacity value
 // represents the maximum number of G's that can be executing at any given time.
-func New(maxRunningJobs int) (*Worker, error) {
+func New(maxRunningJobs int, opts ...Option) (*Worker, error) {
 	if maxRunningJobs <= 0 {
 		return nil, errors.New("max running jobs must be greater than 0")
 	}
 
-	sem := make(chan bool, maxRunningJobs)
-	for i := 0; i < maxRunningJobs; i++ {
-		sem <- true
-	}
-
 	w := Worker{
-		sem:        sem,
+		capacity:   maxRunningJobs,
+		maxQueued:  DefaultMaxQueued,
+		queue:      newScheduler(),
 		isShutdown: make(chan struct{}),
-		running:    make(map[string]context.CancelFunc),
+		running:    make(map[string]*job),
+		schedules:  make(map[string]*entry),
+	}
+
+	for _, opt := range opts {
+		opt(&w)
+	}
+
+	if w.maxQueued <= 0 {
+		return nil, errors.New("max queued jobs must be greater than 0")
 	}
 
 	return &w, nil
@@ -52,22 +84,49 @@ func (w *Worker) Running() int {
 	return len(w.running)
 }
 
-// Shutdown waits for all jobs to complete before it returns.
-func (w *Worker) Shutdown(ctx context.Context) error {
+// Queued returns the number of jobs waiting for a free slot.
+func (w *Worker) Queued() int {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return w.queue.len()
+}
+
+// Shutdown stops accepting work, cancels every running job and waits for
+// all jobs to complete before it returns. It returns the keys of the jobs
+// that were cancelled. Calling Shutdown more than once is safe.
+func (w *Worker) Shutdown(ctx context.Context) ([]string, error) {
+	return w.Drain(ctx, 0)
+}
 
-	// Signal we are shutting down.
-	close(w.isShutdown)
+// Drain stops accepting work and gives running jobs up to the grace period
+// to finish on their own. Jobs still running after that are cancelled. It
+// waits for all jobs to complete, or for the context to be done, and returns
+// the keys of the jobs that had to be cancelled. Jobs waiting in the queue
+// are dropped right away since they never started.
+func (w *Worker) Drain(ctx context.Context, grace time.Duration) ([]string, error) {
 
-	// Call the cancel function for all running goroutines.
+	// Signal we are shutting down, which also stops every schedule, and
+	// drop all the work still waiting for a slot.
+	var dropped []*job
 	func() {
-		w.mu.RLock()
-		defer w.mu.RUnlock()
+		w.mu.Lock()
+		defer w.mu.Unlock()
 
-		for _, cancel := range w.running {
-			cancel()
+		if w.shutdown {
+			return
 		}
+
+		w.shutdown = true
+		close(w.isShutdown)
+		dropped = w.queue.drain()
 	}()
 
+	for _, j := range dropped {
+		j.err = ErrShuttingDown
+		close(j.ready)
+	}
+
 	// Launch a goroutine to wait for all the worker goroutines
 	// to complete their work.
 	ch := make(chan struct{})
@@ -76,90 +135,286 @@ func (w *Worker) Shutdown(ctx context.Context) error {
 		close(ch)
 	}()
 
+	// Let the running jobs finish within the grace period.
+	if grace > 0 {
+		timer := time.NewTimer(grace)
+		defer timer.Stop()
+
+		select {
+		case <-ch:
+			return nil, nil
+		case <-ctx.Done():
+		case <-timer.C:
+		}
+	}
+
+	// Call the cancel function for all the goroutines still running.
+	var cancelled []string
+	func() {
+		w.mu.RLock()
+		defer w.mu.RUnlock()
+
+		for workKey, j := range w.running {
+			j.cancel()
+			cancelled = append(cancelled, workKey)
+		}
+	}()
+
 	// Wait for the goroutines to report they are done or when
 	// the timeout is reached.
 	select {
 	case <-ch:
-		return nil
+		return cancelled, nil
 	case <-ctx.Done():
-		return ctx.Err()
+		return cancelled, ctx.Err()
 	}
 }
 
+// Resize changes the maximum number of jobs that can be executing at any
+// given time. When shrinking, running jobs are left alone and no new job is
+// started until the number of running jobs falls under the new capacity.
+func (w *Worker) Resize(maxRunningJobs int) error {
+	if maxRunningJobs <= 0 {
+		return errors.New("max running jobs must be greater than 0")
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.capacity = maxRunningJobs
+	w.dispatch()
+
+	return nil
+}
+
+// Capacity returns the maximum number of jobs that can be executing at any
+// given time.
+func (w *Worker) Capacity() int {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return w.capacity
+}
+
 // Start lookups a job by key and launches a goroutine to perform the work. A
-// work key is returned so the caller can cancel work early.
-func (w *Worker) Start(ctx context.Context, jobFn JobFn) (string, error) {
+// work key is returned so the caller can cancel work early. If all slots are
+// taken, Start waits in the queue until the job is dispatched according to
+// its priority and tenant.
+func (w *Worker) Start(ctx context.Context, jobFn JobFn, opts ...JobOption) (string, error) {
+	j := newJob(ctx, jobFn, opts)
+	if err := w.enqueue(j); err != nil {
+		return "", err
+	}
 
-	// We need to block here waiting to capture a semaphore, timeout or shutdown.
+	// We need to block here waiting to capture a slot, timeout or shutdown.
 	// The shutdown is first to handle that event as priority.
 	select {
 	case <-w.isShutdown:
-		return "", errors.New("shutting down")
+		if w.dequeue(j) {
+			return "", ErrShuttingDown
+		}
 	case <-ctx.Done():
-		return "", ctx.Err()
-	case <-w.sem:
+		if w.dequeue(j) {
+			return "", ctx.Err()
+		}
+	case <-j.ready:
 	}
 
-	// Need a unique key for this work.
-	workKey := uuid.NewString()
+	// The job left the queue on its own, wait for the final verdict.
+	<-j.ready
+	if j.err != nil {
+		return "", j.err
+	}
 
-	// Let's continue with the current context's deadline.
-	deadline, ok := ctx.Deadline()
-	if !ok {
-		deadline = time.Now().Add(time.Second)
+	return j.key, nil
+}
+
+// TryStart queues a job without blocking. The job is launched as soon as a
+// slot is free and its priority and tenant allow. If the queue is full the
+// call fails immediately with ErrQueueFull. The returned work key can be used
+// to cancel the job whether it's still waiting or already running.
+func (w *Worker) TryStart(ctx context.Context, jobFn JobFn, opts ...JobOption) (string, error) {
+	j := newJob(ctx, jobFn, opts)
+	if err := w.enqueue(j); err != nil {
+		return "", err
 	}
 
-	// Create a cancel function and keep it for stop/shutdown purposes.
-	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	return j.key, nil
+}
 
-	// Register this new G as running.
-	w.trackWork(workKey, cancel)
+// Stop is used to cancel an existing job that is running or waiting.
+func (w *Worker) Stop(workKey string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
 
-	// Launch a goroutine to perform the work.
-	w.wg.Add(1)
-	go func() {
+	if j, queued := w.queue.remove(workKey); queued {
+		j.err = context.Canceled
+		close(j.ready)
+		return nil
+	}
 
-		// Do this in a separate defer incase the other defer panics.
-		// This adds a value back to the semaphore allowing a new message
-		// to be processed.
-		defer func() { w.sem <- true }()
+	j, exists := w.running[workKey]
+	if !exists {
+		return fmt.Errorf("work[%s] is not running", workKey)
+	}
 
-		// We must call cancel regardless, remove the work key and report
-		// to the outer G we are done.
-		defer func() {
-			cancel()
-			w.removeWork(workKey)
-			w.wg.Done()
-		}()
+	// Call cancel to stop the work.
+	j.cancel()
 
-		// Execute the actually workload.
-		jobFn(ctx)
-	}()
+	return nil
+}
+
+// StopAll cancels every job, running or waiting, that matches the selector
+// and returns their work keys. A nil selector matches every job.
+func (w *Worker) StopAll(sel Selector) []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
 
-	return workKey, nil
+	var stopped []string
+	for _, info := range w.jobs(sel) {
+		if j, queued := w.queue.remove(info.Key); queued {
+			j.err = context.Canceled
+			close(j.ready)
+		} else {
+			w.running[info.Key].cancel()
+		}
+		stopped = append(stopped, info.Key)
+	}
+
+	return stopped
 }
 
-// Stop is used to cancel an existing job that is running.
-func (w *Worker) Stop(workKey string) error {
+// Jobs returns the metadata of every job, running or waiting, that matches
+// the selector. A nil selector matches every job.
+func (w *Worker) Jobs(sel Selector) []JobInfo {
 	w.mu.RLock()
 	defer w.mu.RUnlock()
 
-	cancel, exists := w.running[workKey]
-	if !exists {
-		return fmt.Errorf("work[%s] is not running", workKey)
+	return w.jobs(sel)
+}
+
+// jobs must be called with the lock held.
+func (w *Worker) jobs(sel Selector) []JobInfo {
+	var infos []JobInfo
+	add := func(j *job) {
+		info := j.info()
+		if sel == nil || sel(info) {
+			infos = append(infos, info)
+		}
 	}
 
-	// Call cancel to stop the work.
-	cancel()
+	for _, j := range w.running {
+		add(j)
+	}
+	for _, j := range w.queue.byKey {
+		add(j)
+	}
+
+	return infos
+}
+
+func newJob(ctx context.Context, jobFn JobFn, opts []JobOption) *job {
+	return &job{
+		key:        uuid.NewString(),
+		ctx:        ctx,
+		fn:         jobFn,
+		jobOptions: newJobOptions(opts),
+		ready:      make(chan struct{}),
+	}
+}
+
+// enqueue places the job in the queue and dispatches as much waiting work as
+// there are free slots.
+func (w *Worker) enqueue(j *job) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.shutdown {
+		return ErrShuttingDown
+	}
+
+	if err := j.ctx.Err(); err != nil {
+		return err
+	}
+
+	// A job that can run right away never counts against the queue. There
+	// are no free slots when the worker was shrunk below its running jobs.
+	free := max(w.capacity-len(w.running), 0)
+	if w.queue.len()-free >= w.maxQueued {
+		return ErrQueueFull
+	}
+
+	w.queue.push(j)
+	w.dispatch()
 
 	return nil
 }
 
-func (w *Worker) trackWork(workKey string, cancel context.CancelFunc) {
+// dequeue removes a job still waiting in the queue. It reports false if the
+// job has already been launched or dropped.
+func (w *Worker) dequeue(j *job) bool {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
-	w.running[workKey] = cancel
+	_, queued := w.queue.remove(j.key)
+	return queued
+}
+
+// dispatch launches waiting jobs while there are free slots. It must be
+// called with the lock held.
+func (w *Worker) dispatch() {
+	for !w.shutdown && len(w.running) < w.capacity {
+		j := w.queue.pop()
+		if j == nil {
+			return
+		}
+
+		// The caller gave up on this job while it was waiting.
+		if err := j.ctx.Err(); err != nil {
+			j.err = err
+			close(j.ready)
+			continue
+		}
+
+		w.launch(j)
+		close(j.ready)
+	}
+}
+
+// launch starts the goroutine executing the job. It must be called with the
+// lock held.
+func (w *Worker) launch(j *job) {
+
+	// Let's continue with the current context's deadline.
+	deadline, ok := j.ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(time.Second)
+	}
+
+	// Create a cancel function and keep it for stop/shutdown purposes.
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+
+	// Register this new G as running.
+	j.cancel = cancel
+	j.started = time.Now()
+	j.deadline = deadline
+	w.running[j.key] = j
+
+	// Launch a goroutine to perform the work.
+	w.wg.Add(1)
+	go func() {
+
+		// We must call cancel regardless, remove the work key, hand the
+		// slot to the next job in line and report to the outer G we are done.
+		defer func() {
+			cancel()
+			w.removeWork(j.key)
+			w.wg.Done()
+		}()
+
+		// Execute the actually workload.
+		j.fn(ctx)
+	}()
 }
 
 func (w *Worker) removeWork(workKey string) {
@@ -167,4 +422,5 @@ func (w *Worker) removeWork(workKey string) {
 	defer w.mu.Unlock()
 
 	delete(w.running, workKey)
+	w.dispatch()
 }
@@ -1,12 +1,16 @@
-package worker
-
+package worker_test
 
 import (
-	"EncrypteDL/EncryrpteID/observability/worker"
 	"context"
+	"errors"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+
+	"EncrypteDL/EncryrpteID/_observability/worker"
 )
 
 func Test_Worker(t *testing.T) {
@@ -48,7 +52,7 @@ func Test_Worker(t *testing.T) {
 	}
 
 	// Shutdown the system with no work.
-	if err := w.Shutdown(context.Background()); err != nil {
+	if _, err := w.Shutdown(context.Background()); err != nil {
 		t.Fatalf("Should be able to shutdown work cleanly : %s", err)
 	}
 }
@@ -86,7 +90,7 @@ func Test_CancelWorker(t *testing.T) {
 	// Give all the jobs 1 second to shut down cleanly.
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 	defer cancel()
-	if err := w.Shutdown(ctx); err != nil {
+	if _, err := w.Shutdown(ctx); err != nil {
 		t.Fatalf("Should be able to shutdown work cleanly : %s", err)
 	}
 }
@@ -146,7 +150,741 @@ func Test_StopWorker(t *testing.T) {
 	}
 
 	// Shutdown the system with no work.
-	if err := w.Shutdown(context.Background()); err != nil {
+	if _, err := w.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Should be able to shutdown work cleanly : %s", err)
+	}
+}
+
+func Test_PriorityWorker(t *testing.T) {
+	w, err := worker.New(1)
+	if err != nil {
+		t.Fatalf("Should be able to create a worker with max 1 : %s", err)
+	}
+
+	// Occupy the only slot until we are ready to observe the queue order.
+	release := make(chan struct{})
+	block := func(ctx context.Context) {
+		<-release
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := w.Start(ctx, block); err != nil {
+		t.Fatalf("Should be able to execute work : %s", err)
+	}
+
+	var mu sync.Mutex
+	var order []string
+	var wg sync.WaitGroup
+	record := func(name string) worker.JobFn {
+		wg.Add(1)
+		return func(ctx context.Context) {
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+			wg.Done()
+		}
+	}
+
+	jobs := []struct {
+		name string
+		opts []worker.JobOption
+	}{
+		{"batch", []worker.JobOption{worker.WithPriority(worker.PriorityLow)}},
+		{"a1", []worker.JobOption{worker.WithTenant("a")}},
+		{"a2", []worker.JobOption{worker.WithTenant("a")}},
+		{"a3", []worker.JobOption{worker.WithTenant("a")}},
+		{"b1", []worker.JobOption{worker.WithTenant("b")}},
+		{"verify", []worker.JobOption{worker.WithPriority(worker.PriorityHigh)}},
+	}
+	for _, job := range jobs {
+		if _, err := w.TryStart(ctx, record(job.name), job.opts...); err != nil {
+			t.Fatalf("Should be able to queue work %s : %s", job.name, err)
+		}
+	}
+
+	if q := w.Queued(); q != len(jobs) {
+		t.Fatalf("Should have %d jobs queued, got %d", len(jobs), q)
+	}
+
+	close(release)
+	wg.Wait()
+
+	exp := []string{"verify", "a1", "b1", "a2", "a3", "batch"}
+	for i := range exp {
+		if order[i] != exp[i] {
+			t.Errorf("Exp: %v", exp)
+			t.Errorf("Got: %v", order)
+			t.Fatal("Should dispatch jobs by priority and round robin across tenants")
+		}
+	}
+
+	if _, err := w.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Should be able to shutdown work cleanly : %s", err)
+	}
+}
+
+func Test_QueueFullWorker(t *testing.T) {
+	w, err := worker.New(1, worker.WithMaxQueued(1))
+	if err != nil {
+		t.Fatalf("Should be able to create a worker with max 1 : %s", err)
+	}
+
+	work := func(ctx context.Context) {
+		<-ctx.Done()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := w.TryStart(ctx, work); err != nil {
+		t.Fatalf("Should be able to execute work : %s", err)
+	}
+	queued, err := w.TryStart(ctx, work)
+	if err != nil {
+		t.Fatalf("Should be able to queue work : %s", err)
+	}
+
+	if _, err := w.TryStart(ctx, work); err != worker.ErrQueueFull {
+		t.Fatalf("Should not be able to queue work on a full queue : %v", err)
+	}
+	if _, err := w.Start(ctx, work); err != worker.ErrQueueFull {
+		t.Fatalf("Should not be able to wait on a full queue : %v", err)
+	}
+
+	// Stopping the waiting job frees its place in the queue.
+	if err := w.Stop(queued); err != nil {
+		t.Fatalf("Should be able to stop queued work : %s", err)
+	}
+	if _, err := w.TryStart(ctx, work); err != nil {
+		t.Fatalf("Should be able to queue work : %s", err)
+	}
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), time.Second)
+	defer shutdownCancel()
+	if _, err := w.Shutdown(shutdownCtx); err != nil {
+		t.Fatalf("Should be able to shutdown work cleanly : %s", err)
+	}
+
+	if q := w.Queued(); q != 0 {
+		t.Fatalf("Should have dropped queued work on shutdown, got %d", q)
+	}
+}
+
+func Test_DefaultQueueBound(t *testing.T) {
+	if _, err := worker.New(1, worker.WithMaxQueued(0)); err == nil {
+		t.Fatal("Should not be able to create a worker with an unbounded queue")
+	}
+
+	w, err := worker.New(1)
+	if err != nil {
+		t.Fatalf("Should be able to create a worker with max 1 : %s", err)
+	}
+
+	work := func(ctx context.Context) {
+		<-ctx.Done()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// One job runs, the others wait until the queue is full.
+	for i := 0; i <= worker.DefaultMaxQueued; i++ {
+		if _, err := w.TryStart(ctx, work); err != nil {
+			t.Fatalf("Should be able to queue work %d : %s", i, err)
+		}
+	}
+	if _, err := w.TryStart(ctx, work); err != worker.ErrQueueFull {
+		t.Fatalf("Should not be able to queue work past the default bound : %v", err)
+	}
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), time.Second)
+	defer shutdownCancel()
+	if _, err := w.Shutdown(shutdownCtx); err != nil {
+		t.Fatalf("Should be able to shutdown work cleanly : %s", err)
+	}
+}
+
+func Test_ParseCron(t *testing.T) {
+	base := time.Date(2024, time.July, 15, 10, 30, 20, 0, time.UTC) // Monday
+
+	tt := []struct {
+		expr string
+		exp  time.Time
+	}{
+		{"* * * * *", time.Date(2024, time.July, 15, 10, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, time.July, 15, 10, 45, 0, 0, time.UTC)},
+		{"*/10 * * * * *", time.Date(2024, time.July, 15, 10, 30, 30, 0, time.UTC)},
+		{"0 9-17 * * mon-fri", time.Date(2024, time.July, 15, 11, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, time.July, 21, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1,15 * *", time.Date(2024, time.August, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 feb *", time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2024, time.July, 16, 0, 0, 0, 0, time.UTC)},
+		{"@every 90s", base.Add(90 * time.Second)},
+	}
+
+	for _, tst := range tt {
+		s, err := worker.ParseCron(tst.expr)
+		if err != nil {
+			t.Fatalf("Should be able to parse %q : %s", tst.expr, err)
+		}
+		if got := s.Next(base); !got.Equal(tst.exp) {
+			t.Errorf("Exp: %s", tst.exp)
+			t.Errorf("Got: %s", got)
+			t.Errorf("Should compute the next activation of %q", tst.expr)
+		}
+	}
+
+	for _, expr := range []string{"", "* * *", "60 * * * *", "* * * 13 *", "*/0 * * * *", "5-1 * * * *", "@every -1s"} {
+		if _, err := worker.ParseCron(expr); err == nil {
+			t.Errorf("Should not be able to parse %q", expr)
+		}
+	}
+}
+
+func Test_ScheduleWorker(t *testing.T) {
+	w, err := worker.New(2)
+	if err != nil {
+		t.Fatalf("Should be able to create a worker with max 2 : %s", err)
+	}
+
+	var runs atomic.Int32
+	work := func(ctx context.Context) {
+		runs.Add(1)
+	}
+	if _, err := w.Schedule(worker.Every(10*time.Millisecond), work); err != nil {
+		t.Fatalf("Should be able to schedule work : %s", err)
+	}
+
+	// A long running job should never overlap with itself.
+	var active, overlaps atomic.Int32
+	slow := func(ctx context.Context) {
+		if active.Add(1) > 1 {
+			overlaps.Add(1)
+		}
+		defer active.Add(-1)
+		time.Sleep(50 * time.Millisecond)
+	}
+	opts := []worker.ScheduleOption{
+		worker.WithSkipIfRunning(),
+		worker.WithTimeout(time.Second),
+		worker.WithJobOptions(worker.WithPriority(worker.PriorityLow)),
+	}
+	key, err := w.Schedule(worker.Every(10*time.Millisecond), slow, opts...)
+	if err != nil {
+		t.Fatalf("Should be able to schedule work : %s", err)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	if n := runs.Load(); n < 5 {
+		t.Errorf("Should have run the schedule several times, got %d", n)
+	}
+	if n := overlaps.Load(); n != 0 {
+		t.Errorf("Should have skipped activations while running, got %d overlaps", n)
+	}
+
+	if err := w.Unschedule(key); err != nil {
+		t.Fatalf("Should be able to unschedule work : %s", err)
+	}
+	if err := w.Unschedule(key); err == nil {
+		t.Fatal("Should not be able to unschedule work twice")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := w.Shutdown(ctx); err != nil {
+		t.Fatalf("Should be able to shutdown work cleanly : %s", err)
+	}
+
+	if _, err := w.Schedule(worker.Every(time.Second), work); err != worker.ErrShuttingDown {
+		t.Fatalf("Should not be able to schedule work after shutdown : %v", err)
+	}
+}
+
+func Test_DurableQueue(t *testing.T) {
+	w, err := worker.New(2)
+	if err != nil {
+		t.Fatalf("Should be able to create a worker with max 2 : %s", err)
+	}
+
+	q, err := worker.NewDurableQueue(w, "", worker.WithRetryDelay(10*time.Millisecond), worker.WithMaxAttempts(3))
+	if err != nil {
+		t.Fatalf("Should be able to open a durable queue : %s", err)
+	}
+
+	// Fail every job once before acknowledging it.
+	var mu sync.Mutex
+	attempts := make(map[string]int)
+	done := make(chan string, 10)
+	q.Register("issue", func(ctx context.Context, payload []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		attempts[string(payload)]++
+		if attempts[string(payload)] == 1 {
+			return errors.New("transient failure")
+		}
+
+		done <- string(payload)
+		return nil
+	})
+	q.Register("poison", func(ctx context.Context, payload []byte) error {
+		return errors.New("permanent failure")
+	})
+
+	for _, payload := range []string{"a", "b", "c"} {
+		if _, err := q.Enqueue("issue", []byte(payload)); err != nil {
+			t.Fatalf("Should be able to enqueue a job : %s", err)
+		}
+	}
+	poison, err := q.Enqueue("poison", nil, worker.WithPriority(worker.PriorityLow))
+	if err != nil {
+		t.Fatalf("Should be able to enqueue a job : %s", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Should be able to deliver every job")
+		}
+	}
+
+	// Wait for the poison job to run out of attempts.
+	var dead []string
+	for i := 0; i < 50 && len(dead) == 0; i++ {
+		time.Sleep(20 * time.Millisecond)
+		if dead, err = q.DeadLetters(); err != nil {
+			t.Fatalf("Should be able to list dead letters : %s", err)
+		}
+	}
+	if len(dead) != 1 || dead[0] != poison {
+		t.Fatalf("Should have moved the poison job to the dead letters, got %v", dead)
+	}
+
+	for i := 0; i < 50; i++ {
+		if n, _ := q.Pending(); n == 0 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if n, err := q.Pending(); err != nil || n != 0 {
+		t.Fatalf("Should have acknowledged every job, got %d pending : %v", n, err)
+	}
+
+	if _, err := w.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Should be able to shutdown work cleanly : %s", err)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatalf("Should be able to close the durable queue : %s", err)
+	}
+}
+
+func Test_DurableQueueBusyWorker(t *testing.T) {
+	w, err := worker.New(1)
+	if err != nil {
+		t.Fatalf("Should be able to create a worker with max 1 : %s", err)
+	}
+
+	// Hold the only slot for longer than several leases.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	release := make(chan struct{})
+	if _, err := w.TryStart(ctx, func(ctx context.Context) {
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+	}); err != nil {
+		t.Fatalf("Should be able to execute work : %s", err)
+	}
+
+	q, err := worker.NewDurableQueue(w, "", worker.WithVisibilityTimeout(50*time.Millisecond),
+		worker.WithRetryDelay(10*time.Millisecond), worker.WithMaxAttempts(2), worker.WithPollInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Should be able to open a durable queue : %s", err)
+	}
+
+	// Deliveries that never ran don't count, the job gets its two attempts.
+	var runs atomic.Int32
+	done := make(chan struct{})
+	q.Register("issue", func(ctx context.Context, payload []byte) error {
+		if runs.Add(1) == 1 {
+			return errors.New("transient failure")
+		}
+		close(done)
+		return nil
+	})
+	if _, err := q.Enqueue("issue", nil); err != nil {
+		t.Fatalf("Should be able to enqueue a job : %s", err)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	if n := runs.Load(); n != 0 {
+		t.Fatalf("Should not have run the job without a free slot, ran %d times", n)
+	}
+	close(release)
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		dead, _ := q.DeadLetters()
+		t.Fatalf("Should be able to retry the job, ran %d times, dead letters %v", runs.Load(), dead)
+	}
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), time.Second)
+	defer shutdownCancel()
+	if _, err := w.Shutdown(shutdownCtx); err != nil {
+		t.Fatalf("Should be able to shutdown work cleanly : %s", err)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatalf("Should be able to close the durable queue : %s", err)
+	}
+}
+
+func Test_DurableQueueStoreErrors(t *testing.T) {
+	w, err := worker.New(2)
+	if err != nil {
+		t.Fatalf("Should be able to create a worker with max 2 : %s", err)
+	}
+
+	var mu sync.Mutex
+	var errs []error
+	q, err := worker.NewDurableQueue(w, "", worker.WithErrorHandler(func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, err)
+	}))
+	if err != nil {
+		t.Fatalf("Should be able to open a durable queue : %s", err)
+	}
+
+	// Both jobs finish once the store is closed.
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	q.Register("ack", func(ctx context.Context, payload []byte) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	})
+	q.Register("nack", func(ctx context.Context, payload []byte) error {
+		started <- struct{}{}
+		<-release
+		return errors.New("failure")
+	})
+	for _, kind := range []string{"ack", "nack"} {
+		if _, err := q.Enqueue(kind, nil); err != nil {
+			t.Fatalf("Should be able to enqueue a job : %s", err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case <-started:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Should be able to deliver every job")
+		}
+	}
+
+	if err := q.Close(); err != nil {
+		t.Fatalf("Should be able to close the durable queue : %s", err)
+	}
+	close(release)
+	if _, err := w.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Should be able to shutdown work cleanly : %s", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(errs) != 2 {
+		t.Fatalf("Should have reported the acknowledgement of both jobs, got %v", errs)
+	}
+	for _, err := range errs {
+		if !errors.Is(err, leveldb.ErrClosed) {
+			t.Errorf("Should have reported the closed store, got %v", err)
+		}
+	}
+}
+
+func Test_DurableQueueRecovery(t *testing.T) {
+	path := t.TempDir()
+
+	w, err := worker.New(1)
+	if err != nil {
+		t.Fatalf("Should be able to create a worker with max 1 : %s", err)
+	}
+	q, err := worker.NewDurableQueue(w, path, worker.WithVisibilityTimeout(time.Hour))
+	if err != nil {
+		t.Fatalf("Should be able to open a durable queue : %s", err)
+	}
+
+	// Simulate a crash: the job is leased but never acknowledged.
+	started := make(chan struct{})
+	release := make(chan struct{})
+	q.Register("anchor", func(ctx context.Context, payload []byte) error {
+		close(started)
+		<-release
+		return nil
+	})
+	if _, err := q.Enqueue("anchor", []byte("did:example:123")); err != nil {
+		t.Fatalf("Should be able to enqueue a job : %s", err)
+	}
+	<-started
+	if err := q.Close(); err != nil {
+		t.Fatalf("Should be able to close the durable queue : %s", err)
+	}
+	close(release)
+
+	if _, err := w.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Should be able to shutdown work cleanly : %s", err)
+	}
+
+	// On restart the in-flight job is delivered again right away even
+	// though its lease is an hour long.
+	w, err = worker.New(1)
+	if err != nil {
+		t.Fatalf("Should be able to create a worker with max 1 : %s", err)
+	}
+	q, err = worker.NewDurableQueue(w, path, worker.WithVisibilityTimeout(time.Hour))
+	if err != nil {
+		t.Fatalf("Should be able to reopen the durable queue : %s", err)
+	}
+
+	redelivered := make(chan string, 1)
+	q.Register("anchor", func(ctx context.Context, payload []byte) error {
+		redelivered <- string(payload)
+		return nil
+	})
+
+	select {
+	case payload := <-redelivered:
+		if payload != "did:example:123" {
+			t.Fatalf("Should redeliver the same payload, got %q", payload)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Should redeliver the in-flight job after a restart")
+	}
+
+	if _, err := w.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Should be able to shutdown work cleanly : %s", err)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatalf("Should be able to close the durable queue : %s", err)
+	}
+}
+
+func Test_ResizeWorker(t *testing.T) {
+	w, err := worker.New(1)
+	if err != nil {
+		t.Fatalf("Should be able to create a worker with max 1 : %s", err)
+	}
+
+	release := make(chan struct{})
+	work := func(ctx context.Context) {
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for i := 0; i < 3; i++ {
+		if _, err := w.TryStart(ctx, work); err != nil {
+			t.Fatalf("Should be able to queue work : %s", err)
+		}
+	}
+	if r, q := w.Running(), w.Queued(); r != 1 || q != 2 {
+		t.Fatalf("Should have 1 job running and 2 queued, got %d and %d", r, q)
+	}
+
+	if err := w.Resize(0); err == nil {
+		t.Fatal("Should not be able to resize to 0")
+	}
+	if err := w.Resize(3); err != nil {
+		t.Fatalf("Should be able to resize to 3 : %s", err)
+	}
+	if r, q := w.Running(), w.Queued(); r != 3 || q != 0 {
+		t.Fatalf("Should have 3 jobs running and none queued, got %d and %d", r, q)
+	}
+
+	// Shrinking leaves running jobs alone.
+	if err := w.Resize(1); err != nil {
+		t.Fatalf("Should be able to resize to 1 : %s", err)
+	}
+	if _, err := w.TryStart(ctx, work); err != nil {
+		t.Fatalf("Should be able to queue work : %s", err)
+	}
+	if r, q, c := w.Running(), w.Queued(), w.Capacity(); r != 3 || q != 1 || c != 1 {
+		t.Fatalf("Should have 3 jobs running, 1 queued and a capacity of 1, got %d, %d and %d", r, q, c)
+	}
+
+	close(release)
+
+	if _, err := w.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Should be able to shutdown work cleanly : %s", err)
+	}
+}
+
+func Test_ResizeBelowRunningWorker(t *testing.T) {
+	w, err := worker.New(3, worker.WithMaxQueued(1))
+	if err != nil {
+		t.Fatalf("Should be able to create a worker with max 3 : %s", err)
+	}
+
+	work := func(ctx context.Context) {
+		<-ctx.Done()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for i := 0; i < 3; i++ {
+		if _, err := w.TryStart(ctx, work); err != nil {
+			t.Fatalf("Should be able to execute work : %s", err)
+		}
+	}
+	if err := w.Resize(1); err != nil {
+		t.Fatalf("Should be able to resize to 1 : %s", err)
+	}
+
+	// The queue is empty even though more jobs run than the capacity allows.
+	waitCtx, waitCancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer waitCancel()
+	if _, err := w.Start(waitCtx, work); err != context.DeadlineExceeded {
+		t.Fatalf("Should be able to wait in the queue until the deadline : %v", err)
+	}
+	if _, err := w.TryStart(ctx, work); err != nil {
+		t.Fatalf("Should be able to queue work : %s", err)
+	}
+	if _, err := w.TryStart(ctx, work); err != worker.ErrQueueFull {
+		t.Fatalf("Should not be able to queue work on a full queue : %v", err)
+	}
+	if r, q := w.Running(), w.Queued(); r != 3 || q != 1 {
+		t.Fatalf("Should have 3 jobs running and 1 queued, got %d and %d", r, q)
+	}
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), time.Second)
+	defer shutdownCancel()
+	if _, err := w.Shutdown(shutdownCtx); err != nil {
+		t.Fatalf("Should be able to shutdown work cleanly : %s", err)
+	}
+}
+
+func Test_DrainWorker(t *testing.T) {
+	w, err := worker.New(2)
+	if err != nil {
+		t.Fatalf("Should be able to create a worker with max 2 : %s", err)
+	}
+
+	// One job finishes within the grace period, the other one never does.
+	var wg sync.WaitGroup
+	wg.Add(2)
+	quick := func(ctx context.Context) {
+		wg.Done()
+		time.Sleep(20 * time.Millisecond)
+	}
+	stuck := func(ctx context.Context) {
+		wg.Done()
+		<-ctx.Done()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := w.Start(ctx, quick); err != nil {
+		t.Fatalf("Should be able to execute work : %s", err)
+	}
+	stuckKey, err := w.Start(ctx, stuck)
+	if err != nil {
+		t.Fatalf("Should be able to execute work : %s", err)
+	}
+	wg.Wait()
+
+	cancelled, err := w.Drain(ctx, 100*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Should be able to drain work cleanly : %s", err)
+	}
+	if len(cancelled) != 1 || cancelled[0] != stuckKey {
+		t.Fatalf("Should have force-cancelled only the stuck job, got %v", cancelled)
+	}
+
+	if _, err := w.Start(ctx, quick); err != worker.ErrShuttingDown {
+		t.Fatalf("Should not be able to execute work while draining : %v", err)
+	}
+
+	// Shutting down again is a no-op.
+	cancelled, err = w.Shutdown(context.Background())
+	if err != nil || len(cancelled) != 0 {
+		t.Fatalf("Should be able to shutdown twice, got %v : %v", cancelled, err)
+	}
+}
+
+func Test_StopAllWorker(t *testing.T) {
+	w, err := worker.New(2)
+	if err != nil {
+		t.Fatalf("Should be able to create a worker with max 2 : %s", err)
+	}
+
+	work := func(ctx context.Context) {
+		<-ctx.Done()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Two jobs for the DID being deleted, one running and one waiting,
+	// and one unrelated job.
+	did := map[string]string{"did": "did:example:123"}
+	for _, opts := range [][]worker.JobOption{
+		{worker.WithTenant("acme"), worker.WithLabels(did)},
+		{worker.WithTenant("globex")},
+		{worker.WithTenant("acme"), worker.WithLabels(did), worker.WithLabels(map[string]string{"kind": "anchor"})},
+	} {
+		if _, err := w.TryStart(ctx, work, opts...); err != nil {
+			t.Fatalf("Should be able to queue work : %s", err)
+		}
+	}
+
+	jobs := w.Jobs(worker.MatchLabels(did))
+	if len(jobs) != 2 {
+		t.Fatalf("Should list 2 jobs for the DID, got %d", len(jobs))
+	}
+	var running int
+	for _, ji := range jobs {
+		if ji.Tenant != "acme" || ji.Labels["did"] != did["did"] {
+			t.Errorf("Should report the job metadata, got %+v", ji)
+		}
+		if ji.Running() {
+			running++
+		}
+	}
+	if running != 1 {
+		t.Fatalf("Should have 1 job for the DID running, got %d", running)
+	}
+
+	stopped := w.StopAll(worker.MatchLabels(did))
+	if len(stopped) != 2 {
+		t.Fatalf("Should have stopped 2 jobs, got %d", len(stopped))
+	}
+
+	for i := 0; i < 10; i++ {
+		if len(w.Jobs(nil)) == 1 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if jobs := w.Jobs(nil); len(jobs) != 1 || jobs[0].Tenant != "globex" || !jobs[0].Running() {
+		t.Fatalf("Should only have the unrelated job left, got %+v", jobs)
+	}
+
+	if stopped := w.StopAll(worker.MatchTenant("globex")); len(stopped) != 1 {
+		t.Fatalf("Should have stopped 1 job, got %d", len(stopped))
+	}
+
+	if _, err := w.Shutdown(context.Background()); err != nil {
 		t.Fatalf("Should be able to shutdown work cleanly : %s", err)
 	}
 }
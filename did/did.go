@@ -0,0 +1,212 @@
+// Package did implements decentralized identifiers as specified by W3C DID
+// Core 1.0: the syntax of DIDs and DID URLs, the DID document data model with
+// its JSON and JSON-LD representations, and the interface of the resolvers
+// turning a DID into its document.
+package did
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// DID is a decentralized identifier, did:<method>:<method specific id>.
+type DID struct {
+	Method string
+	ID     string // Method specific identifier
+}
+
+// Parse parses a DID. It fails with an error matching ErrInvalidDID if s
+// isn't a DID, DID URLs included.
+func Parse(s string) (DID, error) {
+	rest, ok := strings.CutPrefix(s, "did:")
+	if !ok {
+		return DID{}, invalid(s, "missing did scheme")
+	}
+	method, id, ok := strings.Cut(rest, ":")
+	if !ok {
+		return DID{}, invalid(s, "missing method specific identifier")
+	}
+	if method == "" {
+		return DID{}, invalid(s, "empty method name")
+	}
+	for i := 0; i < len(method); i++ {
+		if c := method[i]; !('a' <= c && c <= 'z' || '0' <= c && c <= '9') {
+			return DID{}, invalid(s, fmt.Sprintf("invalid character %q in method name", c))
+		}
+	}
+	if err := checkID(id); err != nil {
+		return DID{}, invalid(s, err.Error())
+	}
+	return DID{Method: method, ID: id}, nil
+}
+
+// MustParse is like Parse but panics if s isn't a DID.
+func MustParse(s string) DID {
+	d, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// String returns the DID as a string.
+func (d DID) String() string {
+	return "did:" + d.Method + ":" + d.ID
+}
+
+// IsZero reports whether d is the zero DID.
+func (d DID) IsZero() bool {
+	return d == DID{}
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (d DID) MarshalText() ([]byte, error) {
+	if d.IsZero() {
+		return nil, nil
+	}
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (d *DID) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*d = DID{}
+		return nil
+	}
+	parsed, err := Parse(string(text))
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// checkID checks the method specific identifier: colon separated segments of
+// idchar, the last one not empty.
+func checkID(id string) error {
+	if id == "" || strings.HasSuffix(id, ":") {
+		return fmt.Errorf("method specific identifier %q ends with an empty segment", id)
+	}
+	for i := 0; i < len(id); i++ {
+		switch c := id[i]; {
+		case c == '%':
+			if i+2 >= len(id) || !isHex(id[i+1]) || !isHex(id[i+2]) {
+				return fmt.Errorf("invalid percent encoding at %d", i)
+			}
+			i += 2
+		case !isIDChar(c) && c != ':':
+			return fmt.Errorf("invalid character %q in method specific identifier", c)
+		}
+	}
+	return nil
+}
+
+func isIDChar(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '.' || c == '-' || c == '_'
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+// URL is a DID URL, a DID followed by an optional path, query and fragment.
+// Relative DID URLs, such as "#key-1" in a document, have a zero DID.
+type URL struct {
+	DID
+	Path     string // Path with its leading slash, percent encoded
+	Query    string // Query without the question mark, percent encoded
+	Fragment string // Fragment without the hash sign, percent encoded
+}
+
+// ParseURL parses an absolute DID URL.
+func ParseURL(s string) (URL, error) {
+	end := strings.IndexAny(s, "/?#")
+	if end < 0 {
+		end = len(s)
+	}
+	d, err := Parse(s[:end])
+	if err != nil {
+		return URL{}, err
+	}
+	u, err := parseReference(s[end:])
+	if err != nil {
+		return URL{}, invalid(s, err.Error())
+	}
+	u.DID = d
+	return u, nil
+}
+
+// ParseRelativeURL parses a DID URL relative to a DID document, a path, query
+// or fragment only. Absolute DID URLs are accepted too.
+func ParseRelativeURL(s string) (URL, error) {
+	if strings.HasPrefix(s, "did:") {
+		return ParseURL(s)
+	}
+	if s == "" || !strings.ContainsRune("/?#", rune(s[0])) {
+		return URL{}, invalid(s, "not a relative DID URL")
+	}
+	u, err := parseReference(s)
+	if err != nil {
+		return URL{}, invalid(s, err.Error())
+	}
+	return u, nil
+}
+
+// parseReference parses the path, query and fragment of a DID URL.
+func parseReference(s string) (URL, error) {
+	var u URL
+	s, u.Fragment, _ = strings.Cut(s, "#")
+	u.Path, u.Query, _ = strings.Cut(s, "?")
+	for name, part := range map[string]string{"path": u.Path, "query": u.Query, "fragment": u.Fragment} {
+		if _, err := url.PathUnescape(part); err != nil {
+			return URL{}, fmt.Errorf("invalid %s: %w", name, err)
+		}
+		if strings.ContainsAny(part, " \"<>\\^`{|}") {
+			return URL{}, fmt.Errorf("invalid character in %s", name)
+		}
+	}
+	if strings.HasPrefix(u.Path, "//") {
+		return URL{}, fmt.Errorf("path %q starts with an empty segment", u.Path)
+	}
+	if strings.Contains(u.Fragment, "#") {
+		return URL{}, fmt.Errorf("invalid fragment %q", u.Fragment)
+	}
+	return u, nil
+}
+
+// IsRelative reports whether u has no DID.
+func (u URL) IsRelative() bool {
+	return u.DID.IsZero()
+}
+
+// Resolve returns u made absolute with base, the DID of the document it
+// appears in.
+func (u URL) Resolve(base DID) URL {
+	if u.IsRelative() {
+		u.DID = base
+	}
+	return u
+}
+
+// Params returns the DID parameters of the query, such as versionId and
+// versionTime.
+func (u URL) Params() (url.Values, error) {
+	return url.ParseQuery(u.Query)
+}
+
+// String returns the DID URL as a string.
+func (u URL) String() string {
+	var b strings.Builder
+	if !u.IsRelative() {
+		b.WriteString(u.DID.String())
+	}
+	b.WriteString(u.Path)
+	if u.Query != "" {
+		b.WriteString("?" + u.Query)
+	}
+	if u.Fragment != "" {
+		b.WriteString("#" + u.Fragment)
+	}
+	return b.String()
+}
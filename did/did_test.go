@@ -0,0 +1,269 @@
+package did
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want DID
+	}{
+		{"did:example:123456789abcdefghi", DID{"example", "123456789abcdefghi"}},
+		{"did:ethr:0x5:0xb9c5714089478a327f09197987f16f9e5d936e8a", DID{"ethr", "0x5:0xb9c5714089478a327f09197987f16f9e5d936e8a"}},
+		{"did:web:example.com%3A8443", DID{"web", "example.com%3A8443"}},
+		{"did:key:z6Mk-_.", DID{"key", "z6Mk-_."}},
+		{"did:a1::x", DID{"a1", ":x"}},
+	} {
+		have, err := Parse(tt.in)
+		if err != nil || have != tt.want {
+			t.Errorf("Parse(%q) = %+v, %v, want %+v", tt.in, have, err, tt.want)
+		}
+		if s := have.String(); s != tt.in {
+			t.Errorf("String() = %q, want %q", s, tt.in)
+		}
+	}
+
+	for _, in := range []string{
+		"", "did:", "did:example", "did::123", "did:Example:123", "did:ex-ample:123",
+		"DID:example:123", "did:example:", "did:example:123:", "did:example:1 2",
+		"did:example:%zz", "did:example:%4", "did:example:123#key", "did:example:123/path",
+	} {
+		if _, err := Parse(in); !errors.Is(err, ErrInvalidDID) {
+			t.Errorf("Parse(%q): expected ErrInvalidDID, got %v", in, err)
+		}
+	}
+}
+
+func TestParseURL(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want URL
+	}{
+		{"did:example:123", URL{DID: DID{"example", "123"}}},
+		{"did:example:123#keys-1", URL{DID: DID{"example", "123"}, Fragment: "keys-1"}},
+		{"did:example:123/path/to%20doc?versionId=1&hl=x#frag", URL{DID: DID{"example", "123"}, Path: "/path/to%20doc", Query: "versionId=1&hl=x", Fragment: "frag"}},
+		{"did:example:123?service=files&relativeRef=/a", URL{DID: DID{"example", "123"}, Query: "service=files&relativeRef=/a"}},
+	} {
+		have, err := ParseURL(tt.in)
+		if err != nil || have != tt.want {
+			t.Errorf("ParseURL(%q) = %+v, %v, want %+v", tt.in, have, err, tt.want)
+		}
+		if s := have.String(); s != tt.in {
+			t.Errorf("String() = %q, want %q", s, tt.in)
+		}
+	}
+
+	u, err := ParseURL("did:example:123?versionTime=2021-05-10T17:00:00Z&versionId=4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params, err := u.Params(); err != nil || params.Get("versionId") != "4" || params.Get("versionTime") != "2021-05-10T17:00:00Z" {
+		t.Errorf("unexpected params %v, %v", params, err)
+	}
+
+	rel, err := ParseRelativeURL("#keys-1")
+	if err != nil || !rel.IsRelative() {
+		t.Fatalf("ParseRelativeURL = %+v, %v", rel, err)
+	}
+	if s := rel.Resolve(MustParse("did:example:123")).String(); s != "did:example:123#keys-1" {
+		t.Errorf("resolved to %q", s)
+	}
+
+	for _, in := range []string{"did:example:123#a#b", "did:example:123//x", "did:example:123/a b", "did:example:123?%x", "did:ex ample:1#x"} {
+		if _, err := ParseURL(in); !errors.Is(err, ErrInvalidDID) {
+			t.Errorf("ParseURL(%q): expected ErrInvalidDID, got %v", in, err)
+		}
+	}
+	for _, in := range []string{"", "keys-1", "https://example.com#x"} {
+		if _, err := ParseRelativeURL(in); !errors.Is(err, ErrInvalidDID) {
+			t.Errorf("ParseRelativeURL(%q): expected ErrInvalidDID, got %v", in, err)
+		}
+	}
+}
+
+// document is the example document of DID Core with embedded and referenced
+// verification methods.
+const document = `{
+  "@context": ["https://www.w3.org/ns/did/v1", "https://w3id.org/security/suites/ed25519-2020/v1"],
+  "id": "did:example:123456789abcdefghi",
+  "controller": "did:example:bcehfew7h32f32h7af3",
+  "verificationMethod": [{
+    "id": "did:example:123456789abcdefghi#keys-1",
+    "type": "Ed25519VerificationKey2020",
+    "controller": "did:example:123456789abcdefghi",
+    "publicKeyMultibase": "zH3C2AVvLMv6gmMNam3uVAjZpfkcJCwDwnZn6z3wXmqPV"
+  }, {
+    "id": "#keys-2",
+    "type": "JsonWebKey2020",
+    "controller": "did:example:123456789abcdefghi",
+    "publicKeyJwk": {"crv": "secp256k1", "kty": "EC", "x": "Z4Y3NNOxv0J6tCgqOBFnHnaZhJF6LdulT7z8A-2D5_8", "y": "i5a2NtJoUKXkLm6q8nOEu9WOkso1Ag6FTUT6k_LMnGk"}
+  }],
+  "authentication": [
+    "did:example:123456789abcdefghi#keys-1",
+    {
+      "id": "did:example:123456789abcdefghi#keys-3",
+      "type": "EcdsaSecp256k1RecoveryMethod2020",
+      "controller": "did:example:123456789abcdefghi",
+      "blockchainAccountId": "eip155:1:0xb9c5714089478a327f09197987f16f9e5d936e8a"
+    }
+  ],
+  "assertionMethod": ["#keys-2", "did:example:other#keys-1"],
+  "service": [{
+    "id": "did:example:123456789abcdefghi#linked-domain",
+    "type": "LinkedDomains",
+    "serviceEndpoint": "https://bar.example.com"
+  }, {
+    "id": "#files",
+    "type": ["FileStore", "IPFS"],
+    "serviceEndpoint": {"origins": ["https://a.example.com", "ipfs://bafy"]}
+  }]
+}`
+
+func TestDocument(t *testing.T) {
+	doc, err := Unmarshal([]byte(document), MediaTypeJSONLD)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.ID != MustParse("did:example:123456789abcdefghi") || len(doc.Controller) != 1 || len(doc.Context) != 2 {
+		t.Errorf("unexpected document %+v", doc)
+	}
+	if vm, ok := doc.VerificationMethodByID("did:example:123456789abcdefghi#keys-2"); !ok || vm.Type != "JsonWebKey2020" || !strings.Contains(string(vm.PublicKeyJwk), "secp256k1") {
+		t.Errorf("unexpected verification method keys-2 %+v, %v", vm, ok)
+	}
+	if vm, ok := doc.VerificationMethodByID("#keys-3"); !ok || vm.BlockchainAccountID == "" {
+		t.Errorf("unexpected embedded verification method %+v, %v", vm, ok)
+	}
+
+	var ids []string
+	for _, vm := range doc.VerificationMethods(Authentication) {
+		ids = append(ids, vm.ID)
+	}
+	if strings.Join(ids, " ") != "did:example:123456789abcdefghi#keys-1 did:example:123456789abcdefghi#keys-3" {
+		t.Errorf("unexpected authentication methods %v", ids)
+	}
+	if methods := doc.VerificationMethods(AssertionMethod); len(methods) != 1 || methods[0].ID != "#keys-2" {
+		t.Errorf("unexpected assertion methods %+v", methods)
+	}
+	if s := doc.Service[1]; len(s.Type) != 2 || s.ServiceEndpoint.(map[string]any)["origins"] == nil {
+		t.Errorf("unexpected service %+v", s)
+	}
+
+	// Both representations round trip.
+	for _, mediaType := range []string{MediaTypeJSON, MediaTypeJSONLD} {
+		data, err := doc.Marshal(mediaType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hasContext := strings.Contains(string(data), "@context"); hasContext != (mediaType == MediaTypeJSONLD) {
+			t.Errorf("%s: unexpected context in %s", mediaType, data)
+		}
+		again, err := Unmarshal(data, mediaType)
+		if err != nil {
+			t.Fatalf("%s: %v", mediaType, err)
+		}
+		if mediaType == MediaTypeJSON {
+			again.Context = doc.Context
+		}
+		want, _ := json.Marshal(doc)
+		have, _ := json.Marshal(again)
+		if string(have) != string(want) {
+			t.Errorf("%s: round trip changed the document:\n have %s\n want %s", mediaType, have, want)
+		}
+	}
+
+	// The JSON-LD representation gets the DID context when missing.
+	plain := Document{ID: MustParse("did:example:1")}
+	data, err := plain.Marshal(MediaTypeJSONLD)
+	if err != nil || string(data) != `{"@context":"https://www.w3.org/ns/did/v1","id":"did:example:1"}` {
+		t.Errorf("unexpected JSON-LD %s, %v", data, err)
+	}
+	if _, err := Unmarshal([]byte(`{"id":"did:example:1"}`), MediaTypeJSONLD); !errors.Is(err, ErrInvalidDocument) {
+		t.Errorf("expected ErrInvalidDocument without context, got %v", err)
+	}
+	if _, err := plain.Marshal("application/xml"); !errors.Is(err, ErrRepresentationNotSupported) {
+		t.Errorf("expected ErrRepresentationNotSupported, got %v", err)
+	}
+}
+
+func TestDocumentValidate(t *testing.T) {
+	vm := func(id string) VerificationMethod {
+		return VerificationMethod{ID: id, Type: "JsonWebKey2020", Controller: MustParse("did:example:1")}
+	}
+	for _, tt := range []struct {
+		name string
+		doc  Document
+		msg  string
+	}{
+		{"missing id", Document{}, "missing id"},
+		{"controller", Document{ID: MustParse("did:example:1"), Controller: Set{"example"}}, "invalid controller"},
+		{"alsoKnownAs", Document{ID: MustParse("did:example:1"), AlsoKnownAs: []string{"nope"}}, "not a URI"},
+		{"method id", Document{ID: MustParse("did:example:1"), VerificationMethod: []VerificationMethod{vm("key-1")}}, "invalid verification method id"},
+		{"duplicate", Document{ID: MustParse("did:example:1"), VerificationMethod: []VerificationMethod{vm("#key-1"), vm("did:example:1#key-1")}}, "duplicate id"},
+		{"duplicate embedded", Document{ID: MustParse("did:example:1"), VerificationMethod: []VerificationMethod{vm("#key-1")},
+			KeyAgreement: []VerificationRelationship{{Embedded: &VerificationMethod{ID: "#key-1", Type: "X25519KeyAgreementKey2020", Controller: MustParse("did:example:1")}}}}, "duplicate id"},
+		{"method type", Document{ID: MustParse("did:example:1"), VerificationMethod: []VerificationMethod{{ID: "#key-1", Controller: MustParse("did:example:1")}}}, "has no type"},
+		{"reference", Document{ID: MustParse("did:example:1"), CapabilityInvocation: []VerificationRelationship{{Reference: "key-1"}}}, "invalid capabilityInvocation reference"},
+		{"service id", Document{ID: MustParse("did:example:1"), Service: []Service{{ID: "files", Type: Set{"X"}, ServiceEndpoint: "https://a"}}}, "not a URI"},
+		{"service duplicate", Document{ID: MustParse("did:example:1"), VerificationMethod: []VerificationMethod{vm("#x")}, Service: []Service{{ID: "#x", Type: Set{"X"}, ServiceEndpoint: "https://a"}}}, "duplicate id"},
+		{"service endpoint", Document{ID: MustParse("did:example:1"), Service: []Service{{ID: "https://a/s", Type: Set{"X"}}}}, "has no endpoint"},
+	} {
+		err := tt.doc.Validate()
+		if !errors.Is(err, ErrInvalidDocument) || !strings.Contains(err.Error(), tt.msg) {
+			t.Errorf("%s: expected invalid document with %q, got %v", tt.name, tt.msg, err)
+		}
+	}
+}
+
+func TestMethods(t *testing.T) {
+	ctx := context.Background()
+	example := ResolverFunc(func(ctx context.Context, did string, opts ResolutionOptions) (*Resolution, error) {
+		switch did {
+		case "did:example:1":
+			return &Resolution{Document: &Document{ID: MustParse(did)}}, nil
+		case "did:example:old":
+			res := Resolution{Document: &Document{ID: MustParse(did)}, DocumentMetadata: DocumentMetadata{Deactivated: true}}
+			return &res, NewError(ErrDeactivated, did, nil)
+		}
+		return nil, NewError(ErrNotFound, did, errors.New("no such record"))
+	})
+	r := Methods{"example": example}
+
+	if res, err := r.Resolve(ctx, "did:example:1", ResolutionOptions{}); err != nil || res.Document.ID.ID != "1" {
+		t.Errorf("unexpected resolution %+v, %v", res, err)
+	}
+	res, err := r.Resolve(ctx, "did:example:old", ResolutionOptions{})
+	if !errors.Is(err, ErrDeactivated) || res == nil || !res.DocumentMetadata.Deactivated {
+		t.Errorf("expected deactivated resolution, got %+v, %v", res, err)
+	}
+
+	for _, tt := range []struct {
+		did  string
+		want *Error
+		code string
+	}{
+		{"did:example:2", ErrNotFound, "notFound"},
+		{"did:other:1", ErrMethodNotSupported, "methodNotSupported"},
+		{"did:example", ErrInvalidDID, "invalidDid"},
+	} {
+		_, err := r.Resolve(ctx, tt.did, ResolutionOptions{})
+		if !errors.Is(err, tt.want) || errors.Is(err, ErrDeactivated) || ErrorCode(err) != tt.code {
+			t.Errorf("%s: expected %s, got %v", tt.did, tt.code, err)
+		}
+		data, _ := json.Marshal(Failure(err))
+		if want := `{"didDocument":null,"didResolutionMetadata":{"error":"` + tt.code + `"},"didDocumentMetadata":{}}`; string(data) != want {
+			t.Errorf("unexpected failure %s", data)
+		}
+	}
+	if _, err := r.Resolve(ctx, "did:example:2", ResolutionOptions{}); err.Error() != "notFound did:example:2: no such record" {
+		t.Errorf("unexpected message %q", err)
+	}
+	if ErrorCode(errors.New("boom")) != "internalError" {
+		t.Error("expected internalError for other errors")
+	}
+}
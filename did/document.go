@@ -0,0 +1,340 @@
+package did
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// Media types of the DID document representations.
+const (
+	MediaTypeJSON   = "application/did+json"
+	MediaTypeJSONLD = "application/did+ld+json"
+)
+
+// ContextV1 is the JSON-LD context of DID Core 1.0, the first context of every
+// JSON-LD document.
+const ContextV1 = "https://www.w3.org/ns/did/v1"
+
+// Document is a DID document.
+type Document struct {
+	Context              Context                    `json:"@context,omitempty"`
+	ID                   DID                        `json:"id"`
+	AlsoKnownAs          []string                   `json:"alsoKnownAs,omitempty"`
+	Controller           Set                        `json:"controller,omitempty"`
+	VerificationMethod   []VerificationMethod       `json:"verificationMethod,omitempty"`
+	Authentication       []VerificationRelationship `json:"authentication,omitempty"`
+	AssertionMethod      []VerificationRelationship `json:"assertionMethod,omitempty"`
+	KeyAgreement         []VerificationRelationship `json:"keyAgreement,omitempty"`
+	CapabilityInvocation []VerificationRelationship `json:"capabilityInvocation,omitempty"`
+	CapabilityDelegation []VerificationRelationship `json:"capabilityDelegation,omitempty"`
+	Service              []Service                  `json:"service,omitempty"`
+}
+
+// VerificationMethod is a public key, or another way to verify proofs, of a
+// DID subject.
+type VerificationMethod struct {
+	ID                  string          `json:"id"` // DID URL, may be relative
+	Type                string          `json:"type"`
+	Controller          DID             `json:"controller"`
+	PublicKeyJwk        json.RawMessage `json:"publicKeyJwk,omitempty"`
+	PublicKeyMultibase  string          `json:"publicKeyMultibase,omitempty"`
+	BlockchainAccountID string          `json:"blockchainAccountId,omitempty"` // CAIP-10 account
+}
+
+// VerificationRelationship is a verification method used for a purpose such
+// as authentication, either embedded or referenced by its DID URL.
+type VerificationRelationship struct {
+	Reference string              // DID URL of a verification method
+	Embedded  *VerificationMethod // Verification method only usable for this purpose
+}
+
+// MarshalJSON implements json.Marshaler.
+func (r VerificationRelationship) MarshalJSON() ([]byte, error) {
+	if r.Embedded != nil {
+		return json.Marshal(r.Embedded)
+	}
+	return json.Marshal(r.Reference)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (r *VerificationRelationship) UnmarshalJSON(data []byte) error {
+	*r = VerificationRelationship{}
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		r.Embedded = new(VerificationMethod)
+		return json.Unmarshal(data, r.Embedded)
+	}
+	return json.Unmarshal(data, &r.Reference)
+}
+
+// ID returns the DID URL of the verification method.
+func (r VerificationRelationship) ID() string {
+	if r.Embedded != nil {
+		return r.Embedded.ID
+	}
+	return r.Reference
+}
+
+// Service is a way to communicate with the DID subject, such as the location
+// of its data.
+type Service struct {
+	ID              string `json:"id"` // URI, may be a relative DID URL
+	Type            Set    `json:"type"`
+	ServiceEndpoint any    `json:"serviceEndpoint"` // URI string, map or set of them
+}
+
+// Set is a set of strings, represented as a single string when it has one
+// element.
+type Set []string
+
+// MarshalJSON implements json.Marshaler.
+func (s Set) MarshalJSON() ([]byte, error) {
+	if len(s) == 1 {
+		return json.Marshal(s[0])
+	}
+	return json.Marshal([]string(s))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (s *Set) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*s = Set{one}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(s))
+}
+
+// Context is the JSON-LD context of a document: URLs or embedded contexts.
+type Context []any
+
+// MarshalJSON implements json.Marshaler.
+func (c Context) MarshalJSON() ([]byte, error) {
+	if len(c) == 1 {
+		if s, ok := c[0].(string); ok {
+			return json.Marshal(s)
+		}
+	}
+	return json.Marshal([]any(c))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (c *Context) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*c = Context{one}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]any)(c))
+}
+
+// Relationship names a verification relationship of a document.
+type Relationship string
+
+// Set of verification relationships.
+const (
+	Authentication       Relationship = "authentication"
+	AssertionMethod      Relationship = "assertionMethod"
+	KeyAgreement         Relationship = "keyAgreement"
+	CapabilityInvocation Relationship = "capabilityInvocation"
+	CapabilityDelegation Relationship = "capabilityDelegation"
+)
+
+// relationships returns the verification relationships of a document, in
+// the order of the document.
+func (d *Document) relationships() []struct {
+	name Relationship
+	rels []VerificationRelationship
+} {
+	return []struct {
+		name Relationship
+		rels []VerificationRelationship
+	}{
+		{Authentication, d.Authentication},
+		{AssertionMethod, d.AssertionMethod},
+		{KeyAgreement, d.KeyAgreement},
+		{CapabilityInvocation, d.CapabilityInvocation},
+		{CapabilityDelegation, d.CapabilityDelegation},
+	}
+}
+
+// VerificationMethodByID returns the verification method of the document
+// with a DID URL, relative or not. Methods embedded in relationships are
+// included.
+func (d *Document) VerificationMethodByID(id string) (VerificationMethod, bool) {
+	target, err := ParseRelativeURL(id)
+	if err != nil {
+		return VerificationMethod{}, false
+	}
+	target = target.Resolve(d.ID)
+
+	matches := func(vm *VerificationMethod) bool {
+		u, err := ParseRelativeURL(vm.ID)
+		return err == nil && u.Resolve(d.ID) == target
+	}
+	for i := range d.VerificationMethod {
+		if matches(&d.VerificationMethod[i]) {
+			return d.VerificationMethod[i], true
+		}
+	}
+	for _, rel := range d.relationships() {
+		for _, r := range rel.rels {
+			if r.Embedded != nil && matches(r.Embedded) {
+				return *r.Embedded, true
+			}
+		}
+	}
+	return VerificationMethod{}, false
+}
+
+// VerificationMethods returns the verification methods of a relationship.
+// References to methods of other documents are skipped.
+func (d *Document) VerificationMethods(rel Relationship) []VerificationMethod {
+	var rels []VerificationRelationship
+	for _, r := range d.relationships() {
+		if r.name == rel {
+			rels = r.rels
+		}
+	}
+
+	var methods []VerificationMethod
+	for _, r := range rels {
+		if r.Embedded != nil {
+			methods = append(methods, *r.Embedded)
+			continue
+		}
+		if vm, ok := d.VerificationMethodByID(r.Reference); ok {
+			methods = append(methods, vm)
+		}
+	}
+	return methods
+}
+
+// Validate checks the document conforms to the data model: identifiers are
+// valid and unique, and required properties are set.
+func (d *Document) Validate() error {
+	if d.ID.IsZero() {
+		return d.invalid("missing id")
+	}
+	for _, c := range d.Controller {
+		if _, err := Parse(c); err != nil {
+			return d.invalid(fmt.Sprintf("invalid controller %q", c))
+		}
+	}
+	for _, aka := range d.AlsoKnownAs {
+		if u, err := url.Parse(aka); err != nil || u.Scheme == "" {
+			return d.invalid(fmt.Sprintf("alsoKnownAs %q is not a URI", aka))
+		}
+	}
+
+	// Ids are compared in their absolute form.
+	ids := make(map[string]bool)
+	unique := func(id string) error {
+		key := id
+		if u, err := ParseRelativeURL(id); err == nil {
+			key = u.Resolve(d.ID).String()
+		}
+		if ids[key] {
+			return d.invalid(fmt.Sprintf("duplicate id %q", id))
+		}
+		ids[key] = true
+		return nil
+	}
+	checkMethod := func(vm *VerificationMethod) error {
+		if _, err := ParseRelativeURL(vm.ID); err != nil {
+			return d.invalid(fmt.Sprintf("invalid verification method id %q", vm.ID))
+		}
+		if err := unique(vm.ID); err != nil {
+			return err
+		}
+		if vm.Type == "" {
+			return d.invalid(fmt.Sprintf("verification method %q has no type", vm.ID))
+		}
+		if vm.Controller.IsZero() {
+			return d.invalid(fmt.Sprintf("verification method %q has no controller", vm.ID))
+		}
+		return nil
+	}
+
+	for i := range d.VerificationMethod {
+		if err := checkMethod(&d.VerificationMethod[i]); err != nil {
+			return err
+		}
+	}
+	for _, rel := range d.relationships() {
+		for _, r := range rel.rels {
+			if r.Embedded != nil {
+				if err := checkMethod(r.Embedded); err != nil {
+					return err
+				}
+				continue
+			}
+			if _, err := ParseRelativeURL(r.Reference); err != nil {
+				return d.invalid(fmt.Sprintf("invalid %s reference %q", rel.name, r.Reference))
+			}
+		}
+	}
+
+	for _, s := range d.Service {
+		if _, err := ParseRelativeURL(s.ID); err != nil {
+			if u, err := url.Parse(s.ID); err != nil || u.Scheme == "" {
+				return d.invalid(fmt.Sprintf("service id %q is not a URI", s.ID))
+			}
+		}
+		if err := unique(s.ID); err != nil {
+			return err
+		}
+		if len(s.Type) == 0 {
+			return d.invalid(fmt.Sprintf("service %q has no type", s.ID))
+		}
+		if s.ServiceEndpoint == nil {
+			return d.invalid(fmt.Sprintf("service %q has no endpoint", s.ID))
+		}
+	}
+
+	return nil
+}
+
+func (d *Document) invalid(msg string) *Error {
+	return &Error{Code: ErrInvalidDocument.Code, DID: d.ID.String(), Message: msg}
+}
+
+// Marshal returns a representation of the document, MediaTypeJSON or
+// MediaTypeJSONLD. The JSON representation has no context; the JSON-LD one
+// starts with ContextV1, which is added if needed.
+func (d *Document) Marshal(mediaType string) ([]byte, error) {
+	doc := *d
+	switch mediaType {
+	case MediaTypeJSON:
+		doc.Context = nil
+	case MediaTypeJSONLD:
+		if len(doc.Context) == 0 || doc.Context[0] != ContextV1 {
+			doc.Context = append(Context{ContextV1}, doc.Context...)
+		}
+	default:
+		return nil, &Error{Code: ErrRepresentationNotSupported.Code, DID: d.ID.String(), Message: mediaType}
+	}
+	return json.Marshal(doc)
+}
+
+// Unmarshal parses and validates the representation of a document. The
+// JSON-LD representation must start with ContextV1.
+func Unmarshal(data []byte, mediaType string) (*Document, error) {
+	if mediaType != MediaTypeJSON && mediaType != MediaTypeJSONLD {
+		return nil, &Error{Code: ErrRepresentationNotSupported.Code, Message: mediaType}
+	}
+
+	var d Document
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, NewError(ErrInvalidDocument, "", err)
+	}
+	if mediaType == MediaTypeJSONLD && (len(d.Context) == 0 || d.Context[0] != ContextV1) {
+		return nil, d.invalid("JSON-LD context doesn't start with " + ContextV1)
+	}
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
@@ -0,0 +1,151 @@
+package did
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Error is a DID resolution error, identified by its code in the resolution
+// metadata. Errors match the sentinel of their code with errors.Is.
+type Error struct {
+	Code    string // Error code of the resolution metadata, such as notFound
+	DID     string // DID being resolved, if any
+	Message string // Details, if any
+	Err     error  // Underlying error, if any
+}
+
+// Set of resolution errors, to be matched with errors.Is.
+var (
+	ErrInvalidDID                 = &Error{Code: "invalidDid"}
+	ErrNotFound                   = &Error{Code: "notFound"}
+	ErrDeactivated                = &Error{Code: "deactivated"}
+	ErrMethodNotSupported         = &Error{Code: "methodNotSupported"}
+	ErrRepresentationNotSupported = &Error{Code: "representationNotSupported"}
+	ErrInvalidDocument            = &Error{Code: "invalidDidDocument"}
+)
+
+func (e *Error) Error() string {
+	msg := e.Code
+	if e.DID != "" {
+		msg += " " + e.DID
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is an Error with the same code.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// NewError returns an Error with the code of sentinel, one of the errors
+// above, for a DID and caused by err, which may be nil.
+func NewError(sentinel *Error, did string, err error) *Error {
+	return &Error{Code: sentinel.Code, DID: did, Err: err}
+}
+
+// ErrorCode returns the code of the resolution error in err's tree, or
+// internalError.
+func ErrorCode(err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return "internalError"
+}
+
+func invalid(did, msg string) *Error {
+	return &Error{Code: ErrInvalidDID.Code, DID: did, Message: msg}
+}
+
+// ResolutionOptions are the options of a resolution.
+type ResolutionOptions struct {
+	// Accept is the media type of the representation asked for, MediaTypeJSON
+	// or MediaTypeJSONLD. Empty means the resolver's choice.
+	Accept string
+
+	// VersionID selects a version of the document, as the versionId DID
+	// parameter does. Its format is method specific.
+	VersionID string
+
+	// VersionTime selects the version of the document that was current at a
+	// time, as the versionTime DID parameter does. Zero means the latest.
+	VersionTime time.Time
+}
+
+// ResolutionMetadata is the metadata of the resolution process.
+type ResolutionMetadata struct {
+	ContentType string `json:"contentType,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// DocumentMetadata is the metadata of a resolved DID document.
+type DocumentMetadata struct {
+	Created       *time.Time `json:"created,omitempty"`
+	Updated       *time.Time `json:"updated,omitempty"`
+	Deactivated   bool       `json:"deactivated,omitempty"`
+	NextUpdate    *time.Time `json:"nextUpdate,omitempty"`
+	VersionID     string     `json:"versionId,omitempty"`
+	NextVersionID string     `json:"nextVersionId,omitempty"`
+	EquivalentID  []string   `json:"equivalentId,omitempty"`
+	CanonicalID   string     `json:"canonicalId,omitempty"`
+}
+
+// Resolution is the result of resolving a DID.
+type Resolution struct {
+	Document           *Document          `json:"didDocument"`
+	ResolutionMetadata ResolutionMetadata `json:"didResolutionMetadata"`
+	DocumentMetadata   DocumentMetadata   `json:"didDocumentMetadata"`
+}
+
+// Resolver resolves DIDs to their documents.
+//
+// A resolver returns an error matching ErrInvalidDID, ErrNotFound or
+// ErrMethodNotSupported when no document exists. A deactivated DID is
+// reported with ErrDeactivated along with the resolution, whose metadata is
+// marked deactivated and which may carry the last document.
+type Resolver interface {
+	Resolve(ctx context.Context, did string, opts ResolutionOptions) (*Resolution, error)
+}
+
+// ResolverFunc adapts a function to a Resolver.
+type ResolverFunc func(ctx context.Context, did string, opts ResolutionOptions) (*Resolution, error)
+
+// Resolve calls f.
+func (f ResolverFunc) Resolve(ctx context.Context, did string, opts ResolutionOptions) (*Resolution, error) {
+	return f(ctx, did, opts)
+}
+
+// Methods is a Resolver delegating to the resolver of each DID method.
+type Methods map[string]Resolver
+
+// Resolve resolves a DID with the resolver of its method.
+func (m Methods) Resolve(ctx context.Context, did string, opts ResolutionOptions) (*Resolution, error) {
+	d, err := Parse(did)
+	if err != nil {
+		return nil, err
+	}
+	r, ok := m[d.Method]
+	if !ok {
+		return nil, NewError(ErrMethodNotSupported, did, nil)
+	}
+	return r.Resolve(ctx, did, opts)
+}
+
+// Failure returns the resolution reporting err, for the representations of
+// a failed resolution such as HTTP responses.
+func Failure(err error) *Resolution {
+	return &Resolution{ResolutionMetadata: ResolutionMetadata{Error: ErrorCode(err)}}
+}
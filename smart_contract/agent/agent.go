@@ -0,0 +1,104 @@
+// Package agent implements the delegated authorization of the Agentable
+// contract. A DID holder signs a message authorizing an agent to create,
+// update or delete a DID in a registry, and the agent relays the operation
+// with the signature as proof. The registry recovers the holder from the
+// proof and acts on their behalf.
+//
+// Messages are byte-identical to the ones of getCreateAuthMessage,
+// getUpdateAuthMessage and getDeleteAuthMessage, and are signed with the
+// "\x19Ethereum Signed Message:\n" prefix of personal_sign.
+package agent
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ErrInvalidProof is returned when no signer can be recovered from a proof.
+var ErrInvalidProof = errors.New("invalid proof")
+
+// CreateMessage returns the message authorizing agent to create a DID in
+// registry, with the hash and URI of its document.
+func CreateMessage(registry, agent common.Address, did string, hash common.Hash, uri string) []byte {
+	return concat("I authorize ", address(agent), " to create DID ", did,
+		" in contract with ", address(registry), " (", string(hash[:]), ", ", uri, ")")
+}
+
+// UpdateMessage returns the message authorizing agent to update a DID in
+// registry, with the hash and URI of its document after the update.
+func UpdateMessage(registry, agent common.Address, did string, hash common.Hash, uri string) []byte {
+	return concat("I authorize ", address(agent), " to update DID ", did,
+		" in contract to ", address(registry), " (", string(hash[:]), ", ", uri, ")")
+}
+
+// DeleteMessage returns the message authorizing agent to delete a DID in
+// registry.
+func DeleteMessage(registry, agent common.Address, did string) []byte {
+	return concat("I authorize ", address(agent), " to delete DID ", did,
+		" in contract ", address(registry))
+}
+
+// Sign signs a message with the key of the holder, such as one of a
+// keystore, and returns the proof to send with the operation.
+func Sign(key *ecdsa.PrivateKey, message []byte) ([]byte, error) {
+	sig, err := crypto.Sign(signHash(message), key)
+	if err != nil {
+		return nil, err
+	}
+	sig[crypto.RecoveryIDOffset] += 27
+	return sig, nil
+}
+
+// Recover returns the address that signed a message, as the contract does.
+// Proofs are 65 bytes long, with a recovery id of 0, 1, 27 or 28.
+func Recover(message, proof []byte) (common.Address, error) {
+	if len(proof) != crypto.SignatureLength {
+		return common.Address{}, ErrInvalidProof
+	}
+	sig := make([]byte, crypto.SignatureLength)
+	copy(sig, proof)
+	if v := sig[crypto.RecoveryIDOffset]; v >= 27 {
+		sig[crypto.RecoveryIDOffset] = v - 27
+	}
+	if sig[crypto.RecoveryIDOffset] > 1 {
+		return common.Address{}, ErrInvalidProof
+	}
+	pub, err := crypto.SigToPub(signHash(message), sig)
+	if err != nil {
+		return common.Address{}, ErrInvalidProof
+	}
+	return crypto.PubkeyToAddress(*pub), nil
+}
+
+// signHash is the hash of a message with the prefix of signed messages.
+func signHash(message []byte) []byte {
+	return crypto.Keccak256([]byte("\x19Ethereum Signed Message:\n"), []byte(strconv.Itoa(len(message))), message)
+}
+
+// address returns the lower case hex form of an address, as addrToString
+// does. The checksummed form of Address.Hex would change the message.
+func address(a common.Address) string {
+	const alphabet = "0123456789abcdef"
+	s := make([]byte, 2, 42)
+	copy(s, "0x")
+	for _, b := range a {
+		s = append(s, alphabet[b>>4], alphabet[b&0x0f])
+	}
+	return string(s)
+}
+
+func concat(parts ...string) []byte {
+	n := 0
+	for _, p := range parts {
+		n += len(p)
+	}
+	b := make([]byte, 0, n)
+	for _, p := range parts {
+		b = append(b, p...)
+	}
+	return b
+}
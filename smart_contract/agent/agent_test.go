@@ -0,0 +1,242 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	keystore "EncrypteDL/EncryrpteID/_observability/keyStore"
+	"EncrypteDL/EncryrpteID/smart_contract/bindings"
+	"EncrypteDL/EncryrpteID/smart_contract/internal/registrytest"
+	"EncrypteDL/EncryrpteID/smart_contract/registry"
+)
+
+// checkMessages compares the messages built in Go with the ones of the
+// contract at address.
+func checkMessages(t *testing.T, chain *registrytest.Chain, address common.Address) {
+	t.Helper()
+	caller, err := bindings.NewAgentableCaller(address, chain.Backend.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent := chain.Accounts[0].Address
+
+	for _, tt := range []struct {
+		did  string
+		hash common.Hash
+		uri  string
+	}{
+		{"d", common.Hash{}, ""},
+		{"6b1d3f0e-3d5c-4a43-9e0c-2f1d8e6a7b90", crypto.Keccak256Hash([]byte("doc")), "ipfs://bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi"},
+		{strings.Repeat("x", 33), common.HexToHash("0xff00"), "https://example.com/" + strings.Repeat("y", 100)},
+		{"did:example:ünïcode", common.Hash{0x20, 0x29}, "a, b)"},
+	} {
+		want, err := caller.GetCreateAuthMessage(nil, []byte(tt.did), tt.hash, []byte(tt.uri), agent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have := CreateMessage(address, agent, tt.did, tt.hash, tt.uri); !bytes.Equal(have, want) {
+			t.Errorf("create message of %q:\n have %q\n want %q", tt.did, have, want)
+		}
+		want, err = caller.GetUpdateAuthMessage(nil, []byte(tt.did), tt.hash, []byte(tt.uri), agent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have := UpdateMessage(address, agent, tt.did, tt.hash, tt.uri); !bytes.Equal(have, want) {
+			t.Errorf("update message of %q:\n have %q\n want %q", tt.did, have, want)
+		}
+		want, err = caller.GetDeleteAuthMessage(nil, []byte(tt.did), agent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have := DeleteMessage(address, agent, tt.did); !bytes.Equal(have, want) {
+			t.Errorf("delete message of %q:\n have %q\n want %q", tt.did, have, want)
+		}
+	}
+}
+
+func TestMessages(t *testing.T) {
+	chain := registrytest.NewChain(t, 1)
+	checkMessages(t, chain, chain.Registry)
+}
+
+// TestMessagesCompiled checks the messages against Agentable.sol deployed on
+// its own rather than inherited by the registry.
+func TestMessagesCompiled(t *testing.T) {
+	chain := registrytest.NewChain(t, 1)
+	checkMessages(t, chain, chain.DeployAgentable(t))
+}
+
+func TestSignRecover(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	holder := crypto.PubkeyToAddress(key.PublicKey)
+	message := DeleteMessage(common.Address{1}, common.Address{2}, "device")
+
+	proof, err := Sign(key, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := proof[64]; v != 27 && v != 28 {
+		t.Errorf("unexpected recovery id %d", v)
+	}
+	if signer, err := Recover(message, proof); err != nil || signer != holder {
+		t.Errorf("recovered %s, %v, want %s", signer, err, holder)
+	}
+
+	// Recovery ids of 0 and 1 are accepted too.
+	raw := bytes.Clone(proof)
+	raw[64] -= 27
+	if signer, err := Recover(message, raw); err != nil || signer != holder {
+		t.Errorf("recovered %s, %v with raw recovery id, want %s", signer, err, holder)
+	}
+	if signer, err := Recover(append(message, '.'), proof); err != nil || signer == holder {
+		t.Errorf("recovered %s, %v from another message", signer, err)
+	}
+
+	badV := bytes.Clone(proof)
+	badV[64] = 29
+	for name, proof := range map[string][]byte{"short": proof[:64], "empty": nil, "recovery id": badV} {
+		if _, err := Recover(message, proof); !errors.Is(err, ErrInvalidProof) {
+			t.Errorf("%s: expected ErrInvalidProof, got %v", name, err)
+		}
+	}
+}
+
+// holderKey loads a holder key from a keystore, as written by
+// openssl ecparam -name secp256k1 -genkey.
+func holderKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	params, err := asn1.Marshal(asn1.ObjectIdentifier{1, 3, 132, 0, 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := asn1.Marshal(struct {
+		Version       int
+		PrivateKey    []byte
+		NamedCurveOID asn1.ObjectIdentifier `asn1:"optional,explicit,tag:0"`
+		PublicKey     asn1.BitString        `asn1:"optional,explicit,tag:1"`
+	}{1, crypto.FromECDSA(key), asn1.ObjectIdentifier{1, 3, 132, 0, 10}, asn1.BitString{Bytes: crypto.FromECDSAPub(&key.PublicKey), BitLength: 520}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := append(pem.EncodeToMemory(&pem.Block{Type: "EC PARAMETERS", Bytes: params}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})...)
+
+	ks := keystore.New()
+	if err := ks.LoadSecp256k1Keys(fstest.MapFS{"keys/holder.pem": {Data: file}}); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := ks.Secp256k1Key("holder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Equal(key) {
+		t.Fatal("loaded key differs from the generated one")
+	}
+	return loaded
+}
+
+func TestRelay(t *testing.T) {
+	ctx := context.Background()
+	chain := registrytest.NewChain(t, 2)
+	holderKey := holderKey(t)
+	holder := crypto.PubkeyToAddress(holderKey.PublicKey)
+
+	c := chain.NewClient(t, 0)
+	agent := c.From()
+	sign := func(message []byte) []byte {
+		t.Helper()
+		proof, err := Sign(holderKey, message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return proof
+	}
+	mine := chain.Mine(t, c)
+
+	// Message lengths cross a power of ten as the DID grows, which changes
+	// the number of digits in the signed prefix.
+	for _, did := range []string{"device", strings.Repeat("d", 40), strings.Repeat("d", 420)} {
+		hash, uri := crypto.Keccak256Hash([]byte(did)), "ipfs://"+did
+		created := mine(c.Create(ctx, did, sign(CreateMessage(chain.Registry, agent, did, hash, uri)), hash, uri))
+
+		want := registry.Record{Owner: holder, Hash: hash, URI: uri}
+		if have, err := c.Record(ctx, did, nil); err != nil || have != want {
+			t.Fatalf("unexpected record of %d bytes DID:\n have %+v, %v\n want %+v", len(did), have, err, want)
+		}
+
+		events, err := c.Filterer().FilterDIDCreated(&bind.FilterOpts{Start: created.BlockNumber.Uint64(), Context: ctx}, []common.Address{agent})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !events.Next() || events.Event.Did != did {
+			t.Errorf("missing DIDCreated event operated by the agent for %q", did)
+		}
+		events.Close()
+	}
+
+	// Updates are authorized with the resulting hash and URI.
+	hash2, uri2 := common.Hash{2}, "https://example.com/device.json"
+	mine(c.UpdateHash(ctx, "device", sign(UpdateMessage(chain.Registry, agent, "device", hash2, "ipfs://device")), hash2))
+	mine(c.UpdateURI(ctx, "device", sign(UpdateMessage(chain.Registry, agent, "device", hash2, uri2)), uri2))
+	want := registry.Record{Owner: holder, Hash: hash2, URI: uri2}
+	if have, err := c.Record(ctx, "device", nil); err != nil || have != want {
+		t.Fatalf("unexpected record after updates:\n have %+v, %v\n want %+v", have, err, want)
+	}
+
+	// The agent can't act for itself, nor with proofs of other messages or
+	// signers.
+	other, err := Sign(chain.Accounts[1].Key, DeleteMessage(chain.Registry, agent, "device"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name   string
+		send   func() (*types.Transaction, error)
+		reason string
+	}{
+		{"no proof", func() (*types.Transaction, error) {
+			return c.Delete(ctx, "device", nil)
+		}, registrytest.ReasonUnauthorized},
+		{"other signer", func() (*types.Transaction, error) {
+			return c.Delete(ctx, "device", other)
+		}, registrytest.ReasonUnauthorized},
+		{"other update", func() (*types.Transaction, error) {
+			return c.UpdateURI(ctx, "device", sign(UpdateMessage(chain.Registry, agent, "device", hash2, "ipfs://device")), "ipfs://other")
+		}, registrytest.ReasonUnauthorized},
+		{"other agent", func() (*types.Transaction, error) {
+			return c.Delete(ctx, "device", sign(DeleteMessage(chain.Registry, chain.Accounts[1].Address, "device")))
+		}, registrytest.ReasonUnauthorized},
+		{"short proof", func() (*types.Transaction, error) {
+			return c.Delete(ctx, "device", sign(DeleteMessage(chain.Registry, agent, "device"))[:64])
+		}, registrytest.ReasonBadProof},
+	} {
+		_, err := tt.send()
+		if !errors.Is(err, registry.ErrReverted) || !strings.Contains(err.Error(), tt.reason) {
+			t.Errorf("%s: expected revert with %q, got %v", tt.name, tt.reason, err)
+		}
+	}
+
+	mine(c.Delete(ctx, "device", sign(DeleteMessage(chain.Registry, agent, "device"))))
+	if _, err := c.Record(ctx, "device", nil); !errors.Is(err, registry.ErrNotFound) {
+		t.Errorf("expected ErrNotFound after deletion, got %v", err)
+	}
+}
@@ -0,0 +1,274 @@
+// Code generated - DO NOT EDIT.
+// This file is a generated binding and any manual changes will be lost.
+
+package bindings
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var (
+	_ = errors.New
+	_ = big.NewInt
+	_ = strings.NewReader
+	_ = ethereum.NotFound
+	_ = bind.Bind
+	_ = common.Big1
+	_ = types.BloomLookup
+	_ = event.NewSubscription
+	_ = abi.ConvertType
+)
+
+// AgentableMetaData contains all meta data concerning the Agentable contract.
+var AgentableMetaData = &bind.MetaData{
+	ABI: "[{\"type\":\"function\",\"name\":\"getCreateAuthMessage\",\"stateMutability\":\"view\",\"inputs\":[{\"name\":\"did\",\"type\":\"bytes\"},{\"name\":\"h\",\"type\":\"bytes32\"},{\"name\":\"uri\",\"type\":\"bytes\"},{\"name\":\"agent\",\"type\":\"address\"}],\"outputs\":[{\"name\":\"\",\"type\":\"bytes\"}]},{\"type\":\"function\",\"name\":\"getUpdateAuthMessage\",\"stateMutability\":\"view\",\"inputs\":[{\"name\":\"did\",\"type\":\"bytes\"},{\"name\":\"h\",\"type\":\"bytes32\"},{\"name\":\"uri\",\"type\":\"bytes\"},{\"name\":\"agent\",\"type\":\"address\"}],\"outputs\":[{\"name\":\"\",\"type\":\"bytes\"}]},{\"type\":\"function\",\"name\":\"getDeleteAuthMessage\",\"stateMutability\":\"view\",\"inputs\":[{\"name\":\"did\",\"type\":\"bytes\"},{\"name\":\"agent\",\"type\":\"address\"}],\"outputs\":[{\"name\":\"\",\"type\":\"bytes\"}]}]",
+}
+
+// AgentableABI is the input ABI used to generate the binding from.
+// Deprecated: Use AgentableMetaData.ABI instead.
+var AgentableABI = AgentableMetaData.ABI
+
+// Agentable is an auto generated Go binding around an Ethereum contract.
+type Agentable struct {
+	AgentableCaller     // Read-only binding to the contract
+	AgentableTransactor // Write-only binding to the contract
+	AgentableFilterer   // Log filterer for contract events
+}
+
+// AgentableCaller is an auto generated read-only Go binding around an Ethereum contract.
+type AgentableCaller struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// AgentableTransactor is an auto generated write-only Go binding around an Ethereum contract.
+type AgentableTransactor struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// AgentableFilterer is an auto generated log filtering Go binding around an Ethereum contract events.
+type AgentableFilterer struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// AgentableSession is an auto generated Go binding around an Ethereum contract,
+// with pre-set call and transact options.
+type AgentableSession struct {
+	Contract     *Agentable        // Generic contract binding to set the session for
+	CallOpts     bind.CallOpts     // Call options to use throughout this session
+	TransactOpts bind.TransactOpts // Transaction auth options to use throughout this session
+}
+
+// AgentableCallerSession is an auto generated read-only Go binding around an Ethereum contract,
+// with pre-set call options.
+type AgentableCallerSession struct {
+	Contract *AgentableCaller // Generic contract caller binding to set the session for
+	CallOpts bind.CallOpts    // Call options to use throughout this session
+}
+
+// AgentableTransactorSession is an auto generated write-only Go binding around an Ethereum contract,
+// with pre-set transact options.
+type AgentableTransactorSession struct {
+	Contract     *AgentableTransactor // Generic contract transactor binding to set the session for
+	TransactOpts bind.TransactOpts    // Transaction auth options to use throughout this session
+}
+
+// AgentableRaw is an auto generated low-level Go binding around an Ethereum contract.
+type AgentableRaw struct {
+	Contract *Agentable // Generic contract binding to access the raw methods on
+}
+
+// AgentableCallerRaw is an auto generated low-level read-only Go binding around an Ethereum contract.
+type AgentableCallerRaw struct {
+	Contract *AgentableCaller // Generic read-only contract binding to access the raw methods on
+}
+
+// AgentableTransactorRaw is an auto generated low-level write-only Go binding around an Ethereum contract.
+type AgentableTransactorRaw struct {
+	Contract *AgentableTransactor // Generic write-only contract binding to access the raw methods on
+}
+
+// NewAgentable creates a new instance of Agentable, bound to a specific deployed contract.
+func NewAgentable(address common.Address, backend bind.ContractBackend) (*Agentable, error) {
+	contract, err := bindAgentable(address, backend, backend, backend)
+	if err != nil {
+		return nil, err
+	}
+	return &Agentable{AgentableCaller: AgentableCaller{contract: contract}, AgentableTransactor: AgentableTransactor{contract: contract}, AgentableFilterer: AgentableFilterer{contract: contract}}, nil
+}
+
+// NewAgentableCaller creates a new read-only instance of Agentable, bound to a specific deployed contract.
+func NewAgentableCaller(address common.Address, caller bind.ContractCaller) (*AgentableCaller, error) {
+	contract, err := bindAgentable(address, caller, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &AgentableCaller{contract: contract}, nil
+}
+
+// NewAgentableTransactor creates a new write-only instance of Agentable, bound to a specific deployed contract.
+func NewAgentableTransactor(address common.Address, transactor bind.ContractTransactor) (*AgentableTransactor, error) {
+	contract, err := bindAgentable(address, nil, transactor, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &AgentableTransactor{contract: contract}, nil
+}
+
+// NewAgentableFilterer creates a new log filterer instance of Agentable, bound to a specific deployed contract.
+func NewAgentableFilterer(address common.Address, filterer bind.ContractFilterer) (*AgentableFilterer, error) {
+	contract, err := bindAgentable(address, nil, nil, filterer)
+	if err != nil {
+		return nil, err
+	}
+	return &AgentableFilterer{contract: contract}, nil
+}
+
+// bindAgentable binds a generic wrapper to an already deployed contract.
+func bindAgentable(address common.Address, caller bind.ContractCaller, transactor bind.ContractTransactor, filterer bind.ContractFilterer) (*bind.BoundContract, error) {
+	parsed, err := AgentableMetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+	return bind.NewBoundContract(address, *parsed, caller, transactor, filterer), nil
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_Agentable *AgentableRaw) Call(opts *bind.CallOpts, result *[]interface{}, method string, params ...interface{}) error {
+	return _Agentable.Contract.AgentableCaller.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_Agentable *AgentableRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _Agentable.Contract.AgentableTransactor.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_Agentable *AgentableRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _Agentable.Contract.AgentableTransactor.contract.Transact(opts, method, params...)
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_Agentable *AgentableCallerRaw) Call(opts *bind.CallOpts, result *[]interface{}, method string, params ...interface{}) error {
+	return _Agentable.Contract.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_Agentable *AgentableTransactorRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _Agentable.Contract.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_Agentable *AgentableTransactorRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _Agentable.Contract.contract.Transact(opts, method, params...)
+}
+
+// GetCreateAuthMessage is a free data retrieval call binding the contract method 0xcc3b41dc.
+//
+// Solidity: function getCreateAuthMessage(bytes did, bytes32 h, bytes uri, address agent) view returns(bytes)
+func (_Agentable *AgentableCaller) GetCreateAuthMessage(opts *bind.CallOpts, did []byte, h [32]byte, uri []byte, agent common.Address) ([]byte, error) {
+	var out []interface{}
+	err := _Agentable.contract.Call(opts, &out, "getCreateAuthMessage", did, h, uri, agent)
+
+	if err != nil {
+		return *new([]byte), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new([]byte)).(*[]byte)
+
+	return out0, err
+
+}
+
+// GetCreateAuthMessage is a free data retrieval call binding the contract method 0xcc3b41dc.
+//
+// Solidity: function getCreateAuthMessage(bytes did, bytes32 h, bytes uri, address agent) view returns(bytes)
+func (_Agentable *AgentableSession) GetCreateAuthMessage(did []byte, h [32]byte, uri []byte, agent common.Address) ([]byte, error) {
+	return _Agentable.Contract.GetCreateAuthMessage(&_Agentable.CallOpts, did, h, uri, agent)
+}
+
+// GetCreateAuthMessage is a free data retrieval call binding the contract method 0xcc3b41dc.
+//
+// Solidity: function getCreateAuthMessage(bytes did, bytes32 h, bytes uri, address agent) view returns(bytes)
+func (_Agentable *AgentableCallerSession) GetCreateAuthMessage(did []byte, h [32]byte, uri []byte, agent common.Address) ([]byte, error) {
+	return _Agentable.Contract.GetCreateAuthMessage(&_Agentable.CallOpts, did, h, uri, agent)
+}
+
+// GetDeleteAuthMessage is a free data retrieval call binding the contract method 0x3bdcb26c.
+//
+// Solidity: function getDeleteAuthMessage(bytes did, address agent) view returns(bytes)
+func (_Agentable *AgentableCaller) GetDeleteAuthMessage(opts *bind.CallOpts, did []byte, agent common.Address) ([]byte, error) {
+	var out []interface{}
+	err := _Agentable.contract.Call(opts, &out, "getDeleteAuthMessage", did, agent)
+
+	if err != nil {
+		return *new([]byte), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new([]byte)).(*[]byte)
+
+	return out0, err
+
+}
+
+// GetDeleteAuthMessage is a free data retrieval call binding the contract method 0x3bdcb26c.
+//
+// Solidity: function getDeleteAuthMessage(bytes did, address agent) view returns(bytes)
+func (_Agentable *AgentableSession) GetDeleteAuthMessage(did []byte, agent common.Address) ([]byte, error) {
+	return _Agentable.Contract.GetDeleteAuthMessage(&_Agentable.CallOpts, did, agent)
+}
+
+// GetDeleteAuthMessage is a free data retrieval call binding the contract method 0x3bdcb26c.
+//
+// Solidity: function getDeleteAuthMessage(bytes did, address agent) view returns(bytes)
+func (_Agentable *AgentableCallerSession) GetDeleteAuthMessage(did []byte, agent common.Address) ([]byte, error) {
+	return _Agentable.Contract.GetDeleteAuthMessage(&_Agentable.CallOpts, did, agent)
+}
+
+// GetUpdateAuthMessage is a free data retrieval call binding the contract method 0xdd120153.
+//
+// Solidity: function getUpdateAuthMessage(bytes did, bytes32 h, bytes uri, address agent) view returns(bytes)
+func (_Agentable *AgentableCaller) GetUpdateAuthMessage(opts *bind.CallOpts, did []byte, h [32]byte, uri []byte, agent common.Address) ([]byte, error) {
+	var out []interface{}
+	err := _Agentable.contract.Call(opts, &out, "getUpdateAuthMessage", did, h, uri, agent)
+
+	if err != nil {
+		return *new([]byte), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new([]byte)).(*[]byte)
+
+	return out0, err
+
+}
+
+// GetUpdateAuthMessage is a free data retrieval call binding the contract method 0xdd120153.
+//
+// Solidity: function getUpdateAuthMessage(bytes did, bytes32 h, bytes uri, address agent) view returns(bytes)
+func (_Agentable *AgentableSession) GetUpdateAuthMessage(did []byte, h [32]byte, uri []byte, agent common.Address) ([]byte, error) {
+	return _Agentable.Contract.GetUpdateAuthMessage(&_Agentable.CallOpts, did, h, uri, agent)
+}
+
+// GetUpdateAuthMessage is a free data retrieval call binding the contract method 0xdd120153.
+//
+// Solidity: function getUpdateAuthMessage(bytes did, bytes32 h, bytes uri, address agent) view returns(bytes)
+func (_Agentable *AgentableCallerSession) GetUpdateAuthMessage(did []byte, h [32]byte, uri []byte, agent common.Address) ([]byte, error) {
+	return _Agentable.Contract.GetUpdateAuthMessage(&_Agentable.CallOpts, did, h, uri, agent)
+}
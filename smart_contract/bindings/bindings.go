@@ -0,0 +1,8 @@
+// Package bindings contains the Go bindings of the DID registry contracts,
+// generated by abigen from the ABIs in the abi directory. The ABIs mirror the
+// Solidity interfaces in smart_contract/contract.
+package bindings
+
+//go:generate go run -ldflags=-checklinkname=0 github.com/ethereum/go-ethereum/cmd/abigen --abi abi/IDID.abi --pkg bindings --type IDID --out idid.go
+//go:generate go run -ldflags=-checklinkname=0 github.com/ethereum/go-ethereum/cmd/abigen --abi abi/SelfManagedDeviceDID.abi --pkg bindings --type SelfManagedDeviceDID --out self_managed_device_did.go
+//go:generate go run -ldflags=-checklinkname=0 github.com/ethereum/go-ethereum/cmd/abigen --abi abi/Agentable.abi --pkg bindings --type Agentable --out agentable.go
@@ -0,0 +1,729 @@
+// Code generated - DO NOT EDIT.
+// This file is a generated binding and any manual changes will be lost.
+
+package bindings
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var (
+	_ = errors.New
+	_ = big.NewInt
+	_ = strings.NewReader
+	_ = ethereum.NotFound
+	_ = bind.Bind
+	_ = common.Big1
+	_ = types.BloomLookup
+	_ = event.NewSubscription
+	_ = abi.ConvertType
+)
+
+// IDIDMetaData contains all meta data concerning the IDID contract.
+var IDIDMetaData = &bind.MetaData{
+	ABI: "[{\"type\":\"event\",\"name\":\"DIDCreated\",\"anonymous\":false,\"inputs\":[{\"name\":\"operator\",\"type\":\"address\",\"indexed\":true},{\"name\":\"did\",\"type\":\"string\",\"indexed\":false},{\"name\":\"hash\",\"type\":\"bytes32\",\"indexed\":false},{\"name\":\"uri\",\"type\":\"string\",\"indexed\":false}]},{\"type\":\"event\",\"name\":\"DIDUpdated\",\"anonymous\":false,\"inputs\":[{\"name\":\"operator\",\"type\":\"address\",\"indexed\":true},{\"name\":\"did\",\"type\":\"string\",\"indexed\":true},{\"name\":\"hash\",\"type\":\"bytes32\",\"indexed\":false},{\"name\":\"uri\",\"type\":\"string\",\"indexed\":false}]},{\"type\":\"event\",\"name\":\"DIDDeleted\",\"anonymous\":false,\"inputs\":[{\"name\":\"operator\",\"type\":\"address\",\"indexed\":true},{\"name\":\"did\",\"type\":\"string\",\"indexed\":true}]},{\"type\":\"function\",\"name\":\"getHash\",\"stateMutability\":\"view\",\"inputs\":[{\"name\":\"\",\"type\":\"bytes\"}],\"outputs\":[{\"name\":\"\",\"type\":\"bytes32\"}]},{\"type\":\"function\",\"name\":\"getURI\",\"stateMutability\":\"view\",\"inputs\":[{\"name\":\"\",\"type\":\"bytes\"}],\"outputs\":[{\"name\":\"\",\"type\":\"bytes\"}]},{\"type\":\"function\",\"name\":\"getOwner\",\"stateMutability\":\"view\",\"inputs\":[{\"name\":\"\",\"type\":\"bytes\"}],\"outputs\":[{\"name\":\"\",\"type\":\"address\"}]}]",
+}
+
+// IDIDABI is the input ABI used to generate the binding from.
+// Deprecated: Use IDIDMetaData.ABI instead.
+var IDIDABI = IDIDMetaData.ABI
+
+// IDID is an auto generated Go binding around an Ethereum contract.
+type IDID struct {
+	IDIDCaller     // Read-only binding to the contract
+	IDIDTransactor // Write-only binding to the contract
+	IDIDFilterer   // Log filterer for contract events
+}
+
+// IDIDCaller is an auto generated read-only Go binding around an Ethereum contract.
+type IDIDCaller struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// IDIDTransactor is an auto generated write-only Go binding around an Ethereum contract.
+type IDIDTransactor struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// IDIDFilterer is an auto generated log filtering Go binding around an Ethereum contract events.
+type IDIDFilterer struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// IDIDSession is an auto generated Go binding around an Ethereum contract,
+// with pre-set call and transact options.
+type IDIDSession struct {
+	Contract     *IDID             // Generic contract binding to set the session for
+	CallOpts     bind.CallOpts     // Call options to use throughout this session
+	TransactOpts bind.TransactOpts // Transaction auth options to use throughout this session
+}
+
+// IDIDCallerSession is an auto generated read-only Go binding around an Ethereum contract,
+// with pre-set call options.
+type IDIDCallerSession struct {
+	Contract *IDIDCaller   // Generic contract caller binding to set the session for
+	CallOpts bind.CallOpts // Call options to use throughout this session
+}
+
+// IDIDTransactorSession is an auto generated write-only Go binding around an Ethereum contract,
+// with pre-set transact options.
+type IDIDTransactorSession struct {
+	Contract     *IDIDTransactor   // Generic contract transactor binding to set the session for
+	TransactOpts bind.TransactOpts // Transaction auth options to use throughout this session
+}
+
+// IDIDRaw is an auto generated low-level Go binding around an Ethereum contract.
+type IDIDRaw struct {
+	Contract *IDID // Generic contract binding to access the raw methods on
+}
+
+// IDIDCallerRaw is an auto generated low-level read-only Go binding around an Ethereum contract.
+type IDIDCallerRaw struct {
+	Contract *IDIDCaller // Generic read-only contract binding to access the raw methods on
+}
+
+// IDIDTransactorRaw is an auto generated low-level write-only Go binding around an Ethereum contract.
+type IDIDTransactorRaw struct {
+	Contract *IDIDTransactor // Generic write-only contract binding to access the raw methods on
+}
+
+// NewIDID creates a new instance of IDID, bound to a specific deployed contract.
+func NewIDID(address common.Address, backend bind.ContractBackend) (*IDID, error) {
+	contract, err := bindIDID(address, backend, backend, backend)
+	if err != nil {
+		return nil, err
+	}
+	return &IDID{IDIDCaller: IDIDCaller{contract: contract}, IDIDTransactor: IDIDTransactor{contract: contract}, IDIDFilterer: IDIDFilterer{contract: contract}}, nil
+}
+
+// NewIDIDCaller creates a new read-only instance of IDID, bound to a specific deployed contract.
+func NewIDIDCaller(address common.Address, caller bind.ContractCaller) (*IDIDCaller, error) {
+	contract, err := bindIDID(address, caller, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &IDIDCaller{contract: contract}, nil
+}
+
+// NewIDIDTransactor creates a new write-only instance of IDID, bound to a specific deployed contract.
+func NewIDIDTransactor(address common.Address, transactor bind.ContractTransactor) (*IDIDTransactor, error) {
+	contract, err := bindIDID(address, nil, transactor, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &IDIDTransactor{contract: contract}, nil
+}
+
+// NewIDIDFilterer creates a new log filterer instance of IDID, bound to a specific deployed contract.
+func NewIDIDFilterer(address common.Address, filterer bind.ContractFilterer) (*IDIDFilterer, error) {
+	contract, err := bindIDID(address, nil, nil, filterer)
+	if err != nil {
+		return nil, err
+	}
+	return &IDIDFilterer{contract: contract}, nil
+}
+
+// bindIDID binds a generic wrapper to an already deployed contract.
+func bindIDID(address common.Address, caller bind.ContractCaller, transactor bind.ContractTransactor, filterer bind.ContractFilterer) (*bind.BoundContract, error) {
+	parsed, err := IDIDMetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+	return bind.NewBoundContract(address, *parsed, caller, transactor, filterer), nil
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_IDID *IDIDRaw) Call(opts *bind.CallOpts, result *[]interface{}, method string, params ...interface{}) error {
+	return _IDID.Contract.IDIDCaller.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_IDID *IDIDRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _IDID.Contract.IDIDTransactor.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_IDID *IDIDRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _IDID.Contract.IDIDTransactor.contract.Transact(opts, method, params...)
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_IDID *IDIDCallerRaw) Call(opts *bind.CallOpts, result *[]interface{}, method string, params ...interface{}) error {
+	return _IDID.Contract.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_IDID *IDIDTransactorRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _IDID.Contract.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_IDID *IDIDTransactorRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _IDID.Contract.contract.Transact(opts, method, params...)
+}
+
+// GetHash is a free data retrieval call binding the contract method 0xb00140aa.
+//
+// Solidity: function getHash(bytes ) view returns(bytes32)
+func (_IDID *IDIDCaller) GetHash(opts *bind.CallOpts, arg0 []byte) ([32]byte, error) {
+	var out []interface{}
+	err := _IDID.contract.Call(opts, &out, "getHash", arg0)
+
+	if err != nil {
+		return *new([32]byte), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new([32]byte)).(*[32]byte)
+
+	return out0, err
+
+}
+
+// GetHash is a free data retrieval call binding the contract method 0xb00140aa.
+//
+// Solidity: function getHash(bytes ) view returns(bytes32)
+func (_IDID *IDIDSession) GetHash(arg0 []byte) ([32]byte, error) {
+	return _IDID.Contract.GetHash(&_IDID.CallOpts, arg0)
+}
+
+// GetHash is a free data retrieval call binding the contract method 0xb00140aa.
+//
+// Solidity: function getHash(bytes ) view returns(bytes32)
+func (_IDID *IDIDCallerSession) GetHash(arg0 []byte) ([32]byte, error) {
+	return _IDID.Contract.GetHash(&_IDID.CallOpts, arg0)
+}
+
+// GetOwner is a free data retrieval call binding the contract method 0xb102bfbe.
+//
+// Solidity: function getOwner(bytes ) view returns(address)
+func (_IDID *IDIDCaller) GetOwner(opts *bind.CallOpts, arg0 []byte) (common.Address, error) {
+	var out []interface{}
+	err := _IDID.contract.Call(opts, &out, "getOwner", arg0)
+
+	if err != nil {
+		return *new(common.Address), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(common.Address)).(*common.Address)
+
+	return out0, err
+
+}
+
+// GetOwner is a free data retrieval call binding the contract method 0xb102bfbe.
+//
+// Solidity: function getOwner(bytes ) view returns(address)
+func (_IDID *IDIDSession) GetOwner(arg0 []byte) (common.Address, error) {
+	return _IDID.Contract.GetOwner(&_IDID.CallOpts, arg0)
+}
+
+// GetOwner is a free data retrieval call binding the contract method 0xb102bfbe.
+//
+// Solidity: function getOwner(bytes ) view returns(address)
+func (_IDID *IDIDCallerSession) GetOwner(arg0 []byte) (common.Address, error) {
+	return _IDID.Contract.GetOwner(&_IDID.CallOpts, arg0)
+}
+
+// GetURI is a free data retrieval call binding the contract method 0x8626dea9.
+//
+// Solidity: function getURI(bytes ) view returns(bytes)
+func (_IDID *IDIDCaller) GetURI(opts *bind.CallOpts, arg0 []byte) ([]byte, error) {
+	var out []interface{}
+	err := _IDID.contract.Call(opts, &out, "getURI", arg0)
+
+	if err != nil {
+		return *new([]byte), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new([]byte)).(*[]byte)
+
+	return out0, err
+
+}
+
+// GetURI is a free data retrieval call binding the contract method 0x8626dea9.
+//
+// Solidity: function getURI(bytes ) view returns(bytes)
+func (_IDID *IDIDSession) GetURI(arg0 []byte) ([]byte, error) {
+	return _IDID.Contract.GetURI(&_IDID.CallOpts, arg0)
+}
+
+// GetURI is a free data retrieval call binding the contract method 0x8626dea9.
+//
+// Solidity: function getURI(bytes ) view returns(bytes)
+func (_IDID *IDIDCallerSession) GetURI(arg0 []byte) ([]byte, error) {
+	return _IDID.Contract.GetURI(&_IDID.CallOpts, arg0)
+}
+
+// IDIDDIDCreatedIterator is returned from FilterDIDCreated and is used to iterate over the raw logs and unpacked data for DIDCreated events raised by the IDID contract.
+type IDIDDIDCreatedIterator struct {
+	Event *IDIDDIDCreated // Event containing the contract specifics and raw log
+
+	contract *bind.BoundContract // Generic contract to use for unpacking event data
+	event    string              // Event name to use for unpacking event data
+
+	logs chan types.Log        // Log channel receiving the found contract events
+	sub  ethereum.Subscription // Subscription for errors, completion and termination
+	done bool                  // Whether the subscription completed delivering logs
+	fail error                 // Occurred error to stop iteration
+}
+
+// Next advances the iterator to the subsequent event, returning whether there
+// are any more events found. In case of a retrieval or parsing error, false is
+// returned and Error() can be queried for the exact failure.
+func (it *IDIDDIDCreatedIterator) Next() bool {
+	// If the iterator failed, stop iterating
+	if it.fail != nil {
+		return false
+	}
+	// If the iterator completed, deliver directly whatever's available
+	if it.done {
+		select {
+		case log := <-it.logs:
+			it.Event = new(IDIDDIDCreated)
+			if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+				it.fail = err
+				return false
+			}
+			it.Event.Raw = log
+			return true
+
+		default:
+			return false
+		}
+	}
+	// Iterator still in progress, wait for either a data or an error event
+	select {
+	case log := <-it.logs:
+		it.Event = new(IDIDDIDCreated)
+		if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+			it.fail = err
+			return false
+		}
+		it.Event.Raw = log
+		return true
+
+	case err := <-it.sub.Err():
+		it.done = true
+		it.fail = err
+		return it.Next()
+	}
+}
+
+// Error returns any retrieval or parsing error occurred during filtering.
+func (it *IDIDDIDCreatedIterator) Error() error {
+	return it.fail
+}
+
+// Close terminates the iteration process, releasing any pending underlying
+// resources.
+func (it *IDIDDIDCreatedIterator) Close() error {
+	it.sub.Unsubscribe()
+	return nil
+}
+
+// IDIDDIDCreated represents a DIDCreated event raised by the IDID contract.
+type IDIDDIDCreated struct {
+	Operator common.Address
+	Did      string
+	Hash     [32]byte
+	Uri      string
+	Raw      types.Log // Blockchain specific contextual infos
+}
+
+// FilterDIDCreated is a free log retrieval operation binding the contract event 0x0a36151ef7283a91a6a8d052cd44a0c20f8c7207decf22d985eba8eb0a5a268b.
+//
+// Solidity: event DIDCreated(address indexed operator, string did, bytes32 hash, string uri)
+func (_IDID *IDIDFilterer) FilterDIDCreated(opts *bind.FilterOpts, operator []common.Address) (*IDIDDIDCreatedIterator, error) {
+
+	var operatorRule []interface{}
+	for _, operatorItem := range operator {
+		operatorRule = append(operatorRule, operatorItem)
+	}
+
+	logs, sub, err := _IDID.contract.FilterLogs(opts, "DIDCreated", operatorRule)
+	if err != nil {
+		return nil, err
+	}
+	return &IDIDDIDCreatedIterator{contract: _IDID.contract, event: "DIDCreated", logs: logs, sub: sub}, nil
+}
+
+// WatchDIDCreated is a free log subscription operation binding the contract event 0x0a36151ef7283a91a6a8d052cd44a0c20f8c7207decf22d985eba8eb0a5a268b.
+//
+// Solidity: event DIDCreated(address indexed operator, string did, bytes32 hash, string uri)
+func (_IDID *IDIDFilterer) WatchDIDCreated(opts *bind.WatchOpts, sink chan<- *IDIDDIDCreated, operator []common.Address) (event.Subscription, error) {
+
+	var operatorRule []interface{}
+	for _, operatorItem := range operator {
+		operatorRule = append(operatorRule, operatorItem)
+	}
+
+	logs, sub, err := _IDID.contract.WatchLogs(opts, "DIDCreated", operatorRule)
+	if err != nil {
+		return nil, err
+	}
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case log := <-logs:
+				// New log arrived, parse the event and forward to the user
+				event := new(IDIDDIDCreated)
+				if err := _IDID.contract.UnpackLog(event, "DIDCreated", log); err != nil {
+					return err
+				}
+				event.Raw = log
+
+				select {
+				case sink <- event:
+				case err := <-sub.Err():
+					return err
+				case <-quit:
+					return nil
+				}
+			case err := <-sub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
+
+// ParseDIDCreated is a log parse operation binding the contract event 0x0a36151ef7283a91a6a8d052cd44a0c20f8c7207decf22d985eba8eb0a5a268b.
+//
+// Solidity: event DIDCreated(address indexed operator, string did, bytes32 hash, string uri)
+func (_IDID *IDIDFilterer) ParseDIDCreated(log types.Log) (*IDIDDIDCreated, error) {
+	event := new(IDIDDIDCreated)
+	if err := _IDID.contract.UnpackLog(event, "DIDCreated", log); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
+}
+
+// IDIDDIDDeletedIterator is returned from FilterDIDDeleted and is used to iterate over the raw logs and unpacked data for DIDDeleted events raised by the IDID contract.
+type IDIDDIDDeletedIterator struct {
+	Event *IDIDDIDDeleted // Event containing the contract specifics and raw log
+
+	contract *bind.BoundContract // Generic contract to use for unpacking event data
+	event    string              // Event name to use for unpacking event data
+
+	logs chan types.Log        // Log channel receiving the found contract events
+	sub  ethereum.Subscription // Subscription for errors, completion and termination
+	done bool                  // Whether the subscription completed delivering logs
+	fail error                 // Occurred error to stop iteration
+}
+
+// Next advances the iterator to the subsequent event, returning whether there
+// are any more events found. In case of a retrieval or parsing error, false is
+// returned and Error() can be queried for the exact failure.
+func (it *IDIDDIDDeletedIterator) Next() bool {
+	// If the iterator failed, stop iterating
+	if it.fail != nil {
+		return false
+	}
+	// If the iterator completed, deliver directly whatever's available
+	if it.done {
+		select {
+		case log := <-it.logs:
+			it.Event = new(IDIDDIDDeleted)
+			if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+				it.fail = err
+				return false
+			}
+			it.Event.Raw = log
+			return true
+
+		default:
+			return false
+		}
+	}
+	// Iterator still in progress, wait for either a data or an error event
+	select {
+	case log := <-it.logs:
+		it.Event = new(IDIDDIDDeleted)
+		if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+			it.fail = err
+			return false
+		}
+		it.Event.Raw = log
+		return true
+
+	case err := <-it.sub.Err():
+		it.done = true
+		it.fail = err
+		return it.Next()
+	}
+}
+
+// Error returns any retrieval or parsing error occurred during filtering.
+func (it *IDIDDIDDeletedIterator) Error() error {
+	return it.fail
+}
+
+// Close terminates the iteration process, releasing any pending underlying
+// resources.
+func (it *IDIDDIDDeletedIterator) Close() error {
+	it.sub.Unsubscribe()
+	return nil
+}
+
+// IDIDDIDDeleted represents a DIDDeleted event raised by the IDID contract.
+type IDIDDIDDeleted struct {
+	Operator common.Address
+	Did      common.Hash
+	Raw      types.Log // Blockchain specific contextual infos
+}
+
+// FilterDIDDeleted is a free log retrieval operation binding the contract event 0x56c5173d83675a7f2b174113df9205152eb36c65ab39e41d5d1b8d9cb88eabd2.
+//
+// Solidity: event DIDDeleted(address indexed operator, string indexed did)
+func (_IDID *IDIDFilterer) FilterDIDDeleted(opts *bind.FilterOpts, operator []common.Address, did []string) (*IDIDDIDDeletedIterator, error) {
+
+	var operatorRule []interface{}
+	for _, operatorItem := range operator {
+		operatorRule = append(operatorRule, operatorItem)
+	}
+	var didRule []interface{}
+	for _, didItem := range did {
+		didRule = append(didRule, didItem)
+	}
+
+	logs, sub, err := _IDID.contract.FilterLogs(opts, "DIDDeleted", operatorRule, didRule)
+	if err != nil {
+		return nil, err
+	}
+	return &IDIDDIDDeletedIterator{contract: _IDID.contract, event: "DIDDeleted", logs: logs, sub: sub}, nil
+}
+
+// WatchDIDDeleted is a free log subscription operation binding the contract event 0x56c5173d83675a7f2b174113df9205152eb36c65ab39e41d5d1b8d9cb88eabd2.
+//
+// Solidity: event DIDDeleted(address indexed operator, string indexed did)
+func (_IDID *IDIDFilterer) WatchDIDDeleted(opts *bind.WatchOpts, sink chan<- *IDIDDIDDeleted, operator []common.Address, did []string) (event.Subscription, error) {
+
+	var operatorRule []interface{}
+	for _, operatorItem := range operator {
+		operatorRule = append(operatorRule, operatorItem)
+	}
+	var didRule []interface{}
+	for _, didItem := range did {
+		didRule = append(didRule, didItem)
+	}
+
+	logs, sub, err := _IDID.contract.WatchLogs(opts, "DIDDeleted", operatorRule, didRule)
+	if err != nil {
+		return nil, err
+	}
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case log := <-logs:
+				// New log arrived, parse the event and forward to the user
+				event := new(IDIDDIDDeleted)
+				if err := _IDID.contract.UnpackLog(event, "DIDDeleted", log); err != nil {
+					return err
+				}
+				event.Raw = log
+
+				select {
+				case sink <- event:
+				case err := <-sub.Err():
+					return err
+				case <-quit:
+					return nil
+				}
+			case err := <-sub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
+
+// ParseDIDDeleted is a log parse operation binding the contract event 0x56c5173d83675a7f2b174113df9205152eb36c65ab39e41d5d1b8d9cb88eabd2.
+//
+// Solidity: event DIDDeleted(address indexed operator, string indexed did)
+func (_IDID *IDIDFilterer) ParseDIDDeleted(log types.Log) (*IDIDDIDDeleted, error) {
+	event := new(IDIDDIDDeleted)
+	if err := _IDID.contract.UnpackLog(event, "DIDDeleted", log); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
+}
+
+// IDIDDIDUpdatedIterator is returned from FilterDIDUpdated and is used to iterate over the raw logs and unpacked data for DIDUpdated events raised by the IDID contract.
+type IDIDDIDUpdatedIterator struct {
+	Event *IDIDDIDUpdated // Event containing the contract specifics and raw log
+
+	contract *bind.BoundContract // Generic contract to use for unpacking event data
+	event    string              // Event name to use for unpacking event data
+
+	logs chan types.Log        // Log channel receiving the found contract events
+	sub  ethereum.Subscription // Subscription for errors, completion and termination
+	done bool                  // Whether the subscription completed delivering logs
+	fail error                 // Occurred error to stop iteration
+}
+
+// Next advances the iterator to the subsequent event, returning whether there
+// are any more events found. In case of a retrieval or parsing error, false is
+// returned and Error() can be queried for the exact failure.
+func (it *IDIDDIDUpdatedIterator) Next() bool {
+	// If the iterator failed, stop iterating
+	if it.fail != nil {
+		return false
+	}
+	// If the iterator completed, deliver directly whatever's available
+	if it.done {
+		select {
+		case log := <-it.logs:
+			it.Event = new(IDIDDIDUpdated)
+			if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+				it.fail = err
+				return false
+			}
+			it.Event.Raw = log
+			return true
+
+		default:
+			return false
+		}
+	}
+	// Iterator still in progress, wait for either a data or an error event
+	select {
+	case log := <-it.logs:
+		it.Event = new(IDIDDIDUpdated)
+		if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+			it.fail = err
+			return false
+		}
+		it.Event.Raw = log
+		return true
+
+	case err := <-it.sub.Err():
+		it.done = true
+		it.fail = err
+		return it.Next()
+	}
+}
+
+// Error returns any retrieval or parsing error occurred during filtering.
+func (it *IDIDDIDUpdatedIterator) Error() error {
+	return it.fail
+}
+
+// Close terminates the iteration process, releasing any pending underlying
+// resources.
+func (it *IDIDDIDUpdatedIterator) Close() error {
+	it.sub.Unsubscribe()
+	return nil
+}
+
+// IDIDDIDUpdated represents a DIDUpdated event raised by the IDID contract.
+type IDIDDIDUpdated struct {
+	Operator common.Address
+	Did      common.Hash
+	Hash     [32]byte
+	Uri      string
+	Raw      types.Log // Blockchain specific contextual infos
+}
+
+// FilterDIDUpdated is a free log retrieval operation binding the contract event 0x30665598c4da8be2cccefb7fe32a379decd1eae407564569a8e95b51e3afccd7.
+//
+// Solidity: event DIDUpdated(address indexed operator, string indexed did, bytes32 hash, string uri)
+func (_IDID *IDIDFilterer) FilterDIDUpdated(opts *bind.FilterOpts, operator []common.Address, did []string) (*IDIDDIDUpdatedIterator, error) {
+
+	var operatorRule []interface{}
+	for _, operatorItem := range operator {
+		operatorRule = append(operatorRule, operatorItem)
+	}
+	var didRule []interface{}
+	for _, didItem := range did {
+		didRule = append(didRule, didItem)
+	}
+
+	logs, sub, err := _IDID.contract.FilterLogs(opts, "DIDUpdated", operatorRule, didRule)
+	if err != nil {
+		return nil, err
+	}
+	return &IDIDDIDUpdatedIterator{contract: _IDID.contract, event: "DIDUpdated", logs: logs, sub: sub}, nil
+}
+
+// WatchDIDUpdated is a free log subscription operation binding the contract event 0x30665598c4da8be2cccefb7fe32a379decd1eae407564569a8e95b51e3afccd7.
+//
+// Solidity: event DIDUpdated(address indexed operator, string indexed did, bytes32 hash, string uri)
+func (_IDID *IDIDFilterer) WatchDIDUpdated(opts *bind.WatchOpts, sink chan<- *IDIDDIDUpdated, operator []common.Address, did []string) (event.Subscription, error) {
+
+	var operatorRule []interface{}
+	for _, operatorItem := range operator {
+		operatorRule = append(operatorRule, operatorItem)
+	}
+	var didRule []interface{}
+	for _, didItem := range did {
+		didRule = append(didRule, didItem)
+	}
+
+	logs, sub, err := _IDID.contract.WatchLogs(opts, "DIDUpdated", operatorRule, didRule)
+	if err != nil {
+		return nil, err
+	}
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case log := <-logs:
+				// New log arrived, parse the event and forward to the user
+				event := new(IDIDDIDUpdated)
+				if err := _IDID.contract.UnpackLog(event, "DIDUpdated", log); err != nil {
+					return err
+				}
+				event.Raw = log
+
+				select {
+				case sink <- event:
+				case err := <-sub.Err():
+					return err
+				case <-quit:
+					return nil
+				}
+			case err := <-sub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
+
+// ParseDIDUpdated is a log parse operation binding the contract event 0x30665598c4da8be2cccefb7fe32a379decd1eae407564569a8e95b51e3afccd7.
+//
+// Solidity: event DIDUpdated(address indexed operator, string indexed did, bytes32 hash, string uri)
+func (_IDID *IDIDFilterer) ParseDIDUpdated(log types.Log) (*IDIDDIDUpdated, error) {
+	event := new(IDIDDIDUpdated)
+	if err := _IDID.contract.UnpackLog(event, "DIDUpdated", log); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
+}
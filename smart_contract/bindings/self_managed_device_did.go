@@ -0,0 +1,327 @@
+// Code generated - DO NOT EDIT.
+// This file is a generated binding and any manual changes will be lost.
+
+package bindings
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var (
+	_ = errors.New
+	_ = big.NewInt
+	_ = strings.NewReader
+	_ = ethereum.NotFound
+	_ = bind.Bind
+	_ = common.Big1
+	_ = types.BloomLookup
+	_ = event.NewSubscription
+	_ = abi.ConvertType
+)
+
+// SelfManagedDeviceDIDMetaData contains all meta data concerning the SelfManagedDeviceDID contract.
+var SelfManagedDeviceDIDMetaData = &bind.MetaData{
+	ABI: "[{\"type\":\"function\",\"name\":\"createDID\",\"stateMutability\":\"nonpayable\",\"outputs\":[],\"inputs\":[{\"name\":\"uuid\",\"type\":\"string\"},{\"name\":\"proof\",\"type\":\"bytes\"},{\"name\":\"hash\",\"type\":\"bytes32\"},{\"name\":\"uri\",\"type\":\"string\"}]},{\"type\":\"function\",\"name\":\"deleteDID\",\"stateMutability\":\"nonpayable\",\"outputs\":[],\"inputs\":[{\"name\":\"uuid\",\"type\":\"string\"},{\"name\":\"proof\",\"type\":\"bytes\"}]},{\"type\":\"function\",\"name\":\"updateHash\",\"stateMutability\":\"nonpayable\",\"outputs\":[],\"inputs\":[{\"name\":\"uuid\",\"type\":\"string\"},{\"name\":\"proof\",\"type\":\"bytes\"},{\"name\":\"hash\",\"type\":\"bytes32\"}]},{\"type\":\"function\",\"name\":\"updateURI\",\"stateMutability\":\"nonpayable\",\"outputs\":[],\"inputs\":[{\"name\":\"uuid\",\"type\":\"string\"},{\"name\":\"proof\",\"type\":\"bytes\"},{\"name\":\"uri\",\"type\":\"string\"}]},{\"type\":\"function\",\"name\":\"getHash\",\"stateMutability\":\"view\",\"inputs\":[{\"name\":\"did\",\"type\":\"string\"}],\"outputs\":[{\"name\":\"\",\"type\":\"bytes32\"}]},{\"type\":\"function\",\"name\":\"getURI\",\"stateMutability\":\"view\",\"inputs\":[{\"name\":\"did\",\"type\":\"string\"}],\"outputs\":[{\"name\":\"\",\"type\":\"string\"}]}]",
+}
+
+// SelfManagedDeviceDIDABI is the input ABI used to generate the binding from.
+// Deprecated: Use SelfManagedDeviceDIDMetaData.ABI instead.
+var SelfManagedDeviceDIDABI = SelfManagedDeviceDIDMetaData.ABI
+
+// SelfManagedDeviceDID is an auto generated Go binding around an Ethereum contract.
+type SelfManagedDeviceDID struct {
+	SelfManagedDeviceDIDCaller     // Read-only binding to the contract
+	SelfManagedDeviceDIDTransactor // Write-only binding to the contract
+	SelfManagedDeviceDIDFilterer   // Log filterer for contract events
+}
+
+// SelfManagedDeviceDIDCaller is an auto generated read-only Go binding around an Ethereum contract.
+type SelfManagedDeviceDIDCaller struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// SelfManagedDeviceDIDTransactor is an auto generated write-only Go binding around an Ethereum contract.
+type SelfManagedDeviceDIDTransactor struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// SelfManagedDeviceDIDFilterer is an auto generated log filtering Go binding around an Ethereum contract events.
+type SelfManagedDeviceDIDFilterer struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// SelfManagedDeviceDIDSession is an auto generated Go binding around an Ethereum contract,
+// with pre-set call and transact options.
+type SelfManagedDeviceDIDSession struct {
+	Contract     *SelfManagedDeviceDID // Generic contract binding to set the session for
+	CallOpts     bind.CallOpts         // Call options to use throughout this session
+	TransactOpts bind.TransactOpts     // Transaction auth options to use throughout this session
+}
+
+// SelfManagedDeviceDIDCallerSession is an auto generated read-only Go binding around an Ethereum contract,
+// with pre-set call options.
+type SelfManagedDeviceDIDCallerSession struct {
+	Contract *SelfManagedDeviceDIDCaller // Generic contract caller binding to set the session for
+	CallOpts bind.CallOpts               // Call options to use throughout this session
+}
+
+// SelfManagedDeviceDIDTransactorSession is an auto generated write-only Go binding around an Ethereum contract,
+// with pre-set transact options.
+type SelfManagedDeviceDIDTransactorSession struct {
+	Contract     *SelfManagedDeviceDIDTransactor // Generic contract transactor binding to set the session for
+	TransactOpts bind.TransactOpts               // Transaction auth options to use throughout this session
+}
+
+// SelfManagedDeviceDIDRaw is an auto generated low-level Go binding around an Ethereum contract.
+type SelfManagedDeviceDIDRaw struct {
+	Contract *SelfManagedDeviceDID // Generic contract binding to access the raw methods on
+}
+
+// SelfManagedDeviceDIDCallerRaw is an auto generated low-level read-only Go binding around an Ethereum contract.
+type SelfManagedDeviceDIDCallerRaw struct {
+	Contract *SelfManagedDeviceDIDCaller // Generic read-only contract binding to access the raw methods on
+}
+
+// SelfManagedDeviceDIDTransactorRaw is an auto generated low-level write-only Go binding around an Ethereum contract.
+type SelfManagedDeviceDIDTransactorRaw struct {
+	Contract *SelfManagedDeviceDIDTransactor // Generic write-only contract binding to access the raw methods on
+}
+
+// NewSelfManagedDeviceDID creates a new instance of SelfManagedDeviceDID, bound to a specific deployed contract.
+func NewSelfManagedDeviceDID(address common.Address, backend bind.ContractBackend) (*SelfManagedDeviceDID, error) {
+	contract, err := bindSelfManagedDeviceDID(address, backend, backend, backend)
+	if err != nil {
+		return nil, err
+	}
+	return &SelfManagedDeviceDID{SelfManagedDeviceDIDCaller: SelfManagedDeviceDIDCaller{contract: contract}, SelfManagedDeviceDIDTransactor: SelfManagedDeviceDIDTransactor{contract: contract}, SelfManagedDeviceDIDFilterer: SelfManagedDeviceDIDFilterer{contract: contract}}, nil
+}
+
+// NewSelfManagedDeviceDIDCaller creates a new read-only instance of SelfManagedDeviceDID, bound to a specific deployed contract.
+func NewSelfManagedDeviceDIDCaller(address common.Address, caller bind.ContractCaller) (*SelfManagedDeviceDIDCaller, error) {
+	contract, err := bindSelfManagedDeviceDID(address, caller, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &SelfManagedDeviceDIDCaller{contract: contract}, nil
+}
+
+// NewSelfManagedDeviceDIDTransactor creates a new write-only instance of SelfManagedDeviceDID, bound to a specific deployed contract.
+func NewSelfManagedDeviceDIDTransactor(address common.Address, transactor bind.ContractTransactor) (*SelfManagedDeviceDIDTransactor, error) {
+	contract, err := bindSelfManagedDeviceDID(address, nil, transactor, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &SelfManagedDeviceDIDTransactor{contract: contract}, nil
+}
+
+// NewSelfManagedDeviceDIDFilterer creates a new log filterer instance of SelfManagedDeviceDID, bound to a specific deployed contract.
+func NewSelfManagedDeviceDIDFilterer(address common.Address, filterer bind.ContractFilterer) (*SelfManagedDeviceDIDFilterer, error) {
+	contract, err := bindSelfManagedDeviceDID(address, nil, nil, filterer)
+	if err != nil {
+		return nil, err
+	}
+	return &SelfManagedDeviceDIDFilterer{contract: contract}, nil
+}
+
+// bindSelfManagedDeviceDID binds a generic wrapper to an already deployed contract.
+func bindSelfManagedDeviceDID(address common.Address, caller bind.ContractCaller, transactor bind.ContractTransactor, filterer bind.ContractFilterer) (*bind.BoundContract, error) {
+	parsed, err := SelfManagedDeviceDIDMetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+	return bind.NewBoundContract(address, *parsed, caller, transactor, filterer), nil
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_SelfManagedDeviceDID *SelfManagedDeviceDIDRaw) Call(opts *bind.CallOpts, result *[]interface{}, method string, params ...interface{}) error {
+	return _SelfManagedDeviceDID.Contract.SelfManagedDeviceDIDCaller.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_SelfManagedDeviceDID *SelfManagedDeviceDIDRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _SelfManagedDeviceDID.Contract.SelfManagedDeviceDIDTransactor.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_SelfManagedDeviceDID *SelfManagedDeviceDIDRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _SelfManagedDeviceDID.Contract.SelfManagedDeviceDIDTransactor.contract.Transact(opts, method, params...)
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_SelfManagedDeviceDID *SelfManagedDeviceDIDCallerRaw) Call(opts *bind.CallOpts, result *[]interface{}, method string, params ...interface{}) error {
+	return _SelfManagedDeviceDID.Contract.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_SelfManagedDeviceDID *SelfManagedDeviceDIDTransactorRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _SelfManagedDeviceDID.Contract.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_SelfManagedDeviceDID *SelfManagedDeviceDIDTransactorRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _SelfManagedDeviceDID.Contract.contract.Transact(opts, method, params...)
+}
+
+// GetHash is a free data retrieval call binding the contract method 0x5b6beeb9.
+//
+// Solidity: function getHash(string did) view returns(bytes32)
+func (_SelfManagedDeviceDID *SelfManagedDeviceDIDCaller) GetHash(opts *bind.CallOpts, did string) ([32]byte, error) {
+	var out []interface{}
+	err := _SelfManagedDeviceDID.contract.Call(opts, &out, "getHash", did)
+
+	if err != nil {
+		return *new([32]byte), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new([32]byte)).(*[32]byte)
+
+	return out0, err
+
+}
+
+// GetHash is a free data retrieval call binding the contract method 0x5b6beeb9.
+//
+// Solidity: function getHash(string did) view returns(bytes32)
+func (_SelfManagedDeviceDID *SelfManagedDeviceDIDSession) GetHash(did string) ([32]byte, error) {
+	return _SelfManagedDeviceDID.Contract.GetHash(&_SelfManagedDeviceDID.CallOpts, did)
+}
+
+// GetHash is a free data retrieval call binding the contract method 0x5b6beeb9.
+//
+// Solidity: function getHash(string did) view returns(bytes32)
+func (_SelfManagedDeviceDID *SelfManagedDeviceDIDCallerSession) GetHash(did string) ([32]byte, error) {
+	return _SelfManagedDeviceDID.Contract.GetHash(&_SelfManagedDeviceDID.CallOpts, did)
+}
+
+// GetURI is a free data retrieval call binding the contract method 0x93ff5d3e.
+//
+// Solidity: function getURI(string did) view returns(string)
+func (_SelfManagedDeviceDID *SelfManagedDeviceDIDCaller) GetURI(opts *bind.CallOpts, did string) (string, error) {
+	var out []interface{}
+	err := _SelfManagedDeviceDID.contract.Call(opts, &out, "getURI", did)
+
+	if err != nil {
+		return *new(string), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(string)).(*string)
+
+	return out0, err
+
+}
+
+// GetURI is a free data retrieval call binding the contract method 0x93ff5d3e.
+//
+// Solidity: function getURI(string did) view returns(string)
+func (_SelfManagedDeviceDID *SelfManagedDeviceDIDSession) GetURI(did string) (string, error) {
+	return _SelfManagedDeviceDID.Contract.GetURI(&_SelfManagedDeviceDID.CallOpts, did)
+}
+
+// GetURI is a free data retrieval call binding the contract method 0x93ff5d3e.
+//
+// Solidity: function getURI(string did) view returns(string)
+func (_SelfManagedDeviceDID *SelfManagedDeviceDIDCallerSession) GetURI(did string) (string, error) {
+	return _SelfManagedDeviceDID.Contract.GetURI(&_SelfManagedDeviceDID.CallOpts, did)
+}
+
+// CreateDID is a paid mutator transaction binding the contract method 0x0da90997.
+//
+// Solidity: function createDID(string uuid, bytes proof, bytes32 hash, string uri) returns()
+func (_SelfManagedDeviceDID *SelfManagedDeviceDIDTransactor) CreateDID(opts *bind.TransactOpts, uuid string, proof []byte, hash [32]byte, uri string) (*types.Transaction, error) {
+	return _SelfManagedDeviceDID.contract.Transact(opts, "createDID", uuid, proof, hash, uri)
+}
+
+// CreateDID is a paid mutator transaction binding the contract method 0x0da90997.
+//
+// Solidity: function createDID(string uuid, bytes proof, bytes32 hash, string uri) returns()
+func (_SelfManagedDeviceDID *SelfManagedDeviceDIDSession) CreateDID(uuid string, proof []byte, hash [32]byte, uri string) (*types.Transaction, error) {
+	return _SelfManagedDeviceDID.Contract.CreateDID(&_SelfManagedDeviceDID.TransactOpts, uuid, proof, hash, uri)
+}
+
+// CreateDID is a paid mutator transaction binding the contract method 0x0da90997.
+//
+// Solidity: function createDID(string uuid, bytes proof, bytes32 hash, string uri) returns()
+func (_SelfManagedDeviceDID *SelfManagedDeviceDIDTransactorSession) CreateDID(uuid string, proof []byte, hash [32]byte, uri string) (*types.Transaction, error) {
+	return _SelfManagedDeviceDID.Contract.CreateDID(&_SelfManagedDeviceDID.TransactOpts, uuid, proof, hash, uri)
+}
+
+// DeleteDID is a paid mutator transaction binding the contract method 0x3262c418.
+//
+// Solidity: function deleteDID(string uuid, bytes proof) returns()
+func (_SelfManagedDeviceDID *SelfManagedDeviceDIDTransactor) DeleteDID(opts *bind.TransactOpts, uuid string, proof []byte) (*types.Transaction, error) {
+	return _SelfManagedDeviceDID.contract.Transact(opts, "deleteDID", uuid, proof)
+}
+
+// DeleteDID is a paid mutator transaction binding the contract method 0x3262c418.
+//
+// Solidity: function deleteDID(string uuid, bytes proof) returns()
+func (_SelfManagedDeviceDID *SelfManagedDeviceDIDSession) DeleteDID(uuid string, proof []byte) (*types.Transaction, error) {
+	return _SelfManagedDeviceDID.Contract.DeleteDID(&_SelfManagedDeviceDID.TransactOpts, uuid, proof)
+}
+
+// DeleteDID is a paid mutator transaction binding the contract method 0x3262c418.
+//
+// Solidity: function deleteDID(string uuid, bytes proof) returns()
+func (_SelfManagedDeviceDID *SelfManagedDeviceDIDTransactorSession) DeleteDID(uuid string, proof []byte) (*types.Transaction, error) {
+	return _SelfManagedDeviceDID.Contract.DeleteDID(&_SelfManagedDeviceDID.TransactOpts, uuid, proof)
+}
+
+// UpdateHash is a paid mutator transaction binding the contract method 0x74990ef8.
+//
+// Solidity: function updateHash(string uuid, bytes proof, bytes32 hash) returns()
+func (_SelfManagedDeviceDID *SelfManagedDeviceDIDTransactor) UpdateHash(opts *bind.TransactOpts, uuid string, proof []byte, hash [32]byte) (*types.Transaction, error) {
+	return _SelfManagedDeviceDID.contract.Transact(opts, "updateHash", uuid, proof, hash)
+}
+
+// UpdateHash is a paid mutator transaction binding the contract method 0x74990ef8.
+//
+// Solidity: function updateHash(string uuid, bytes proof, bytes32 hash) returns()
+func (_SelfManagedDeviceDID *SelfManagedDeviceDIDSession) UpdateHash(uuid string, proof []byte, hash [32]byte) (*types.Transaction, error) {
+	return _SelfManagedDeviceDID.Contract.UpdateHash(&_SelfManagedDeviceDID.TransactOpts, uuid, proof, hash)
+}
+
+// UpdateHash is a paid mutator transaction binding the contract method 0x74990ef8.
+//
+// Solidity: function updateHash(string uuid, bytes proof, bytes32 hash) returns()
+func (_SelfManagedDeviceDID *SelfManagedDeviceDIDTransactorSession) UpdateHash(uuid string, proof []byte, hash [32]byte) (*types.Transaction, error) {
+	return _SelfManagedDeviceDID.Contract.UpdateHash(&_SelfManagedDeviceDID.TransactOpts, uuid, proof, hash)
+}
+
+// UpdateURI is a paid mutator transaction binding the contract method 0xf818daea.
+//
+// Solidity: function updateURI(string uuid, bytes proof, string uri) returns()
+func (_SelfManagedDeviceDID *SelfManagedDeviceDIDTransactor) UpdateURI(opts *bind.TransactOpts, uuid string, proof []byte, uri string) (*types.Transaction, error) {
+	return _SelfManagedDeviceDID.contract.Transact(opts, "updateURI", uuid, proof, uri)
+}
+
+// UpdateURI is a paid mutator transaction binding the contract method 0xf818daea.
+//
+// Solidity: function updateURI(string uuid, bytes proof, string uri) returns()
+func (_SelfManagedDeviceDID *SelfManagedDeviceDIDSession) UpdateURI(uuid string, proof []byte, uri string) (*types.Transaction, error) {
+	return _SelfManagedDeviceDID.Contract.UpdateURI(&_SelfManagedDeviceDID.TransactOpts, uuid, proof, uri)
+}
+
+// UpdateURI is a paid mutator transaction binding the contract method 0xf818daea.
+//
+// Solidity: function updateURI(string uuid, bytes proof, string uri) returns()
+func (_SelfManagedDeviceDID *SelfManagedDeviceDIDTransactorSession) UpdateURI(uuid string, proof []byte, uri string) (*types.Transaction, error) {
+	return _SelfManagedDeviceDID.Contract.UpdateURI(&_SelfManagedDeviceDID.TransactOpts, uuid, proof, uri)
+}
@@ -18,7 +18,7 @@ contract Agentable {
     }
 
     function addrToString(address _addr) internal pure returns(string memory) {
-        bytes32 value = bytes32(uint256(_addr));
+        bytes32 value = bytes32(uint256(uint160(_addr)));
         bytes memory alphabet = "0123456789abcdef";
 
         bytes memory str = new bytes(42);
@@ -42,9 +42,9 @@ contract Agentable {
             j /= 10;
         }
         bytes memory b = new bytes(length);
-        uint k = length - 1;
+        uint k = length;
         while (i != 0){
-            b[k--] = bytes(uint8(48 + i % 10));
+            b[--k] = bytes1(uint8(48 + i % 10));
             i /= 10;
         }
         return string(b);
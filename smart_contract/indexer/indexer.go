@@ -0,0 +1,476 @@
+// Package indexer follows the DIDCreated, DIDUpdated and DIDDeleted events of
+// a DID registry and keeps the current state and the history of every DID in
+// a local store, so they can be queried without scanning the chain.
+//
+// Blocks are indexed once they have the configured number of confirmations.
+// Deeper reorganizations are detected by comparing the hashes of indexed
+// blocks with the canonical chain: the events of blocks that left it are
+// rolled back and their replacements indexed. The last indexed block is
+// checkpointed along with the events, so a restarted indexer resumes where it
+// stopped.
+package indexer
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/storage"
+	"github.com/syndtr/goleveldb/leveldb/util"
+
+	"EncrypteDL/EncryrpteID/smart_contract/bindings"
+)
+
+// Backend is the connection to the chain, such as an *ethclient.Client or
+// the client of a simulated backend.
+type Backend interface {
+	bind.ContractCaller
+	bind.ContractFilterer
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+}
+
+// Indexer indexes the events of a registry contract.
+type Indexer struct {
+	backend  Backend
+	address  common.Address
+	idid     *bindings.IDIDCaller
+	filterer *bindings.IDIDFilterer
+	topics   []common.Hash // DIDCreated, DIDUpdated and DIDDeleted
+	db       *leveldb.DB
+
+	start         uint64
+	confirmations uint64
+	batchSize     uint64
+	pollInterval  time.Duration
+
+	mu sync.Mutex // Serializes syncs
+}
+
+// Option configures an Indexer at construction time.
+type Option func(*Indexer)
+
+// WithStartBlock sets the first block to index, usually the block the
+// registry was deployed in. Updates of DIDs created before it are ignored.
+func WithStartBlock(n uint64) Option {
+	return func(ix *Indexer) {
+		ix.start = n
+	}
+}
+
+// WithConfirmations sets the number of blocks, including its own, a block
+// must be buried under before it's indexed. Defaults to 12.
+func WithConfirmations(n uint64) Option {
+	return func(ix *Indexer) {
+		ix.confirmations = n
+	}
+}
+
+// WithBatchSize sets the maximum number of blocks whose events are requested
+// at once. Defaults to 1000.
+func WithBatchSize(n uint64) Option {
+	return func(ix *Indexer) {
+		ix.batchSize = n
+	}
+}
+
+// WithPollInterval sets how often Run checks for new blocks, every second by
+// default.
+func WithPollInterval(d time.Duration) Option {
+	return func(ix *Indexer) {
+		ix.pollInterval = d
+	}
+}
+
+// New opens the store at path to index the registry deployed at address. An
+// empty path keeps the store in memory, which is only useful for tests.
+func New(backend Backend, address common.Address, path string, opts ...Option) (*Indexer, error) {
+	idid, err := bindings.NewIDIDCaller(address, backend)
+	if err != nil {
+		return nil, err
+	}
+	filterer, err := bindings.NewIDIDFilterer(address, backend)
+	if err != nil {
+		return nil, err
+	}
+	abi, err := bindings.IDIDMetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+
+	ix := Indexer{
+		backend:  backend,
+		address:  address,
+		idid:     idid,
+		filterer: filterer,
+		topics: []common.Hash{
+			abi.Events["DIDCreated"].ID,
+			abi.Events["DIDUpdated"].ID,
+			abi.Events["DIDDeleted"].ID,
+		},
+		confirmations: 12,
+		batchSize:     1000,
+		pollInterval:  time.Second,
+	}
+	for _, opt := range opts {
+		opt(&ix)
+	}
+	if ix.confirmations == 0 || ix.batchSize == 0 || ix.pollInterval <= 0 {
+		return nil, errors.New("invalid indexer options")
+	}
+
+	switch path {
+	case "":
+		ix.db, err = leveldb.Open(storage.NewMemStorage(), nil)
+	default:
+		ix.db, err = leveldb.OpenFile(path, nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening store: %w", err)
+	}
+
+	return &ix, nil
+}
+
+// Close closes the store. Stop Run first.
+func (ix *Indexer) Close() error {
+	return ix.db.Close()
+}
+
+// Run syncs the index every poll interval until the context is done or a
+// sync fails. It can be called again after a failure, indexing resumes from
+// the checkpoint.
+func (ix *Indexer) Run(ctx context.Context) error {
+	ticker := time.NewTicker(ix.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := ix.Sync(ctx); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync indexes the confirmed blocks after the checkpoint, first rolling back
+// the indexed blocks that were reorganized out of the chain.
+func (ix *Indexer) Sync(ctx context.Context) error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	head, err := ix.backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("reading head: %w", err)
+	}
+	if head.Number.Uint64()+1 < ix.confirmations {
+		return nil
+	}
+	safe := head.Number.Uint64() + 1 - ix.confirmations
+
+	cp, err := ix.checkpoint()
+	if err != nil {
+		return err
+	}
+	next := ix.start
+	if cp.Hash != (common.Hash{}) {
+		if cp, err = ix.reconcile(ctx, cp); err != nil {
+			return err
+		}
+		if cp.Hash != (common.Hash{}) {
+			next = cp.Number + 1
+		}
+	}
+
+	for next <= safe {
+		to := min(next+ix.batchSize-1, safe)
+		done, err := ix.index(ctx, next, to)
+		if err != nil || !done {
+			return err
+		}
+		next = to + 1
+	}
+
+	return nil
+}
+
+// reconcile checks the checkpoint is still on the canonical chain. If it's
+// not, it rolls the index back to the last block with events that still is,
+// and returns the new checkpoint.
+func (ix *Indexer) reconcile(ctx context.Context, cp checkpoint) (checkpoint, error) {
+	canonical, err := ix.canonical(ctx, cp.Number, cp.Hash)
+	if err != nil || canonical {
+		return cp, err
+	}
+
+	iter := ix.db.NewIterator(&util.Range{Start: prefixHash, Limit: join(prefixHash, be64(cp.Number+1))}, nil)
+	defer iter.Release()
+
+	var ancestor checkpoint
+	for ok := iter.Last(); ok; ok = iter.Prev() {
+		block := checkpoint{
+			Number: binary.BigEndian.Uint64(iter.Key()[len(prefixHash):]),
+			Hash:   common.BytesToHash(iter.Value()),
+		}
+		canonical, err := ix.canonical(ctx, block.Number, block.Hash)
+		if err != nil {
+			return cp, err
+		}
+		if canonical {
+			ancestor = block
+			break
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return cp, err
+	}
+
+	return ancestor, ix.rollback(ancestor)
+}
+
+// canonical reports whether a block is on the canonical chain.
+func (ix *Indexer) canonical(ctx context.Context, number uint64, hash common.Hash) (bool, error) {
+	header, err := ix.backend.HeaderByNumber(ctx, new(big.Int).SetUint64(number))
+	if errors.Is(err, ethereum.NotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("reading block %d: %w", number, err)
+	}
+	return header.Hash() == hash, nil
+}
+
+// rollback removes the events after a checkpoint and restores the states of
+// their DIDs. A zero checkpoint removes every event.
+func (ix *Indexer) rollback(cp checkpoint) error {
+	from := uint64(0)
+	if cp.Hash != (common.Hash{}) {
+		from = cp.Number + 1
+	}
+
+	batch := new(leveldb.Batch)
+	affected := make(map[string]bool)
+
+	iter := ix.db.NewIterator(&util.Range{Start: join(prefixBlock, be64(from)), Limit: util.BytesPrefix(prefixBlock).Limit}, nil)
+	for iter.Next() {
+		key := iter.Value()
+		e, err := ix.event(join(prefixEvent, key, iter.Key()[len(prefixBlock):]))
+		if err != nil {
+			iter.Release()
+			return err
+		}
+		deleteEvent(batch, key, e)
+		affected[string(key)] = true
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return err
+	}
+
+	for key := range affected {
+		if err := ix.restore(batch, []byte(key), from); err != nil {
+			return err
+		}
+	}
+
+	if cp.Hash == (common.Hash{}) {
+		batch.Delete(keyCheckpoint)
+	} else if err := putCheckpoint(batch, cp); err != nil {
+		return err
+	}
+
+	if err := ix.db.Write(batch, nil); err != nil {
+		return fmt.Errorf("rolling back to block %d: %w", cp.Number, err)
+	}
+
+	return nil
+}
+
+// restore replays the events of a DID before a block to recompute its state.
+func (ix *Indexer) restore(batch *leveldb.Batch, key []byte, before uint64) error {
+	old, err := ix.state(key)
+	if err != nil {
+		return err
+	}
+
+	var s *State
+	iter := ix.db.NewIterator(&util.Range{Start: join(prefixEvent, key), Limit: join(prefixEvent, key, be64(before))}, nil)
+	for iter.Next() {
+		e, err := ix.event(iter.Key())
+		if err != nil {
+			iter.Release()
+			return err
+		}
+		var next State
+		if s != nil {
+			next = *s
+		}
+		next = next.apply(e)
+		s = &next
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return err
+	}
+
+	return putState(batch, key, &old, s)
+}
+
+// index indexes the events of a range of blocks and moves the checkpoint to
+// its end. It returns false without indexing anything if a block of the
+// range was reorganized meanwhile, the next sync takes care of it.
+func (ix *Indexer) index(ctx context.Context, from, to uint64) (bool, error) {
+	end, err := ix.backend.HeaderByNumber(ctx, new(big.Int).SetUint64(to))
+	if err != nil {
+		return false, fmt.Errorf("reading block %d: %w", to, err)
+	}
+	logs, err := ix.backend.FilterLogs(ctx, ethereum.FilterQuery{
+		FromBlock: new(big.Int).SetUint64(from),
+		ToBlock:   new(big.Int).SetUint64(to),
+		Addresses: []common.Address{ix.address},
+		Topics:    [][]common.Hash{ix.topics},
+	})
+	if err != nil {
+		return false, fmt.Errorf("filtering logs of blocks %d to %d: %w", from, to, err)
+	}
+
+	headers := map[uint64]*types.Header{to: end}
+	olds := make(map[string]*State)
+	states := make(map[string]*State)
+	batch := new(leveldb.Batch)
+
+	for _, log := range logs {
+		if log.Removed {
+			continue
+		}
+		header, ok := headers[log.BlockNumber]
+		if !ok {
+			if header, err = ix.backend.HeaderByNumber(ctx, new(big.Int).SetUint64(log.BlockNumber)); err != nil {
+				return false, fmt.Errorf("reading block %d: %w", log.BlockNumber, err)
+			}
+			headers[log.BlockNumber] = header
+		}
+		if header.Hash() != log.BlockHash {
+			return false, nil
+		}
+
+		e, key, err := ix.decode(log)
+		if err != nil {
+			return false, err
+		}
+		e.Time = header.Time
+
+		s, ok := states[string(key)]
+		if !ok {
+			current, err := ix.state(key)
+			switch {
+			case err == nil:
+				s = &current
+			case !errors.Is(err, ErrNotFound):
+				return false, err
+			}
+			olds[string(key)] = s
+		}
+
+		switch e.Kind {
+		case Created:
+			if e.Owner, err = ix.owner(ctx, e); err != nil {
+				return false, err
+			}
+		default:
+			if s == nil {
+				continue // Created before the start block
+			}
+			e.DID = s.DID
+		}
+
+		if err := putEvent(batch, key, e); err != nil {
+			return false, err
+		}
+		var next State
+		if s != nil {
+			next = *s
+		}
+		next = next.apply(e)
+		states[string(key)] = &next
+	}
+
+	for key, s := range states {
+		if err := putState(batch, []byte(key), olds[key], s); err != nil {
+			return false, err
+		}
+	}
+	if err := putCheckpoint(batch, checkpoint{Number: to, Hash: end.Hash()}); err != nil {
+		return false, err
+	}
+
+	if err := ix.db.Write(batch, nil); err != nil {
+		return false, fmt.Errorf("writing blocks %d to %d: %w", from, to, err)
+	}
+
+	return true, nil
+}
+
+// decode converts a log to an event and returns the store key of its DID.
+func (ix *Indexer) decode(log types.Log) (Event, []byte, error) {
+	e := Event{
+		Block:     log.BlockNumber,
+		BlockHash: log.BlockHash,
+		TxHash:    log.TxHash,
+		Index:     log.Index,
+	}
+
+	switch log.Topics[0] {
+	case ix.topics[0]:
+		ev, err := ix.filterer.ParseDIDCreated(log)
+		if err != nil {
+			return e, nil, err
+		}
+		e.Kind, e.DID, e.Operator, e.Hash, e.URI = Created, ev.Did, ev.Operator, ev.Hash, ev.Uri
+		return e, didKey(ev.Did), nil
+	case ix.topics[1]:
+		ev, err := ix.filterer.ParseDIDUpdated(log)
+		if err != nil {
+			return e, nil, err
+		}
+		e.Kind, e.Operator, e.Hash, e.URI = Updated, ev.Operator, ev.Hash, ev.Uri
+		return e, ev.Did.Bytes(), nil
+	default:
+		ev, err := ix.filterer.ParseDIDDeleted(log)
+		if err != nil {
+			return e, nil, err
+		}
+		e.Kind, e.Operator = Deleted, ev.Operator
+		return e, ev.Did.Bytes(), nil
+	}
+}
+
+// owner reads the owner of a DID created by an event, which is the operator
+// unless an agent created it for the holder. A DID deleted in the block it
+// was created in has no owner anymore: the operator is assumed.
+func (ix *Indexer) owner(ctx context.Context, e Event) (common.Address, error) {
+	owner, err := ix.idid.GetOwner(&bind.CallOpts{Context: ctx, BlockNumber: new(big.Int).SetUint64(e.Block)}, []byte(e.DID))
+	if err != nil {
+		return common.Address{}, fmt.Errorf("reading owner of %s: %w", e.DID, err)
+	}
+	if owner == (common.Address{}) {
+		return e.Operator, nil
+	}
+	return owner, nil
+}
+
+func hashDID(did string) common.Hash {
+	return crypto.Keccak256Hash([]byte(did))
+}
@@ -0,0 +1,280 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	agentable "EncrypteDL/EncryrpteID/smart_contract/agent"
+	"EncrypteDL/EncryrpteID/smart_contract/internal/registrytest"
+)
+
+// newIndexer returns an indexer of the chain registry.
+func newIndexer(t *testing.T, chain *registrytest.Chain, path string, opts ...Option) *Indexer {
+	t.Helper()
+	opts = append([]Option{WithConfirmations(1), WithPollInterval(10 * time.Millisecond)}, opts...)
+	ix, err := New(chain.Backend.Client(), chain.Registry, path, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ix.Close() })
+	return ix
+}
+
+// syncIndex syncs the index and checks the checkpoint is the head minus the
+// unconfirmed blocks.
+func syncIndex(t *testing.T, chain *registrytest.Chain, ix *Indexer) {
+	t.Helper()
+	ctx := context.Background()
+	if err := ix.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	head, err := chain.Backend.Client().HeaderByNumber(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	number, hash, err := ix.Checkpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := head.Number.Uint64() + 1 - ix.confirmations
+	if number != want || hash == (common.Hash{}) {
+		t.Fatalf("checkpoint at %d %s, want %d", number, hash, want)
+	}
+}
+
+func kinds(events []Event) []Kind {
+	var kinds []Kind
+	for _, e := range events {
+		kinds = append(kinds, e.Kind)
+	}
+	return kinds
+}
+
+func equal[T comparable](a, b []T) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestIndexer(t *testing.T) {
+	ctx := context.Background()
+	chain := registrytest.NewChain(t, 2)
+	alice, bob := chain.NewClient(t, 0), chain.NewClient(t, 1)
+	ix := newIndexer(t, chain, "", WithBatchSize(2))
+
+	created := chain.Mine(t, alice)(alice.Create(ctx, "alice-1", nil, common.Hash{1}, "ipfs://a1"))
+	chain.Mine(t, bob)(bob.Create(ctx, "bob-1", nil, common.Hash{2}, "ipfs://b1"))
+	chain.Mine(t, alice)(alice.Create(ctx, "alice-2", nil, common.Hash{3}, "ipfs://a2"))
+	updated := chain.Mine(t, alice)(alice.UpdateURI(ctx, "alice-1", nil, "https://example.com/a1"))
+	chain.Backend.Commit()
+	deleted := chain.Mine(t, alice)(alice.Delete(ctx, "alice-2", nil))
+	syncIndex(t, chain, ix)
+
+	want := State{DID: "alice-1", Owner: alice.From(), Hash: common.Hash{1}, URI: "https://example.com/a1",
+		Created: created.BlockNumber.Uint64(), Updated: updated.BlockNumber.Uint64()}
+	if have, err := ix.State("alice-1"); err != nil || have != want {
+		t.Errorf("unexpected state:\n have %+v, %v\n want %+v", have, err, want)
+	}
+	if have, err := ix.State("alice-2"); err != nil || !have.Deleted || have.Updated != deleted.BlockNumber.Uint64() {
+		t.Errorf("unexpected state of the deleted DID %+v, %v", have, err)
+	}
+	if _, err := ix.State("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	history, err := ix.History("alice-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equal(kinds(history), []Kind{Created, Updated}) {
+		t.Fatalf("unexpected history %+v", history)
+	}
+	header, err := chain.Backend.Client().HeaderByNumber(ctx, updated.BlockNumber)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := history[1]; e.DID != "alice-1" || e.Operator != alice.From() || e.URI != "https://example.com/a1" ||
+		e.Hash != (common.Hash{1}) || e.TxHash != updated.TxHash || e.BlockHash != header.Hash() || e.Time != header.Time {
+		t.Errorf("unexpected update event %+v", e)
+	}
+
+	owned, err := ix.ByOwner(alice.From())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(owned) != 1 || owned[0].DID != "alice-1" {
+		t.Errorf("unexpected DIDs of alice %+v", owned)
+	}
+
+	events, err := ix.ByOperator(alice.From(), 0, updated.BlockNumber.Uint64())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equal(kinds(events), []Kind{Created, Created, Updated}) {
+		t.Errorf("unexpected events of alice %+v", events)
+	}
+	events, err = ix.Events(created.BlockNumber.Uint64()+1, ^uint64(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equal(kinds(events), []Kind{Created, Created, Updated, Deleted}) || events[0].DID != "bob-1" || events[3].DID != "alice-2" {
+		t.Errorf("unexpected events after the first block %+v", events)
+	}
+}
+
+func TestIndexerAgent(t *testing.T) {
+	ctx := context.Background()
+	chain := registrytest.NewChain(t, 1)
+	agent := chain.NewClient(t, 0)
+	ix := newIndexer(t, chain, "")
+
+	// The owner of a DID created by an agent is the holder, not the operator.
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	holder := crypto.PubkeyToAddress(key.PublicKey)
+	proof, err := agentable.Sign(key, agentable.CreateMessage(chain.Registry, agent.From(), "device", common.Hash{1}, "ipfs://doc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain.Mine(t, agent)(agent.Create(ctx, "device", proof, common.Hash{1}, "ipfs://doc"))
+	syncIndex(t, chain, ix)
+
+	if s, err := ix.State("device"); err != nil || s.Owner != holder {
+		t.Errorf("unexpected state %+v, %v, want owner %s", s, err, holder)
+	}
+	if owned, err := ix.ByOwner(holder); err != nil || len(owned) != 1 {
+		t.Errorf("unexpected DIDs of the holder %+v, %v", owned, err)
+	}
+	if events, err := ix.ByOperator(agent.From(), 0, ^uint64(0)); err != nil || len(events) != 1 || events[0].Owner != holder {
+		t.Errorf("unexpected events of the agent %+v, %v", events, err)
+	}
+}
+
+func TestIndexerConfirmations(t *testing.T) {
+	ctx := context.Background()
+	chain := registrytest.NewChain(t, 1)
+	c := chain.NewClient(t, 0)
+	ix := newIndexer(t, chain, "", WithConfirmations(3))
+
+	chain.Mine(t, c)(c.Create(ctx, "device", nil, common.Hash{1}, "ipfs://doc"))
+	for i := 0; i < 2; i++ {
+		syncIndex(t, chain, ix)
+		if _, err := ix.State("device"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("DID indexed with %d confirmations: %v", i+1, err)
+		}
+		chain.Backend.Commit()
+	}
+	syncIndex(t, chain, ix)
+	if _, err := ix.State("device"); err != nil {
+		t.Fatalf("DID not indexed with 3 confirmations: %v", err)
+	}
+}
+
+func TestIndexerReorg(t *testing.T) {
+	ctx := context.Background()
+	chain := registrytest.NewChain(t, 2)
+	ix := newIndexer(t, chain, "")
+
+	c := chain.NewClient(t, 0)
+	base := chain.Mine(t, c)(c.Create(ctx, "kept", nil, common.Hash{1}, "ipfs://kept"))
+	chain.Mine(t, c)(c.UpdateHash(ctx, "kept", nil, common.Hash{2}))
+	chain.Mine(t, c)(c.Create(ctx, "dropped", nil, common.Hash{3}, "ipfs://dropped"))
+	chain.Backend.Commit()
+	syncIndex(t, chain, ix)
+	if _, err := ix.State("dropped"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Replace the blocks after the creation of "kept" with a longer chain.
+	if err := chain.Backend.Fork(base.BlockHash); err != nil {
+		t.Fatal(err)
+	}
+	other := chain.NewClient(t, 1)
+	chain.Mine(t, other)(other.Create(ctx, "replacement", nil, common.Hash{4}, "ipfs://replacement"))
+	for i := 0; i < 3; i++ {
+		chain.Backend.Commit()
+	}
+	syncIndex(t, chain, ix)
+
+	if _, err := ix.State("dropped"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the reorganized DID to be gone, got %v", err)
+	}
+	if s, err := ix.State("kept"); err != nil || s.Hash != (common.Hash{1}) || s.Updated != base.BlockNumber.Uint64() {
+		t.Errorf("expected the state before the reorganized update, got %+v, %v", s, err)
+	}
+	if history, err := ix.History("kept"); err != nil || !equal(kinds(history), []Kind{Created}) {
+		t.Errorf("unexpected history after the reorg %+v, %v", history, err)
+	}
+	if s, err := ix.State("replacement"); err != nil || s.Owner != other.From() {
+		t.Errorf("unexpected state of the replacement DID %+v, %v", s, err)
+	}
+	events, err := ix.Events(0, ^uint64(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[1].DID != "replacement" {
+		t.Errorf("unexpected events after the reorg %+v", events)
+	}
+}
+
+func TestIndexerResume(t *testing.T) {
+	ctx := context.Background()
+	chain := registrytest.NewChain(t, 1)
+	c := chain.NewClient(t, 0)
+	path := t.TempDir()
+
+	ix := newIndexer(t, chain, path)
+	chain.Mine(t, c)(c.Create(ctx, "device", nil, common.Hash{1}, "ipfs://doc"))
+	syncIndex(t, chain, ix)
+	number, _, err := ix.Checkpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ix.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	chain.Mine(t, c)(c.UpdateHash(ctx, "device", nil, common.Hash{2}))
+
+	ix = newIndexer(t, chain, path)
+	if resumed, _, err := ix.Checkpoint(); err != nil || resumed != number {
+		t.Fatalf("checkpoint at %d after restart, %v, want %d", resumed, err, number)
+	}
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan error)
+	go func() { done <- ix.Run(runCtx) }()
+	defer func() {
+		cancel()
+		if err := <-done; !errors.Is(err, context.Canceled) {
+			t.Errorf("unexpected Run error %v", err)
+		}
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		history, err := ix.History("device")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if equal(kinds(history), []Kind{Created, Updated}) {
+			break
+		}
+		if len(history) > 2 || time.Now().After(deadline) {
+			t.Fatalf("unexpected history %+v", history)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
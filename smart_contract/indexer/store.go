@@ -0,0 +1,304 @@
+package indexer
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// Key prefixes used in the store. DIDs are keyed by the keccak256 hash of
+// their identifier, the only form DIDUpdated and DIDDeleted carry.
+var (
+	keyCheckpoint  = []byte("c")  // c => checkpoint
+	prefixState    = []byte("s/") // s/<did hash> => State
+	prefixEvent    = []byte("e/") // e/<did hash><block><index> => Event
+	prefixBlock    = []byte("l/") // l/<block><index> => did hash
+	prefixOperator = []byte("p/") // p/<operator><block><index> => did hash
+	prefixOwner    = []byte("o/") // o/<owner><did hash> => nothing
+	prefixHash     = []byte("b/") // b/<block> => hash of the block, for blocks with events
+)
+
+// ErrNotFound is returned when a DID was never indexed.
+var ErrNotFound = errors.New("DID not found")
+
+// Kind is the kind of a registry event.
+type Kind string
+
+// Set of event kinds.
+const (
+	Created Kind = "created"
+	Updated Kind = "updated"
+	Deleted Kind = "deleted"
+)
+
+// Event is an indexed registry event.
+type Event struct {
+	Kind      Kind           `json:"kind"`
+	DID       string         `json:"did"`
+	Operator  common.Address `json:"operator"`      // Sender of the operation
+	Owner     common.Address `json:"owner"`         // Set on creation
+	Hash      common.Hash    `json:"hash"`          // Document hash after the operation
+	URI       string         `json:"uri,omitempty"` // Document URI after the operation
+	Block     uint64         `json:"block"`
+	BlockHash common.Hash    `json:"block_hash"`
+	Time      uint64         `json:"time"` // Timestamp of the block
+	TxHash    common.Hash    `json:"tx_hash"`
+	Index     uint           `json:"index"` // Index of the log in the block
+}
+
+// State is the state of a DID after the indexed events.
+type State struct {
+	DID     string         `json:"did"`
+	Owner   common.Address `json:"owner"`
+	Hash    common.Hash    `json:"hash"`
+	URI     string         `json:"uri"`
+	Created uint64         `json:"created"` // Block of the last creation
+	Updated uint64         `json:"updated"` // Block of the last event
+	Deleted bool           `json:"deleted,omitempty"`
+}
+
+// apply returns the state after an event.
+func (s State) apply(e Event) State {
+	switch e.Kind {
+	case Created:
+		return State{DID: e.DID, Owner: e.Owner, Hash: e.Hash, URI: e.URI, Created: e.Block, Updated: e.Block}
+	case Updated:
+		s.Hash, s.URI = e.Hash, e.URI
+	case Deleted:
+		s.Deleted = true
+	}
+	s.Updated = e.Block
+	return s
+}
+
+// checkpoint is the last indexed block.
+type checkpoint struct {
+	Number uint64      `json:"number"`
+	Hash   common.Hash `json:"hash"`
+}
+
+// State returns the current state of a DID, which may be deleted. It returns
+// ErrNotFound if the DID was never created.
+func (ix *Indexer) State(did string) (State, error) {
+	return ix.state(didKey(did))
+}
+
+// History returns the events of a DID, oldest first.
+func (ix *Indexer) History(did string) ([]Event, error) {
+	var events []Event
+
+	iter := ix.db.NewIterator(util.BytesPrefix(join(prefixEvent, didKey(did))), nil)
+	defer iter.Release()
+
+	for iter.Next() {
+		var e Event
+		if err := json.Unmarshal(iter.Value(), &e); err != nil {
+			return nil, fmt.Errorf("decoding event: %w", err)
+		}
+		events = append(events, e)
+	}
+
+	return events, iter.Error()
+}
+
+// ByOwner returns the DIDs an account owns and that aren't deleted.
+func (ix *Indexer) ByOwner(owner common.Address) ([]State, error) {
+	prefix := join(prefixOwner, owner[:])
+
+	var states []State
+
+	iter := ix.db.NewIterator(util.BytesPrefix(prefix), nil)
+	defer iter.Release()
+
+	for iter.Next() {
+		s, err := ix.state(iter.Key()[len(prefix):])
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, s)
+	}
+
+	return states, iter.Error()
+}
+
+// ByOperator returns the events an account sent between two blocks,
+// inclusive, oldest first.
+func (ix *Indexer) ByOperator(operator common.Address, from, to uint64) ([]Event, error) {
+	prefix := join(prefixOperator, operator[:])
+	return ix.events(prefix, from, to)
+}
+
+// Events returns the events between two blocks, inclusive, oldest first.
+func (ix *Indexer) Events(from, to uint64) ([]Event, error) {
+	return ix.events(prefixBlock, from, to)
+}
+
+// Checkpoint returns the number and hash of the last indexed block. The hash
+// is zero when nothing was indexed yet.
+func (ix *Indexer) Checkpoint() (uint64, common.Hash, error) {
+	cp, err := ix.checkpoint()
+	return cp.Number, cp.Hash, err
+}
+
+// events returns the events of an index keyed by prefix, block and log index.
+func (ix *Indexer) events(prefix []byte, from, to uint64) ([]Event, error) {
+	if from > to {
+		return nil, nil
+	}
+
+	var events []Event
+
+	r := util.Range{Start: join(prefix, be64(from))}
+	if to < ^uint64(0) {
+		r.Limit = join(prefix, be64(to+1))
+	} else {
+		r.Limit = util.BytesPrefix(prefix).Limit
+	}
+	iter := ix.db.NewIterator(&r, nil)
+	defer iter.Release()
+
+	for iter.Next() {
+		pos := iter.Key()[len(prefix):]
+		e, err := ix.event(join(prefixEvent, iter.Value(), pos))
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	return events, iter.Error()
+}
+
+func (ix *Indexer) state(key []byte) (State, error) {
+	data, err := ix.db.Get(join(prefixState, key), nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return State{}, ErrNotFound
+	}
+	if err != nil {
+		return State{}, fmt.Errorf("reading state: %w", err)
+	}
+
+	var s State
+	if err := json.Unmarshal(data, &s); err != nil {
+		return State{}, fmt.Errorf("decoding state: %w", err)
+	}
+
+	return s, nil
+}
+
+func (ix *Indexer) event(key []byte) (Event, error) {
+	data, err := ix.db.Get(key, nil)
+	if err != nil {
+		return Event{}, fmt.Errorf("reading event: %w", err)
+	}
+
+	var e Event
+	if err := json.Unmarshal(data, &e); err != nil {
+		return Event{}, fmt.Errorf("decoding event: %w", err)
+	}
+
+	return e, nil
+}
+
+func (ix *Indexer) checkpoint() (checkpoint, error) {
+	var cp checkpoint
+
+	data, err := ix.db.Get(keyCheckpoint, nil)
+	switch {
+	case errors.Is(err, leveldb.ErrNotFound):
+		return cp, nil
+	case err != nil:
+		return cp, fmt.Errorf("reading checkpoint: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return cp, fmt.Errorf("decoding checkpoint: %w", err)
+	}
+
+	return cp, nil
+}
+
+// putEvent writes an event and its index entries.
+func putEvent(batch *leveldb.Batch, key []byte, e Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("encoding event: %w", err)
+	}
+
+	pos := position(e.Block, e.Index)
+	batch.Put(join(prefixEvent, key, pos), data)
+	batch.Put(join(prefixBlock, pos), key)
+	batch.Put(join(prefixOperator, e.Operator[:], pos), key)
+	batch.Put(join(prefixHash, be64(e.Block)), e.BlockHash[:])
+
+	return nil
+}
+
+// deleteEvent removes an event and its index entries.
+func deleteEvent(batch *leveldb.Batch, key []byte, e Event) {
+	pos := position(e.Block, e.Index)
+	batch.Delete(join(prefixEvent, key, pos))
+	batch.Delete(join(prefixBlock, pos))
+	batch.Delete(join(prefixOperator, e.Operator[:], pos))
+	batch.Delete(join(prefixHash, be64(e.Block)))
+}
+
+// putState replaces the state of a DID and its owner index entry.
+func putState(batch *leveldb.Batch, key []byte, old, s *State) error {
+	if old != nil && !old.Deleted {
+		batch.Delete(join(prefixOwner, old.Owner[:], key))
+	}
+	if s == nil {
+		batch.Delete(join(prefixState, key))
+		return nil
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("encoding state: %w", err)
+	}
+
+	batch.Put(join(prefixState, key), data)
+	if !s.Deleted {
+		batch.Put(join(prefixOwner, s.Owner[:], key), nil)
+	}
+
+	return nil
+}
+
+func putCheckpoint(batch *leveldb.Batch, cp checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return fmt.Errorf("encoding checkpoint: %w", err)
+	}
+
+	batch.Put(keyCheckpoint, data)
+
+	return nil
+}
+
+func didKey(did string) []byte {
+	return hashDID(did).Bytes()
+}
+
+// position orders events by block and log index.
+func position(block uint64, index uint) []byte {
+	return binary.BigEndian.AppendUint32(be64(block), uint32(index))
+}
+
+func be64(n uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, n)
+}
+
+func join(parts ...[]byte) []byte {
+	var key []byte
+	for _, p := range parts {
+		key = append(key, p...)
+	}
+	return key
+}
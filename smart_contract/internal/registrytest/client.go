@@ -0,0 +1,41 @@
+package registrytest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"EncrypteDL/EncryrpteID/smart_contract/registry"
+)
+
+// NewClient returns a client of the registry sending from an account and
+// polling for receipts every 10ms, configured further by opts.
+func (c *Chain) NewClient(t testing.TB, account int, opts ...registry.Option) *registry.Client {
+	t.Helper()
+	opts = append([]registry.Option{registry.WithKey(c.Accounts[account].Key), registry.WithPollInterval(10 * time.Millisecond)}, opts...)
+	client, err := registry.New(c.Backend.Client(), c.Registry, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// Mine returns a function committing a block with the transaction sent by a
+// client and waiting for its receipt. It takes the results of the client
+// methods sending transactions.
+func (c *Chain) Mine(t testing.TB, client *registry.Client) func(*types.Transaction, error) *types.Receipt {
+	return func(tx *types.Transaction, err error) *types.Receipt {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Backend.Commit()
+		receipt, err := client.Wait(context.Background(), tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return receipt
+	}
+}
@@ -0,0 +1,41 @@
+package registrytest
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// The output includes the contracts imported by the registry, Agentable
+// among them.
+//go:generate solc --base-path ../.. --metadata-hash none --abi --bin --overwrite -o testdata testdata/Registry.sol
+
+// compiled returns the init code of a contract compiled by solc into
+// testdata.
+func compiled(t testing.TB, name string) []byte {
+	t.Helper()
+
+	_, file, _, _ := runtime.Caller(0)
+	bin, err := os.ReadFile(filepath.Join(filepath.Dir(file), "testdata", name+".bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := hex.DecodeString(strings.TrimSpace(string(bin)))
+	if err != nil {
+		t.Fatalf("decoding %s.bin: %v", name, err)
+	}
+	return code
+}
+
+// DeployAgentable deploys Agentable.sol on its own, so the authorization
+// messages built in Go can be checked against the contract as written rather
+// than as inherited by the registry.
+func (c *Chain) DeployAgentable(t testing.TB) common.Address {
+	t.Helper()
+	return c.deploy(t, compiled(t, "Agentable"))
+}
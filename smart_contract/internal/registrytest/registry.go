@@ -0,0 +1,123 @@
+// Package registrytest provides a DID registry contract for tests, deployed on
+// go-ethereum's simulated backend.
+//
+// The contract is testdata/Registry.sol, implementing the IDID and
+// SelfManagedDeviceDID interfaces of smart_contract/contract along with
+// Agentable. It's compiled by solc into testdata with go generate, and the
+// output is committed so the tests don't need solc. The tests check its ABI
+// against the Solidity declarations and the bindings.
+package registrytest
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+)
+
+// Revert reasons of the registry, as returned in Error(string) revert data.
+const (
+	ReasonEmptyDID     = "empty DID"
+	ReasonExists       = "DID already exists"
+	ReasonNotFound     = "DID not found"
+	ReasonUnauthorized = "not the DID owner"
+	ReasonBadProof     = "invalid proof"
+)
+
+// Account is a funded account of a test chain.
+type Account struct {
+	Key     *ecdsa.PrivateKey
+	Address common.Address
+}
+
+// Chain is a simulated chain with the registry deployed.
+type Chain struct {
+	Backend  *simulated.Backend
+	Accounts []Account
+	Registry common.Address
+}
+
+// NewChain starts a simulated chain with n funded accounts and deploys the
+// registry from the first one. The chain is closed with the test.
+func NewChain(t testing.TB, n int) *Chain {
+	t.Helper()
+
+	c := new(Chain)
+	alloc := make(types.GenesisAlloc)
+	for i := 0; i < n; i++ {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr := crypto.PubkeyToAddress(key.PublicKey)
+		alloc[addr] = types.Account{Balance: new(big.Int).Lsh(big.NewInt(1), 100)}
+		c.Accounts = append(c.Accounts, Account{Key: key, Address: addr})
+	}
+	c.Backend = simulated.NewBackend(alloc)
+	t.Cleanup(func() { c.Backend.Close() })
+
+	c.Registry = c.deploy(t, compiled(t, "Registry"))
+	return c
+}
+
+// deploy deploys a contract from the first account.
+func (c *Chain) deploy(t testing.TB, code []byte) common.Address {
+	t.Helper()
+
+	auth, err := bind.NewKeyedTransactorWithChainID(c.Accounts[0].Key, big.NewInt(1337))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := c.Backend.Client()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	nonce, err := client.PendingNonceAt(ctx, auth.From)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tip, err := client.SuggestGasTipCap(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gas, err := client.EstimateGas(ctx, ethereum.CallMsg{From: auth.From, Data: code})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := auth.Signer(auth.From, types.NewTx(&types.DynamicFeeTx{
+		ChainID:   big.NewInt(1337),
+		Nonce:     nonce,
+		GasTipCap: tip,
+		GasFeeCap: new(big.Int).Add(tip, new(big.Int).Mul(head.BaseFee, big.NewInt(2))),
+		Gas:       gas,
+		Data:      code,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.SendTransaction(ctx, tx); err != nil {
+		t.Fatal(err)
+	}
+	c.Backend.Commit()
+
+	receipt, err := client.TransactionReceipt(ctx, tx.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		t.Fatal("contract deployment failed")
+	}
+	return receipt.ContractAddress
+}
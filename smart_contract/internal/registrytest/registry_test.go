@@ -0,0 +1,177 @@
+package registrytest
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+
+	"EncrypteDL/EncryrpteID/smart_contract/bindings"
+)
+
+// declaration is a function or event declared in Solidity.
+type declaration struct {
+	sig     string
+	indexed []bool // Indexed parameters of events
+}
+
+var (
+	literalPattern  = regexp.MustCompile(`"(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'|//.*|(?s:/\*.*?\*/)`)
+	contractPattern = regexp.MustCompile(`(?:interface|contract)\s+(\w+)[^{]*\{`)
+	functionPattern = regexp.MustCompile(`function\s+(\w+)\s*\(([^)]*)\)([^{;]*)`)
+	eventPattern    = regexp.MustCompile(`event\s+(\w+)\s*\(([^)]*)\)`)
+)
+
+// parseSolidity returns the external functions and the events of the
+// contracts and interfaces declared in a Solidity file, by contract name.
+func parseSolidity(t *testing.T, name string) (functions, events map[string][]declaration) {
+	t.Helper()
+	src, err := os.ReadFile(filepath.Join("..", "..", "contract", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Strings and comments could pass for declarations.
+	src = literalPattern.ReplaceAll(src, nil)
+
+	signature := func(name, params string) declaration {
+		d := declaration{sig: name + "("}
+		for i, param := range strings.Split(params, ",") {
+			fields := strings.Fields(param)
+			if len(fields) == 0 {
+				continue
+			}
+			if i > 0 {
+				d.sig += ","
+			}
+			typ := fields[0]
+			if typ == "uint" || typ == "int" {
+				typ += "256"
+			}
+			d.sig += typ
+			d.indexed = append(d.indexed, slices.Contains(fields[1:], "indexed"))
+		}
+		d.sig += ")"
+		return d
+	}
+
+	functions, events = make(map[string][]declaration), make(map[string][]declaration)
+	bounds := contractPattern.FindAllSubmatchIndex(src, -1)
+	for i, b := range bounds {
+		contract := string(src[b[2]:b[3]])
+		body := src[b[1]:]
+		if i+1 < len(bounds) {
+			body = src[b[1]:bounds[i+1][0]]
+		}
+		for _, m := range functionPattern.FindAllSubmatch(body, -1) {
+			modifiers := strings.Fields(string(m[3]))
+			if slices.Contains(modifiers, "public") || slices.Contains(modifiers, "external") {
+				functions[contract] = append(functions[contract], signature(string(m[1]), string(m[2])))
+			}
+		}
+		for _, m := range eventPattern.FindAllSubmatch(body, -1) {
+			events[contract] = append(events[contract], signature(string(m[1]), string(m[2])))
+		}
+	}
+	return functions, events
+}
+
+// TestInterfaces checks the bindings and the compiled registry against the
+// Solidity declarations, so that a selector or topic drifting from them is
+// caught.
+func TestInterfaces(t *testing.T) {
+	metadata := map[string]*bind.MetaData{
+		"IDID":                 bindings.IDIDMetaData,
+		"SelfManagedDeviceDID": bindings.SelfManagedDeviceDIDMetaData,
+		"Agentable":            bindings.AgentableMetaData,
+	}
+
+	var declared []string
+	for _, file := range []string{"IDID.sol", "SelfManagedDeviceDID.sol", "Agentable.sol"} {
+		functions, events := parseSolidity(t, file)
+		for contract, md := range metadata {
+			if functions[contract] == nil && events[contract] == nil {
+				continue
+			}
+			parsed, err := md.GetAbi()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(parsed.Methods) != len(functions[contract]) || len(parsed.Events) != len(events[contract]) {
+				t.Errorf("%s: the ABI has %d functions and %d events, the Solidity declarations %d and %d",
+					contract, len(parsed.Methods), len(parsed.Events), len(functions[contract]), len(events[contract]))
+			}
+			for _, fn := range functions[contract] {
+				if !slices.ContainsFunc(mapValues(parsed.Methods), func(m abi.Method) bool { return m.Sig == fn.sig }) {
+					t.Errorf("%s: function %s missing from the ABI", contract, fn.sig)
+				}
+				declared = append(declared, fn.sig)
+			}
+			for _, ev := range events[contract] {
+				i := slices.IndexFunc(mapValues(parsed.Events), func(e abi.Event) bool { return e.Sig == ev.sig })
+				if i < 0 {
+					t.Errorf("%s: event %s missing from the ABI", contract, ev.sig)
+					continue
+				}
+				var indexed []bool
+				for _, in := range mapValues(parsed.Events)[i].Inputs {
+					indexed = append(indexed, in.Indexed)
+				}
+				if !slices.Equal(indexed, ev.indexed) {
+					t.Errorf("%s: event %s indexes %v in the ABI, %v in Solidity", contract, ev.sig, indexed, ev.indexed)
+				}
+			}
+		}
+	}
+
+	// The registry implements every declared function, and only them.
+	file, err := os.Open(filepath.Join("testdata", "Registry.abi"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	registry, err := abi.JSON(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var implemented []string
+	for _, m := range registry.Methods {
+		implemented = append(implemented, m.Sig)
+	}
+	slices.Sort(declared)
+	slices.Sort(implemented)
+	if !slices.Equal(declared, implemented) {
+		t.Errorf("declared functions %v, implemented %v", declared, implemented)
+	}
+
+	parsed, err := bindings.IDIDMetaData.GetAbi()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(registry.Events) != len(parsed.Events) {
+		t.Errorf("the registry has %d events, IDID %d", len(registry.Events), len(parsed.Events))
+	}
+	for name, ev := range parsed.Events {
+		if registry.Events[name].ID != ev.ID {
+			t.Errorf("event %s of the registry doesn't match the ABI event %s", registry.Events[name].Sig, ev.Sig)
+		}
+	}
+}
+
+// mapValues returns the values of a map in key order.
+func mapValues[V any](m map[string]V) []V {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	values := make([]V, len(keys))
+	for i, k := range keys {
+		values[i] = m[k]
+	}
+	return values
+}
@@ -0,0 +1,96 @@
+pragma solidity ^0.8;
+
+import "../../../contract/IDID.sol";
+import "../../../contract/Agentable.sol";
+
+// Registry is the DID registry the tests run against. It implements IDID and
+// the functions of SelfManagedDeviceDID, whose interface is still written for
+// Solidity 0.4 and can't be inherited.
+//
+// An empty proof makes the sender act for itself: it owns the DIDs it creates
+// and only the owner can update or delete a DID. Otherwise, the proof is the
+// signature of the Agentable authorization message of the operation, by which
+// the holder authorizes the sender to act on its behalf. The update message
+// carries the resulting hash and URI of the DID.
+contract Registry is IDID, Agentable {
+
+    struct Record {
+        address owner;
+        bytes32 hash;
+        string uri;
+    }
+
+    // Records by keccak256 hash of their DID
+    mapping(bytes32 => Record) private records;
+
+    function createDID(string calldata uuid, bytes calldata proof, bytes32 hash, string calldata uri) external {
+        require(bytes(uuid).length > 0, "empty DID");
+        Record storage record = records[keccak256(bytes(uuid))];
+        require(record.owner == address(0), "DID already exists");
+
+        record.owner = actingFor(proof, getCreateAuthMessage(bytes(uuid), hash, bytes(uri), msg.sender));
+        record.hash = hash;
+        record.uri = uri;
+        emit DIDCreated(msg.sender, uuid, hash, uri);
+    }
+
+    function deleteDID(string calldata uuid, bytes calldata proof) external {
+        Record storage record = records[keccak256(bytes(uuid))];
+        authorize(record, actingFor(proof, getDeleteAuthMessage(bytes(uuid), msg.sender)));
+
+        delete records[keccak256(bytes(uuid))];
+        emit DIDDeleted(msg.sender, uuid);
+    }
+
+    function updateHash(string calldata uuid, bytes calldata proof, bytes32 hash) external {
+        Record storage record = records[keccak256(bytes(uuid))];
+        authorize(record, actingFor(proof, getUpdateAuthMessage(bytes(uuid), hash, bytes(record.uri), msg.sender)));
+
+        record.hash = hash;
+        emit DIDUpdated(msg.sender, uuid, hash, record.uri);
+    }
+
+    function updateURI(string calldata uuid, bytes calldata proof, string calldata uri) external {
+        Record storage record = records[keccak256(bytes(uuid))];
+        authorize(record, actingFor(proof, getUpdateAuthMessage(bytes(uuid), record.hash, bytes(uri), msg.sender)));
+
+        record.uri = uri;
+        emit DIDUpdated(msg.sender, uuid, record.hash, uri);
+    }
+
+    function getHash(bytes calldata did) external view returns (bytes32) {
+        return records[keccak256(did)].hash;
+    }
+
+    function getHash(string calldata did) external view returns (bytes32) {
+        return records[keccak256(bytes(did))].hash;
+    }
+
+    function getURI(bytes calldata did) external view returns (bytes memory) {
+        return bytes(records[keccak256(did)].uri);
+    }
+
+    function getURI(string calldata did) external view returns (string memory) {
+        return records[keccak256(bytes(did))].uri;
+    }
+
+    function getOwner(bytes calldata did) external view returns (address) {
+        return records[keccak256(did)].owner;
+    }
+
+    // actingFor returns the account the sender acts for: itself without
+    // proof, else the signer of the authorization message.
+    function actingFor(bytes calldata proof, bytes memory message) private view returns (address) {
+        if (proof.length == 0) {
+            return msg.sender;
+        }
+        address signer = getSigner(message, proof);
+        require(signer != address(0), "invalid proof");
+        return signer;
+    }
+
+    function authorize(Record storage record, address actor) private view {
+        require(record.owner != address(0), "DID not found");
+        require(record.owner == actor, "not the DID owner");
+    }
+}
@@ -0,0 +1,349 @@
+// Package registry is a client of the on-chain DID registry, the contract
+// implementing the IDID and SelfManagedDeviceDID interfaces. It reads DID
+// records and sends the transactions creating, updating and deleting them,
+// taking care of nonces, gas and receipts.
+package registry
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"EncrypteDL/EncryrpteID/smart_contract/bindings"
+)
+
+// Set of errors returned by the client.
+var (
+	ErrNotFound = errors.New("DID not found")
+	ErrNoSigner = errors.New("no signing key configured")
+	ErrReverted = errors.New("transaction reverted")
+)
+
+// Backend is the connection to the chain, such as an *ethclient.Client or
+// the client of a simulated backend.
+type Backend interface {
+	bind.ContractBackend
+	bind.DeployBackend
+	ChainID(ctx context.Context) (*big.Int, error)
+}
+
+// Record is the state of a DID in the registry.
+type Record struct {
+	Owner common.Address
+	Hash  common.Hash // Hash of the DID document
+	URI   string      // Location of the DID document
+}
+
+// Client reads and writes the DIDs of a registry contract.
+type Client struct {
+	backend  Backend
+	address  common.Address
+	idid     *bindings.IDIDCaller
+	device   *bindings.SelfManagedDeviceDIDTransactor
+	filterer *bindings.IDIDFilterer
+
+	key           *ecdsa.PrivateKey
+	from          common.Address
+	gasLimit      uint64
+	gasMargin     uint64
+	confirmations uint64
+	pollInterval  time.Duration
+
+	mu         sync.Mutex // Serializes transactions, to allocate nonces in order
+	chainID    *big.Int
+	nonce      uint64
+	nonceKnown bool
+}
+
+// Option configures a Client at construction time.
+type Option func(*Client)
+
+// WithKey sets the key signing transactions. Without a key, the client can
+// only read the registry.
+func WithKey(key *ecdsa.PrivateKey) Option {
+	return func(c *Client) {
+		c.key = key
+		c.from = crypto.PubkeyToAddress(key.PublicKey)
+	}
+}
+
+// WithGasLimit sets the gas limit of transactions, instead of estimating it.
+func WithGasLimit(gas uint64) Option {
+	return func(c *Client) {
+		c.gasLimit = gas
+	}
+}
+
+// WithGasMargin sets the percentage added to the estimated gas of
+// transactions, 20 by default.
+func WithGasMargin(percent uint64) Option {
+	return func(c *Client) {
+		c.gasMargin = percent
+	}
+}
+
+// WithConfirmations sets the number of blocks, including its own, a
+// transaction must be buried under before Wait returns. The default of 1
+// returns as soon as it's mined.
+func WithConfirmations(n uint64) Option {
+	return func(c *Client) {
+		c.confirmations = n
+	}
+}
+
+// WithPollInterval sets how often Wait checks for receipts, every second by
+// default.
+func WithPollInterval(d time.Duration) Option {
+	return func(c *Client) {
+		c.pollInterval = d
+	}
+}
+
+// New constructs a Client of the registry deployed at address.
+func New(backend Backend, address common.Address, opts ...Option) (*Client, error) {
+	idid, err := bindings.NewIDIDCaller(address, backend)
+	if err != nil {
+		return nil, err
+	}
+	filterer, err := bindings.NewIDIDFilterer(address, backend)
+	if err != nil {
+		return nil, err
+	}
+	device, err := bindings.NewSelfManagedDeviceDIDTransactor(address, backend)
+	if err != nil {
+		return nil, err
+	}
+	c := Client{
+		backend:       backend,
+		address:       address,
+		idid:          idid,
+		device:        device,
+		filterer:      filterer,
+		gasMargin:     20,
+		confirmations: 1,
+		pollInterval:  time.Second,
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	if c.confirmations == 0 {
+		return nil, errors.New("confirmations must be greater than 0")
+	}
+	if c.pollInterval <= 0 {
+		return nil, errors.New("poll interval must be greater than 0")
+	}
+	return &c, nil
+}
+
+// Address returns the address of the registry.
+func (c *Client) Address() common.Address {
+	return c.address
+}
+
+// From returns the address sending the transactions, the zero address when
+// no key is configured.
+func (c *Client) From() common.Address {
+	return c.from
+}
+
+// Filterer returns the bindings filtering the events of the registry.
+func (c *Client) Filterer() *bindings.IDIDFilterer {
+	return c.filterer
+}
+
+// Record returns the state of a DID at a block, the latest one when block is
+// nil. It returns ErrNotFound if the DID doesn't exist at that block.
+func (c *Client) Record(ctx context.Context, id string, block *big.Int) (Record, error) {
+	if block == nil {
+		// All the fields are read at the same block, a new one could be mined
+		// in between otherwise.
+		head, err := c.backend.HeaderByNumber(ctx, nil)
+		if err != nil {
+			return Record{}, fmt.Errorf("reading head: %w", err)
+		}
+		block = head.Number
+	}
+	opts := &bind.CallOpts{Context: ctx, BlockNumber: block}
+
+	owner, err := c.idid.GetOwner(opts, []byte(id))
+	if err != nil {
+		return Record{}, fmt.Errorf("reading owner of %s: %w", id, err)
+	}
+	if owner == (common.Address{}) {
+		return Record{}, ErrNotFound
+	}
+	hash, err := c.idid.GetHash(opts, []byte(id))
+	if err != nil {
+		return Record{}, fmt.Errorf("reading hash of %s: %w", id, err)
+	}
+	uri, err := c.idid.GetURI(opts, []byte(id))
+	if err != nil {
+		return Record{}, fmt.Errorf("reading URI of %s: %w", id, err)
+	}
+	return Record{Owner: owner, Hash: hash, URI: string(uri)}, nil
+}
+
+// Create sends the transaction creating a DID. The proof authorizes the
+// sender to act on behalf of the holder, empty when the sender is the holder.
+func (c *Client) Create(ctx context.Context, id string, proof []byte, hash common.Hash, uri string) (*types.Transaction, error) {
+	return c.transact(ctx, "createDID", func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return c.device.CreateDID(opts, id, proof, hash, uri)
+	})
+}
+
+// UpdateHash sends the transaction updating the document hash of a DID.
+func (c *Client) UpdateHash(ctx context.Context, id string, proof []byte, hash common.Hash) (*types.Transaction, error) {
+	return c.transact(ctx, "updateHash", func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return c.device.UpdateHash(opts, id, proof, hash)
+	})
+}
+
+// UpdateURI sends the transaction updating the document URI of a DID.
+func (c *Client) UpdateURI(ctx context.Context, id string, proof []byte, uri string) (*types.Transaction, error) {
+	return c.transact(ctx, "updateURI", func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return c.device.UpdateURI(opts, id, proof, uri)
+	})
+}
+
+// Delete sends the transaction deleting a DID.
+func (c *Client) Delete(ctx context.Context, id string, proof []byte) (*types.Transaction, error) {
+	return c.transact(ctx, "deleteDID", func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return c.device.DeleteDID(opts, id, proof)
+	})
+}
+
+// transact signs and sends a registry transaction built by the bindings with
+// send. Calls that would revert are reported before being sent, as gas
+// estimation fails. The bindings set an EIP-1559 fee cap of twice the current
+// base fee plus the tip, or the suggested gas price on chains without base
+// fee.
+func (c *Client) transact(ctx context.Context, method string, send func(*bind.TransactOpts) (*types.Transaction, error)) (*types.Transaction, error) {
+	if c.key == nil {
+		return nil, ErrNoSigner
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.chainID == nil {
+		chainID, err := c.backend.ChainID(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("reading chain ID: %w", err)
+		}
+		c.chainID = chainID
+	}
+	if !c.nonceKnown {
+		nonce, err := c.backend.PendingNonceAt(ctx, c.from)
+		if err != nil {
+			return nil, fmt.Errorf("reading nonce: %w", err)
+		}
+		c.nonce, c.nonceKnown = nonce, true
+	}
+	opts, err := bind.NewKeyedTransactorWithChainID(c.key, c.chainID)
+	if err != nil {
+		return nil, err
+	}
+	opts.Context = ctx
+	opts.Nonce = new(big.Int).SetUint64(c.nonce)
+	opts.GasLimit = c.gasLimit
+	if opts.GasLimit == 0 {
+		// Build the transaction without sending it to get the estimated gas,
+		// then send it with the margin added.
+		opts.NoSend = true
+		tx, err := send(opts)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", method, revertError(err))
+		}
+		opts.GasLimit = tx.Gas() + tx.Gas()*c.gasMargin/100
+		opts.NoSend = false
+	}
+	tx, err := send(opts)
+	if err != nil {
+		// The pending nonce may have moved, e.g. if the key is shared
+		c.nonceKnown = false
+		return nil, fmt.Errorf("sending %s: %w", method, err)
+	}
+	c.nonce++
+	return tx, nil
+}
+
+// Wait waits until a transaction is mined with the configured confirmations
+// and returns its receipt. A receipt is checked again after every block, in
+// case the transaction was reorganized out of the chain. It returns
+// ErrReverted along with the receipt if the transaction failed.
+func (c *Client) Wait(ctx context.Context, tx *types.Transaction) (*types.Receipt, error) {
+	ticker := time.NewTicker(c.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		receipt, err := c.backend.TransactionReceipt(ctx, tx.Hash())
+		switch {
+		case err == nil:
+			head, err := c.backend.HeaderByNumber(ctx, nil)
+			if err != nil {
+				return nil, fmt.Errorf("reading head: %w", err)
+			}
+			// The head is behind the receipt on a lagging node or after a
+			// reorganization.
+			if head.Number.Cmp(receipt.BlockNumber) < 0 ||
+				new(big.Int).Sub(head.Number, receipt.BlockNumber).Uint64()+1 < c.confirmations {
+				break
+			}
+			if receipt.Status != types.ReceiptStatusSuccessful {
+				return receipt, c.failure(ctx, tx, receipt)
+			}
+			return receipt, nil
+		case !errors.Is(err, ethereum.NotFound):
+			return nil, fmt.Errorf("reading receipt of %s: %w", tx.Hash(), err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// failure explains why a mined transaction failed, replaying it on the state
+// it was executed on to get the revert reason.
+func (c *Client) failure(ctx context.Context, tx *types.Transaction, receipt *types.Receipt) error {
+	parent := new(big.Int).Sub(receipt.BlockNumber, big.NewInt(1))
+	msg := ethereum.CallMsg{From: c.from, To: tx.To(), Gas: tx.Gas(), Data: tx.Data()}
+	if _, err := c.backend.CallContract(ctx, msg, parent); err != nil {
+		return fmt.Errorf("%s: %w", tx.Hash(), revertError(err))
+	}
+	return fmt.Errorf("%s: %w", tx.Hash(), ErrReverted)
+}
+
+// revertError converts an error carrying revert data to an ErrReverted with
+// the revert reason. Other errors are returned as they are.
+func revertError(err error) error {
+	var data interface{ ErrorData() interface{} }
+	if !errors.As(err, &data) {
+		return err
+	}
+	s, ok := data.ErrorData().(string)
+	if !ok {
+		return err
+	}
+	revert, decodeErr := hexutil.Decode(s)
+	if decodeErr != nil {
+		return err
+	}
+	if reason, unpackErr := abi.UnpackRevert(revert); unpackErr == nil {
+		return fmt.Errorf("%w: %s", ErrReverted, reason)
+	}
+	return ErrReverted
+}
@@ -0,0 +1,314 @@
+package registry_test
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"EncrypteDL/EncryrpteID/smart_contract/bindings"
+	"EncrypteDL/EncryrpteID/smart_contract/internal/registrytest"
+	"EncrypteDL/EncryrpteID/smart_contract/registry"
+)
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	chain := registrytest.NewChain(t, 1)
+	c := chain.NewClient(t, 0)
+
+	const id = "6b1d3f0e-3d5c-4a43-9e0c-2f1d8e6a7b90"
+	hash := crypto.Keccak256Hash([]byte("document v1"))
+	uri := "ipfs://bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi"
+
+	if _, err := c.Record(ctx, id, nil); !errors.Is(err, registry.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound before creation, got %v", err)
+	}
+	tx, err := c.Create(ctx, id, nil, hash, uri)
+	created := chain.Mine(t, c)(tx, err)
+
+	have, err := c.Record(ctx, id, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := registry.Record{Owner: c.From(), Hash: hash, URI: uri}
+	if have != want {
+		t.Fatalf("unexpected record after creation:\n have %+v\n want %+v", have, want)
+	}
+
+	// The SelfManagedDeviceDID getters take the DID as a string.
+	device, err := bindings.NewSelfManagedDeviceDIDCaller(chain.Registry, chain.Backend.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h, err := device.GetHash(nil, id); err != nil || h != hash {
+		t.Errorf("unexpected getHash(string): %x, %v", h, err)
+	}
+	if u, err := device.GetURI(nil, id); err != nil || u != uri {
+		t.Errorf("unexpected getURI(string): %q, %v", u, err)
+	}
+
+	hash2 := crypto.Keccak256Hash([]byte("document v2"))
+	tx, err = c.UpdateHash(ctx, id, nil, hash2)
+	chain.Mine(t, c)(tx, err)
+	uri2 := "https://example.com/dids/" + id + "/document.json"
+	tx, err = c.UpdateURI(ctx, id, nil, uri2)
+	updated := chain.Mine(t, c)(tx, err)
+
+	want = registry.Record{Owner: c.From(), Hash: hash2, URI: uri2}
+	if have, err := c.Record(ctx, id, nil); err != nil || have != want {
+		t.Fatalf("unexpected record after updates:\n have %+v, %v\n want %+v", have, err, want)
+	}
+	// Past states remain readable at their block.
+	want = registry.Record{Owner: c.From(), Hash: hash, URI: uri}
+	if have, err := c.Record(ctx, id, created.BlockNumber); err != nil || have != want {
+		t.Errorf("unexpected record at creation block:\n have %+v, %v\n want %+v", have, err, want)
+	}
+
+	tx, err = c.Delete(ctx, id, nil)
+	deleted := chain.Mine(t, c)(tx, err)
+	if _, err := c.Record(ctx, id, nil); !errors.Is(err, registry.ErrNotFound) {
+		t.Errorf("expected ErrNotFound after deletion, got %v", err)
+	}
+
+	// Check the events of the registry.
+	opts := &bind.FilterOpts{Start: created.BlockNumber.Uint64(), Context: ctx}
+	createdEvents, err := c.Filterer().FilterDIDCreated(opts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer createdEvents.Close()
+	if !createdEvents.Next() {
+		t.Fatal("missing DIDCreated event")
+	}
+	if e := createdEvents.Event; e.Operator != c.From() || e.Did != id || e.Hash != hash || e.Uri != uri {
+		t.Errorf("unexpected DIDCreated event %+v", e)
+	}
+
+	updatedEvents, err := c.Filterer().FilterDIDUpdated(opts, []common.Address{c.From()}, []string{id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer updatedEvents.Close()
+	var updates []bindings.IDIDDIDUpdated
+	for updatedEvents.Next() {
+		updates = append(updates, *updatedEvents.Event)
+	}
+	if len(updates) != 2 {
+		t.Fatalf("expected 2 DIDUpdated events, got %d", len(updates))
+	}
+	if e := updates[0]; e.Hash != hash2 || e.Uri != uri || e.Did != crypto.Keccak256Hash([]byte(id)) {
+		t.Errorf("unexpected DIDUpdated event for the hash %+v", e)
+	}
+	if e := updates[1]; e.Hash != hash2 || e.Uri != uri2 || e.Raw.BlockNumber != updated.BlockNumber.Uint64() {
+		t.Errorf("unexpected DIDUpdated event for the URI %+v", e)
+	}
+
+	deletedEvents, err := c.Filterer().FilterDIDDeleted(opts, nil, []string{id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer deletedEvents.Close()
+	if !deletedEvents.Next() || deletedEvents.Event.Raw.TxHash != deleted.TxHash {
+		t.Error("missing DIDDeleted event")
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	ctx := context.Background()
+	chain := registrytest.NewChain(t, 2)
+	owner, other := chain.NewClient(t, 0), chain.NewClient(t, 1)
+
+	tx, err := owner.Create(ctx, "device", nil, common.Hash{1}, "ipfs://doc")
+	chain.Mine(t, owner)(tx, err)
+
+	for _, tt := range []struct {
+		name   string
+		send   func() (*types.Transaction, error)
+		reason string
+	}{
+		{"duplicate", func() (*types.Transaction, error) {
+			return other.Create(ctx, "device", nil, common.Hash{2}, "ipfs://other")
+		}, registrytest.ReasonExists},
+		{"empty DID", func() (*types.Transaction, error) {
+			return owner.Create(ctx, "", nil, common.Hash{2}, "ipfs://other")
+		}, registrytest.ReasonEmptyDID},
+		{"not owner", func() (*types.Transaction, error) {
+			return other.UpdateHash(ctx, "device", nil, common.Hash{2})
+		}, registrytest.ReasonUnauthorized},
+		{"not found", func() (*types.Transaction, error) {
+			return owner.Delete(ctx, "missing", nil)
+		}, registrytest.ReasonNotFound},
+	} {
+		_, err := tt.send()
+		if !errors.Is(err, registry.ErrReverted) || !strings.Contains(err.Error(), tt.reason) {
+			t.Errorf("%s: expected revert with %q, got %v", tt.name, tt.reason, err)
+		}
+	}
+
+	reader, err := registry.New(chain.Backend.Client(), chain.Registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reader.Delete(ctx, "device", nil); !errors.Is(err, registry.ErrNoSigner) {
+		t.Errorf("expected ErrNoSigner, got %v", err)
+	}
+
+	// A transaction failing once mined reports the revert reason too.
+	forced := chain.NewClient(t, 1, registry.WithGasLimit(200_000))
+	tx, err = forced.Delete(ctx, "device", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain.Backend.Commit()
+	receipt, err := forced.Wait(ctx, tx)
+	if !errors.Is(err, registry.ErrReverted) || !strings.Contains(err.Error(), registrytest.ReasonUnauthorized) {
+		t.Errorf("expected revert with %q, got %v", registrytest.ReasonUnauthorized, err)
+	}
+	if receipt == nil || receipt.Status != types.ReceiptStatusFailed {
+		t.Errorf("expected failed receipt, got %+v", receipt)
+	}
+}
+
+func TestClientNonces(t *testing.T) {
+	ctx := context.Background()
+	chain := registrytest.NewChain(t, 1)
+	c := chain.NewClient(t, 0)
+
+	// Transactions sent concurrently, within a block, get consecutive nonces.
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		txs []*types.Transaction
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			tx, err := c.Create(ctx, "device-"+string(rune('a'+i)), nil, common.Hash{byte(i)}, "ipfs://doc")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			txs = append(txs, tx)
+			mu.Unlock()
+		}(i)
+	}
+	wg.Wait()
+	chain.Backend.Commit()
+
+	nonces := make(map[uint64]bool)
+	for _, tx := range txs {
+		if _, err := c.Wait(ctx, tx); err != nil {
+			t.Fatal(err)
+		}
+		nonces[tx.Nonce()] = true
+	}
+	for i := uint64(0); i < uint64(len(txs)); i++ {
+		if !nonces[i+1] { // The registry was deployed with nonce 0
+			t.Errorf("missing nonce %d", i+1)
+		}
+	}
+	for i := 0; i < 8; i++ {
+		if _, err := c.Record(ctx, "device-"+string(rune('a'+i)), nil); err != nil {
+			t.Errorf("device %d: %v", i, err)
+		}
+	}
+}
+
+func TestClientConfirmations(t *testing.T) {
+	ctx := context.Background()
+	chain := registrytest.NewChain(t, 1)
+	c := chain.NewClient(t, 0, registry.WithConfirmations(3))
+
+	tx, err := c.Create(ctx, "device", nil, common.Hash{1}, "ipfs://doc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan *types.Receipt)
+	go func() {
+		receipt, err := c.Wait(ctx, tx)
+		if err != nil {
+			t.Error(err)
+		}
+		done <- receipt
+	}()
+	for i := 0; i < 2; i++ {
+		chain.Backend.Commit()
+		select {
+		case <-done:
+			t.Fatalf("receipt returned with %d confirmations", i+1)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	chain.Backend.Commit()
+	select {
+	case receipt := <-done:
+		if receipt == nil || receipt.BlockNumber.Cmp(big.NewInt(2)) != 0 {
+			t.Errorf("unexpected receipt %+v", receipt)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for 3 confirmations")
+	}
+}
+
+// laggingBackend reports the genesis block as head while lagging, like a node
+// behind the one that mined a transaction.
+type laggingBackend struct {
+	registry.Backend
+	lagging atomic.Bool
+}
+
+func (b *laggingBackend) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	if number == nil && b.lagging.Load() {
+		number = big.NewInt(0)
+	}
+	return b.Backend.HeaderByNumber(ctx, number)
+}
+
+func TestClientLaggingHead(t *testing.T) {
+	ctx := context.Background()
+	chain := registrytest.NewChain(t, 1)
+	backend := &laggingBackend{Backend: chain.Backend.Client()}
+	c, err := registry.New(backend, chain.Registry, registry.WithKey(chain.Accounts[0].Key), registry.WithPollInterval(10*time.Millisecond), registry.WithConfirmations(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := c.Create(ctx, "device", nil, common.Hash{1}, "ipfs://doc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain.Backend.Commit()
+	chain.Backend.Commit()
+
+	backend.lagging.Store(true)
+	done := make(chan *types.Receipt)
+	go func() {
+		receipt, err := c.Wait(ctx, tx)
+		if err != nil {
+			t.Error(err)
+		}
+		done <- receipt
+	}()
+	select {
+	case <-done:
+		t.Fatal("receipt returned with the head behind it")
+	case <-time.After(100 * time.Millisecond):
+	}
+	backend.lagging.Store(false)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for confirmations")
+	}
+}
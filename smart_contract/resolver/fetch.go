@@ -0,0 +1,75 @@
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Fetcher fetches the documents at the URIs of the registry.
+type Fetcher interface {
+	Fetch(ctx context.Context, uri string) ([]byte, error)
+}
+
+// HTTPFetcher fetches documents over HTTP, and from IPFS through a gateway.
+type HTTPFetcher struct {
+	Client  *http.Client // http.DefaultClient if nil
+	Gateway string       // Base URL of the IPFS gateway, https://ipfs.io if empty
+	MaxSize int64        // Maximum document size, 1 megabyte if 0
+}
+
+// Fetch implements Fetcher for http, https and ipfs URIs. An ipfs://<cid>/path
+// URI is fetched from <gateway>/ipfs/<cid>/path.
+func (f *HTTPFetcher) Fetch(ctx context.Context, uri string) ([]byte, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("parsing document URI: %w", err)
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+	case "ipfs":
+		gateway := f.Gateway
+		if gateway == "" {
+			gateway = "https://ipfs.io"
+		}
+		uri = strings.TrimSuffix(gateway, "/") + "/ipfs/" + u.Host + u.EscapedPath()
+	default:
+		return nil, fmt.Errorf("unsupported document URI scheme %q", u.Scheme)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching document: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching document: %s", resp.Status)
+	}
+
+	maxSize := f.MaxSize
+	if maxSize == 0 {
+		maxSize = 1024 * 1024
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading document: %w", err)
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("document larger than %d bytes", maxSize)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,213 @@
+// Package resolver resolves the DIDs anchored in the on-chain registry, in
+// the manner of did:ethr. The registry keeps the owner of a DID along with
+// the hash and URI of its document: the document is fetched from the URI,
+// checked against the hash and completed with a verification method of the
+// owner's account.
+//
+// Records are read from the registry contract or from its event index. Past
+// versions are resolved with the VersionID and VersionTime options, a version
+// id being the number of a block.
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"EncrypteDL/EncryrpteID/did"
+)
+
+// DefaultMethod is the DID method resolved by default. The method specific
+// identifier of a DID is its identifier in the registry.
+const DefaultMethod = "encrypteid"
+
+// Verification method of the owner added to documents.
+const (
+	controllerFragment = "controller"
+	controllerType     = "EcdsaSecp256k1RecoveryMethod2020"
+	controllerContext  = "https://w3id.org/security/suites/secp256k1recovery-2020/v2"
+)
+
+// Resolver is a did.Resolver of the DIDs of a registry.
+type Resolver struct {
+	source  Source
+	chainID *big.Int
+	method  string
+	fetcher Fetcher
+	hash    func([]byte) common.Hash
+}
+
+// Option configures a Resolver at construction time.
+type Option func(*Resolver)
+
+// WithMethod sets the DID method resolved, DefaultMethod by default.
+func WithMethod(method string) Option {
+	return func(r *Resolver) {
+		r.method = method
+	}
+}
+
+// WithFetcher sets the fetcher of documents, an HTTPFetcher using the public
+// IPFS gateway by default.
+func WithFetcher(f Fetcher) Option {
+	return func(r *Resolver) {
+		r.fetcher = f
+	}
+}
+
+// WithHash sets the function hashing documents to compare them with the
+// registry, keccak256 by default.
+func WithHash(hash func([]byte) common.Hash) Option {
+	return func(r *Resolver) {
+		r.hash = hash
+	}
+}
+
+// New constructs a Resolver reading records from source. The chain ID
+// qualifies the accounts of owners in documents.
+func New(source Source, chainID *big.Int, opts ...Option) (*Resolver, error) {
+	r := Resolver{
+		source:  source,
+		chainID: chainID,
+		method:  DefaultMethod,
+		fetcher: &HTTPFetcher{},
+		hash:    func(data []byte) common.Hash { return crypto.Keccak256Hash(data) },
+	}
+	for _, opt := range opts {
+		opt(&r)
+	}
+	if _, err := did.Parse("did:" + r.method + ":x"); err != nil {
+		return nil, fmt.Errorf("invalid method name %q", r.method)
+	}
+	if chainID == nil || chainID.Sign() <= 0 {
+		return nil, errors.New("chain ID must be greater than 0")
+	}
+	return &r, nil
+}
+
+// Resolve implements did.Resolver. A deleted DID resolves to a document
+// without verification methods, along with did.ErrDeactivated.
+func (r *Resolver) Resolve(ctx context.Context, id string, opts did.ResolutionOptions) (*did.Resolution, error) {
+	d, err := did.Parse(id)
+	if err != nil {
+		return nil, err
+	}
+	if d.Method != r.method {
+		return nil, did.NewError(did.ErrMethodNotSupported, id, nil)
+	}
+	if opts.Accept != "" && opts.Accept != did.MediaTypeJSON && opts.Accept != did.MediaTypeJSONLD {
+		return nil, did.NewError(did.ErrRepresentationNotSupported, id, errors.New(opts.Accept))
+	}
+
+	var at At
+	switch {
+	case opts.VersionID != "":
+		block, ok := new(big.Int).SetString(opts.VersionID, 10)
+		if !ok || block.Sign() < 0 {
+			return nil, did.NewError(did.ErrNotFound, id, fmt.Errorf("invalid version id %q", opts.VersionID))
+		}
+		at.Block = block
+	case !opts.VersionTime.IsZero():
+		at.Time = opts.VersionTime
+	}
+
+	v, err := r.source.Version(ctx, d.ID, at)
+	if err != nil {
+		return nil, err
+	}
+
+	res := did.Resolution{DocumentMetadata: metadata(v)}
+	if v.Deleted {
+		res.Document = &did.Document{ID: d}
+		res.ResolutionMetadata.ContentType = did.MediaTypeJSON
+		return &res, did.NewError(did.ErrDeactivated, id, nil)
+	}
+
+	doc, err := r.document(ctx, d, v)
+	if err != nil {
+		return nil, err
+	}
+	res.Document = doc
+
+	switch {
+	case opts.Accept == did.MediaTypeJSON:
+		doc.Context = nil
+		res.ResolutionMetadata.ContentType = did.MediaTypeJSON
+	case opts.Accept == did.MediaTypeJSONLD || len(doc.Context) > 0:
+		if len(doc.Context) == 0 || doc.Context[0] != did.ContextV1 {
+			doc.Context = append(did.Context{did.ContextV1}, doc.Context...)
+		}
+		if !slices.Contains(doc.Context, any(controllerContext)) {
+			doc.Context = append(doc.Context, controllerContext)
+		}
+		res.ResolutionMetadata.ContentType = did.MediaTypeJSONLD
+	default:
+		res.ResolutionMetadata.ContentType = did.MediaTypeJSON
+	}
+
+	return &res, nil
+}
+
+// document fetches and checks the document of a version and adds the
+// verification method of the owner.
+func (r *Resolver) document(ctx context.Context, d did.DID, v Version) (*did.Document, error) {
+	data, err := r.fetcher.Fetch(ctx, v.URI)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", d, err)
+	}
+	if hash := r.hash(data); hash != v.Hash {
+		return nil, did.NewError(did.ErrInvalidDocument, d.String(), fmt.Errorf("document hash %s doesn't match the registry hash %s", hash, v.Hash))
+	}
+
+	doc, err := did.Unmarshal(data, did.MediaTypeJSON)
+	if err != nil {
+		return nil, err
+	}
+	if doc.ID != d {
+		return nil, did.NewError(did.ErrInvalidDocument, d.String(), fmt.Errorf("document of %s", doc.ID))
+	}
+
+	controller := d.String() + "#" + controllerFragment
+	if _, exists := doc.VerificationMethodByID(controller); !exists {
+		doc.VerificationMethod = append(doc.VerificationMethod, did.VerificationMethod{
+			ID:                  controller,
+			Type:                controllerType,
+			Controller:          d,
+			BlockchainAccountID: fmt.Sprintf("eip155:%s:%s", r.chainID, v.Owner.Hex()),
+		})
+		doc.Authentication = append(doc.Authentication, did.VerificationRelationship{Reference: controller})
+		doc.AssertionMethod = append(doc.AssertionMethod, did.VerificationRelationship{Reference: controller})
+	}
+
+	return doc, nil
+}
+
+// metadata returns the document metadata of a version.
+func metadata(v Version) did.DocumentMetadata {
+	var m did.DocumentMetadata
+	if !v.Created.IsZero() {
+		m.Created = timePtr(v.Created)
+	}
+	if !v.Updated.IsZero() {
+		m.Updated = timePtr(v.Updated)
+	}
+	if v.Block != 0 {
+		m.VersionID = strconv.FormatUint(v.Block, 10)
+	}
+	if v.Next != 0 {
+		m.NextVersionID = strconv.FormatUint(v.Next, 10)
+	}
+	m.Deactivated = v.Deleted
+	return m
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
@@ -0,0 +1,322 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"EncrypteDL/EncryrpteID/did"
+	"EncrypteDL/EncryrpteID/smart_contract/indexer"
+	"EncrypteDL/EncryrpteID/smart_contract/internal/registrytest"
+	"EncrypteDL/EncryrpteID/smart_contract/registry"
+)
+
+// fixture is a registry with a DID that had three versions: created with a
+// document over HTTP, updated with one on IPFS, then deleted. Versions are a
+// minute apart.
+type fixture struct {
+	chain   *registrytest.Chain
+	client  *registry.Client
+	fetcher *HTTPFetcher
+	docs    map[string]string
+	blocks  []*types.Header // Blocks of the versions
+}
+
+const device = "did:encrypteid:device"
+
+func document(id, endpoint string) string {
+	return `{"@context":"https://www.w3.org/ns/did/v1","id":"` + id + `","service":[{"id":"#files","type":"LinkedDomains","serviceEndpoint":"` + endpoint + `"}]}`
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	ctx := context.Background()
+	f := fixture{
+		chain: registrytest.NewChain(t, 1),
+		docs: map[string]string{
+			"/v1.json":              document(device, "https://v1.example.com"),
+			"/ipfs/bafyv2/doc.json": document(device, "https://v2.example.com"),
+			"/tampered.json":        document("did:encrypteid:tampered", "https://evil.example.com"),
+			"/other.json":           document("did:encrypteid:someone-else", "https://other.example.com"),
+		},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		doc, ok := f.docs[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(doc))
+	}))
+	t.Cleanup(server.Close)
+	f.fetcher = &HTTPFetcher{Client: server.Client(), Gateway: server.URL + "/"}
+
+	f.client = f.chain.NewClient(t, 0)
+	hash := func(path string) common.Hash {
+		return crypto.Keccak256Hash([]byte(f.docs[path]))
+	}
+	send := func(txs ...*types.Transaction) {
+		t.Helper()
+		f.chain.Backend.Commit()
+		var receipt *types.Receipt
+		for _, tx := range txs {
+			var err error
+			if receipt, err = f.client.Wait(ctx, tx); err != nil {
+				t.Fatal(err)
+			}
+		}
+		header, err := f.chain.Backend.Client().HeaderByNumber(ctx, receipt.BlockNumber)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.blocks = append(f.blocks, header)
+		if err := f.chain.Backend.AdjustTime(time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+	must := func(tx *types.Transaction, err error) *types.Transaction {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tx
+	}
+
+	send(must(f.client.Create(ctx, "device", nil, hash("/v1.json"), server.URL+"/v1.json")),
+		// The hash doesn't match the document.
+		must(f.client.Create(ctx, "tampered", nil, hash("/v1.json"), server.URL+"/tampered.json")),
+		// The document is another DID's.
+		must(f.client.Create(ctx, "other", nil, hash("/other.json"), server.URL+"/other.json")),
+		must(f.client.Create(ctx, "unreachable", nil, common.Hash{}, server.URL+"/missing.json")))
+	send(must(f.client.UpdateHash(ctx, "device", nil, hash("/ipfs/bafyv2/doc.json"))),
+		must(f.client.UpdateURI(ctx, "device", nil, "ipfs://bafyv2/doc.json")))
+	send(must(f.client.Delete(ctx, "device", nil)))
+
+	return &f
+}
+
+// failingHeaders is a HeaderReader of an unreachable node.
+type failingHeaders struct{}
+
+func (failingHeaders) HeaderByNumber(context.Context, *big.Int) (*types.Header, error) {
+	return nil, errors.New("connection refused")
+}
+
+func (f *fixture) resolver(t *testing.T, source Source) *Resolver {
+	t.Helper()
+	r, err := New(source, big.NewInt(1337), WithFetcher(f.fetcher))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// at returns the time of a version plus an offset.
+func (f *fixture) at(version int, offset time.Duration) time.Time {
+	return time.Unix(int64(f.blocks[version].Time), 0).Add(offset)
+}
+
+// checkDocument checks a resolution has the document of a version.
+func (f *fixture) checkDocument(t *testing.T, res *did.Resolution, err error, endpoint string) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := res.Document
+	if doc.ID != did.MustParse(device) || len(doc.Service) != 1 || doc.Service[0].ServiceEndpoint != endpoint {
+		t.Fatalf("unexpected document %+v", doc)
+	}
+	controller, ok := doc.VerificationMethodByID("#controller")
+	if !ok || controller.Type != "EcdsaSecp256k1RecoveryMethod2020" || controller.BlockchainAccountID != "eip155:1337:"+f.client.From().Hex() {
+		t.Errorf("unexpected controller method %+v", controller)
+	}
+	if methods := doc.VerificationMethods(did.Authentication); len(methods) != 1 || methods[0].ID != controller.ID {
+		t.Errorf("unexpected authentication methods %+v", methods)
+	}
+	if res.ResolutionMetadata.ContentType != did.MediaTypeJSONLD || !slices.Contains(doc.Context, any(controllerContext)) || doc.Context[0] != did.ContextV1 {
+		t.Errorf("unexpected representation %s with context %v", res.ResolutionMetadata.ContentType, doc.Context)
+	}
+	if err := doc.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestResolverContract(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	r := f.resolver(t, NewContract(f.client, f.chain.Backend.Client()))
+
+	// The contract doesn't remember deleted DIDs.
+	if _, err := r.Resolve(ctx, device, did.ResolutionOptions{}); !errors.Is(err, did.ErrNotFound) {
+		t.Fatalf("expected notFound, got %v", err)
+	}
+
+	res, err := r.Resolve(ctx, device, did.ResolutionOptions{VersionID: f.blocks[0].Number.String()})
+	f.checkDocument(t, res, err, "https://v1.example.com")
+	if m := res.DocumentMetadata; m.VersionID != "" || m.Created != nil || m.Deactivated {
+		t.Errorf("unexpected metadata %+v", m)
+	}
+	res, err = r.Resolve(ctx, device, did.ResolutionOptions{VersionTime: f.at(1, 30*time.Second)})
+	f.checkDocument(t, res, err, "https://v2.example.com")
+	res, err = r.Resolve(ctx, device, did.ResolutionOptions{VersionTime: f.at(1, -time.Second)})
+	f.checkDocument(t, res, err, "https://v1.example.com")
+
+	for _, at := range []time.Time{f.at(0, -time.Second), time.Unix(1, 0)} {
+		if _, err := r.Resolve(ctx, device, did.ResolutionOptions{VersionTime: at}); !errors.Is(err, did.ErrNotFound) {
+			t.Errorf("expected notFound at %s, got %v", at, err)
+		}
+	}
+}
+
+func TestResolverIndex(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	ix, err := indexer.New(f.chain.Backend.Client(), f.chain.Registry, "", indexer.WithConfirmations(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ix.Close()
+	if err := ix.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	r := f.resolver(t, NewIndex(ix, f.chain.Backend.Client()))
+	version := func(i int) string {
+		return f.blocks[i].Number.String()
+	}
+
+	res, err := r.Resolve(ctx, device, did.ResolutionOptions{})
+	if !errors.Is(err, did.ErrDeactivated) || res == nil || !res.DocumentMetadata.Deactivated ||
+		res.DocumentMetadata.VersionID != version(2) || len(res.Document.VerificationMethod) != 0 {
+		t.Fatalf("expected deactivated resolution, got %+v, %v", res, err)
+	}
+
+	res, err = r.Resolve(ctx, device, did.ResolutionOptions{VersionID: version(0)})
+	f.checkDocument(t, res, err, "https://v1.example.com")
+	created := f.at(0, 0).UTC()
+	want := did.DocumentMetadata{Created: &created, Updated: &created, VersionID: version(0), NextVersionID: version(1)}
+	if m := res.DocumentMetadata; *m.Created != *want.Created || *m.Updated != *want.Updated || m.VersionID != want.VersionID || m.NextVersionID != want.NextVersionID {
+		t.Errorf("unexpected metadata %+v, want %+v", m, want)
+	}
+
+	res, err = r.Resolve(ctx, device, did.ResolutionOptions{VersionTime: f.at(2, -time.Second)})
+	f.checkDocument(t, res, err, "https://v2.example.com")
+	if m := res.DocumentMetadata; m.VersionID != version(1) || m.NextVersionID != version(2) || !m.Updated.Equal(f.at(1, 0)) || !m.Created.Equal(created) {
+		t.Errorf("unexpected metadata %+v", m)
+	}
+
+	// Versions past the checkpoint aren't confirmed yet.
+	checkpoint, _, err := ix.Checkpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, err := f.chain.Backend.Client().HeaderByNumber(ctx, new(big.Int).SetUint64(checkpoint))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, opts := range []did.ResolutionOptions{
+		{VersionID: strconv.FormatUint(checkpoint+1, 10)},
+		{VersionTime: time.Unix(int64(header.Time), 0)},
+	} {
+		if _, err := r.Resolve(ctx, device, opts); !errors.Is(err, did.ErrNotFound) || !errors.Is(err, ErrUnconfirmed) {
+			t.Errorf("expected an unconfirmed version for %+v, got %v", opts, err)
+		}
+	}
+	res, err = r.Resolve(ctx, device, did.ResolutionOptions{VersionID: strconv.FormatUint(checkpoint, 10)})
+	if !errors.Is(err, did.ErrDeactivated) || res.DocumentMetadata.VersionID != version(2) {
+		t.Errorf("expected the deactivated version at the checkpoint, got %+v, %v", res, err)
+	}
+
+	// Failing to read the checkpoint block isn't a missing version.
+	unreachable := f.resolver(t, NewIndex(ix, failingHeaders{}))
+	if _, err := unreachable.Resolve(ctx, device, did.ResolutionOptions{VersionTime: f.at(0, 0)}); err == nil || did.ErrorCode(err) != "internalError" {
+		t.Errorf("expected internal error without headers, got %v", err)
+	}
+
+	// Versions before the creation don't exist.
+	for _, opts := range []did.ResolutionOptions{
+		{VersionID: strconv.FormatUint(f.blocks[0].Number.Uint64()-1, 10)},
+		{VersionTime: f.at(0, -time.Second)},
+		{VersionID: "latest"},
+	} {
+		if _, err := r.Resolve(ctx, device, opts); !errors.Is(err, did.ErrNotFound) {
+			t.Errorf("expected notFound for %+v, got %v", opts, err)
+		}
+	}
+}
+
+func TestResolverErrors(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	r := f.resolver(t, NewContract(f.client, f.chain.Backend.Client()))
+	methods := did.Methods{DefaultMethod: r}
+
+	for _, tt := range []struct {
+		did  string
+		opts did.ResolutionOptions
+		want error
+	}{
+		{"did:encrypteid:tampered", did.ResolutionOptions{}, did.ErrInvalidDocument},
+		{"did:encrypteid:other", did.ResolutionOptions{}, did.ErrInvalidDocument},
+		{"did:encrypteid:missing", did.ResolutionOptions{}, did.ErrNotFound},
+		{"did:ethr:0x1", did.ResolutionOptions{}, did.ErrMethodNotSupported},
+		{"did:encrypteid", did.ResolutionOptions{}, did.ErrInvalidDID},
+		{"did:encrypteid:other", did.ResolutionOptions{Accept: "text/html"}, did.ErrRepresentationNotSupported},
+	} {
+		if _, err := methods.Resolve(ctx, tt.did, tt.opts); !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.did, tt.want, err)
+		}
+	}
+	_, err := r.Resolve(ctx, "did:encrypteid:unreachable", did.ResolutionOptions{})
+	if err == nil || did.ErrorCode(err) != "internalError" {
+		t.Errorf("expected internal error for an unreachable document, got %v", err)
+	}
+
+	// The JSON representation has no context.
+	res, err := methods.Resolve(ctx, device, did.ResolutionOptions{Accept: did.MediaTypeJSON, VersionID: f.blocks[0].Number.String()})
+	if err != nil || res.ResolutionMetadata.ContentType != did.MediaTypeJSON || res.Document.Context != nil {
+		t.Errorf("unexpected JSON resolution %+v, %v", res, err)
+	}
+
+	if _, err := New(NewContract(f.client, f.chain.Backend.Client()), nil); err == nil {
+		t.Error("expected an error without chain ID")
+	}
+	if _, err := New(NewContract(f.client, f.chain.Backend.Client()), big.NewInt(1), WithMethod("Bad")); err == nil {
+		t.Error("expected an error with an invalid method")
+	}
+}
+
+func TestHTTPFetcher(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ipfs/bafy/a%20b.json" || r.URL.Path == "/ipfs/bafy/a b.json" {
+			w.Write([]byte("ipfs"))
+			return
+		}
+		if r.URL.Path == "/large" {
+			w.Write(make([]byte, 11))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+	f := HTTPFetcher{Client: server.Client(), Gateway: server.URL, MaxSize: 10}
+
+	if data, err := f.Fetch(ctx, "ipfs://bafy/a%20b.json"); err != nil || string(data) != "ipfs" {
+		t.Errorf("unexpected IPFS fetch %q, %v", data, err)
+	}
+	for _, uri := range []string{server.URL + "/large", server.URL + "/missing", "ftp://example.com/doc", "::"} {
+		if _, err := f.Fetch(ctx, uri); err == nil {
+			t.Errorf("expected an error fetching %s", uri)
+		}
+	}
+}
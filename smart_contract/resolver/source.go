@@ -0,0 +1,230 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"EncrypteDL/EncryrpteID/did"
+	"EncrypteDL/EncryrpteID/smart_contract/indexer"
+	"EncrypteDL/EncryrpteID/smart_contract/registry"
+)
+
+// ErrUnconfirmed is wrapped in the did.ErrNotFound errors of versions the
+// index doesn't know yet: the ones selected by a block or a time past its
+// checkpoint.
+var ErrUnconfirmed = errors.New("version not confirmed by the index yet")
+
+// At selects a version of a DID: the one current at a block, else at a time,
+// else the latest.
+type At struct {
+	Block *big.Int
+	Time  time.Time
+}
+
+// Version is a version of the registry record of a DID.
+type Version struct {
+	Owner   common.Address
+	Hash    common.Hash
+	URI     string
+	Deleted bool
+	Block   uint64    // Block of the event creating the version, 0 if unknown
+	Next    uint64    // Block of the next version, 0 if none or unknown
+	Created time.Time // Time the DID was created, zero if unknown
+	Updated time.Time // Time of the version, zero if unknown
+}
+
+// Source reads the versions of DID records. It returns an error matching
+// did.ErrNotFound if the DID doesn't exist at the selected version.
+type Source interface {
+	Version(ctx context.Context, id string, at At) (Version, error)
+}
+
+// HeaderReader reads block headers, such as an *ethclient.Client does.
+type HeaderReader interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+}
+
+// Contract is a Source reading the registry contract. It can't tell deleted
+// DIDs from unknown ones nor when versions were created: use an Index for
+// complete metadata.
+type Contract struct {
+	client  *registry.Client
+	headers HeaderReader
+}
+
+// NewContract returns a Source reading the registry with client. Headers are
+// read to find the block at a time.
+func NewContract(client *registry.Client, headers HeaderReader) *Contract {
+	return &Contract{client: client, headers: headers}
+}
+
+// Version implements Source.
+func (c *Contract) Version(ctx context.Context, id string, at At) (Version, error) {
+	block := at.Block
+	if block == nil && !at.Time.IsZero() {
+		var err error
+		if block, err = c.blockAt(ctx, at.Time); err != nil {
+			return Version{}, err
+		}
+		if block == nil {
+			return Version{}, did.NewError(did.ErrNotFound, id, errors.New("no block before the version time"))
+		}
+	}
+
+	rec, err := c.client.Record(ctx, id, block)
+	// There are no DIDs before the registry is deployed.
+	if errors.Is(err, registry.ErrNotFound) || errors.Is(err, bind.ErrNoCode) {
+		return Version{}, did.NewError(did.ErrNotFound, id, nil)
+	}
+	if err != nil {
+		return Version{}, err
+	}
+
+	return Version{Owner: rec.Owner, Hash: rec.Hash, URI: rec.URI}, nil
+}
+
+// blockAt returns the last block mined at or before t, nil if there is none.
+func (c *Contract) blockAt(ctx context.Context, t time.Time) (*big.Int, error) {
+	header := func(n uint64) (*types.Header, error) {
+		h, err := c.headers.HeaderByNumber(ctx, new(big.Int).SetUint64(n))
+		if err != nil {
+			return nil, fmt.Errorf("reading block %d: %w", n, err)
+		}
+		return h, nil
+	}
+
+	head, err := c.headers.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("reading head: %w", err)
+	}
+	if !time.Unix(int64(head.Time), 0).After(t) {
+		return head.Number, nil
+	}
+
+	// Search the first block after t.
+	lo, hi := uint64(0), head.Number.Uint64()
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		h, err := header(mid)
+		if err != nil {
+			return nil, err
+		}
+		if time.Unix(int64(h.Time), 0).After(t) {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	if lo == 0 {
+		return nil, nil
+	}
+	return new(big.Int).SetUint64(lo - 1), nil
+}
+
+// Index is a Source reading the event index of the registry. It only knows
+// the confirmed blocks: the latest version is the one at its checkpoint, and
+// later blocks and times fail with ErrUnconfirmed.
+type Index struct {
+	ix      *indexer.Indexer
+	headers HeaderReader
+}
+
+// NewIndex returns a Source reading an index. Headers are read to find the
+// time of the index checkpoint.
+func NewIndex(ix *indexer.Indexer, headers HeaderReader) *Index {
+	return &Index{ix: ix, headers: headers}
+}
+
+// Version implements Source.
+func (i *Index) Version(ctx context.Context, id string, at At) (Version, error) {
+	if at.Block != nil && !at.Block.IsUint64() {
+		return Version{}, did.NewError(did.ErrNotFound, id, fmt.Errorf("invalid block %s", at.Block))
+	}
+	if err := i.confirmed(ctx, at); err != nil {
+		if errors.Is(err, ErrUnconfirmed) {
+			err = did.NewError(did.ErrNotFound, id, err)
+		}
+		return Version{}, err
+	}
+
+	history, err := i.ix.History(id)
+	if err != nil {
+		return Version{}, err
+	}
+	selected := func(e indexer.Event) bool {
+		switch {
+		case at.Block != nil:
+			return e.Block <= at.Block.Uint64()
+		case !at.Time.IsZero():
+			return !time.Unix(int64(e.Time), 0).After(at.Time)
+		}
+		return true
+	}
+
+	var v *Version
+	for _, e := range history {
+		if !selected(e) {
+			if v != nil {
+				v.Next = e.Block
+			}
+			break
+		}
+		if v == nil || e.Kind == indexer.Created {
+			v = &Version{Owner: e.Owner, Created: time.Unix(int64(e.Time), 0).UTC()}
+		}
+		// Deleted versions keep the last document.
+		if e.Kind == indexer.Deleted {
+			v.Deleted = true
+		} else {
+			v.Hash, v.URI = e.Hash, e.URI
+		}
+		v.Block, v.Updated = e.Block, time.Unix(int64(e.Time), 0).UTC()
+	}
+	if v == nil {
+		return Version{}, did.NewError(did.ErrNotFound, id, nil)
+	}
+
+	return *v, nil
+}
+
+// confirmed returns an error wrapping ErrUnconfirmed if the selected version
+// is past the index checkpoint, or the error reading the checkpoint.
+func (i *Index) confirmed(ctx context.Context, at At) error {
+	if at.Block == nil && at.Time.IsZero() {
+		return nil
+	}
+	number, hash, err := i.ix.Checkpoint()
+	if err != nil {
+		return fmt.Errorf("reading index checkpoint: %w", err)
+	}
+	if hash == (common.Hash{}) {
+		return fmt.Errorf("%w: nothing indexed", ErrUnconfirmed)
+	}
+	if at.Block != nil {
+		if at.Block.Uint64() > number {
+			return fmt.Errorf("%w: block %s is past the checkpoint %d", ErrUnconfirmed, at.Block, number)
+		}
+		return nil
+	}
+
+	header, err := i.headers.HeaderByNumber(ctx, new(big.Int).SetUint64(number))
+	if err != nil {
+		return fmt.Errorf("reading checkpoint block %d: %w", number, err)
+	}
+	// A reorganization moved the checkpoint, the index catches up later.
+	if header.Hash() != hash {
+		return fmt.Errorf("%w: checkpoint block %d was reorganized", ErrUnconfirmed, number)
+	}
+	// Later blocks can share the time of the checkpoint.
+	if !at.Time.Before(time.Unix(int64(header.Time), 0)) {
+		return fmt.Errorf("%w: %s is not before the checkpoint %d", ErrUnconfirmed, at.Time, number)
+	}
+	return nil
+}